@@ -58,21 +58,21 @@ export const useTodoStore = defineStore('todo', () => {
     return id
   }
 
-  // 更新待办
-  async function updateTodo(todo: any): Promise<void> {
-    await api.UpdateTodo(todo as any)
+  // 更新待办，循环实例(repeatIndex > 0)按 scope 修改，其他待办修改记录本身
+  async function updateTodo(todo: any, scope = 'all'): Promise<void> {
+    await api.UpdateTodoWithScope(todo as any, scope as any)
     await fetchPendingTodos()
   }
 
-  // 删除待办
-  async function deleteTodo(id: number): Promise<void> {
-    await api.DeleteTodo(id)
+  // 删除待办，循环实例(repeatIndex > 0)按 scope 删除，repeatIndex 为 0 时删除整条记录
+  async function deleteTodo(id: number, repeatIndex = 0, scope = 'all'): Promise<void> {
+    await api.DeleteTodoWithScope(id, repeatIndex, scope as any)
     await fetchPendingTodos()
   }
 
-  // 标记待办完成
-  async function markTodoCompleted(id: number, completed: boolean): Promise<void> {
-    await api.MarkTodoCompleted(id, completed)
+  // 标记待办完成，循环待办需指定实例序号
+  async function markTodoCompleted(id: number, completed: boolean, repeatIndex = 0): Promise<void> {
+    await api.MarkOccurrenceCompleted(id, repeatIndex, completed)
     await fetchPendingTodos()
   }
 
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 全天待办去掉时间部分，在月视图中出现在跨越的每一天并排在有具体时间的待办之前
+func TestAllDayTodoInCalendarMonth(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 14, 30, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:     "出差",
+		Type:      models.TodoTypeTask,
+		AllDay:    true,
+		StartDate: models.FlexTime{Time: start},
+		EndDate:   models.FlexTime{Time: start.AddDate(0, 0, 2)},
+	})
+	if err != nil {
+		t.Fatalf("创建全天待办失败: %v", err)
+	}
+	createTask(t, a, "写周报")
+
+	todo, _ := a.GetTodo(id)
+	wantStart := time.Date(2025, 6, 2, 0, 0, 0, 0, time.Local)
+	wantEnd := time.Date(2025, 6, 4, 23, 59, 59, 0, time.Local)
+	if !todo.StartDate.Time.Equal(wantStart) || !todo.EndDate.Time.Equal(wantEnd) {
+		t.Errorf("起止时间为 %v - %v，应为 %v - %v", todo.StartDate.Time, todo.EndDate.Time, wantStart, wantEnd)
+	}
+
+	days, err := a.GetCalendarMonth(2025, 6)
+	if err != nil {
+		t.Fatalf("获取月视图失败: %v", err)
+	}
+	got := map[string]models.CalendarDay{}
+	for _, day := range days {
+		got[day.Date] = day
+	}
+	for _, date := range []string{"2025-06-02", "2025-06-03", "2025-06-04"} {
+		if day := got[date]; day.AllDayCount != 1 || day.Todos[0].ID != id {
+			t.Errorf("%s 的全天待办数为 %d，应为 1 个且排在最前", date, day.AllDayCount)
+		}
+	}
+	if day := got["2025-06-02"]; day.TodoCount != 2 {
+		t.Errorf("6月2日有 %d 个待办，应为 2 个", day.TodoCount)
+	}
+	if day := got["2025-06-05"]; day.TodoCount != 0 {
+		t.Errorf("6月5日有 %d 个待办，全天待办不应延续到结束日之后", day.TodoCount)
+	}
+}
+
+// 全天待办的提醒时间按设置的时刻和提前天数计算，无效设置报错
+func TestAllDayRemindSettings(t *testing.T) {
+	a := newTestApp(t)
+	settings, err := a.GetSettings()
+	if err != nil {
+		t.Fatalf("获取设置失败: %v", err)
+	}
+	if settings.AllDayRemindTime != models.DefaultAllDayRemindTime {
+		t.Errorf("默认提醒时间为 %q，应为 %q", settings.AllDayRemindTime, models.DefaultAllDayRemindTime)
+	}
+
+	settings.AllDayRemindTime = "20:00"
+	settings.AllDayRemindDaysBefore = 1
+	day := time.Date(2025, 6, 2, 0, 0, 0, 0, time.Local)
+	if got, want := settings.AllDayRemindAt(day), time.Date(2025, 6, 1, 20, 0, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("提醒时间为 %v，应为 %v", got, want)
+	}
+
+	for name, invalid := range map[string]models.Settings{
+		"无效时间": {AllDayRemindTime: "25:00"},
+		"负数天数": {AllDayRemindTime: "09:00", AllDayRemindDaysBefore: -1},
+	} {
+		if err := a.UpdateSettings(invalid); err == nil {
+			t.Errorf("%s: 保存设置应报错", name)
+		}
+	}
+}
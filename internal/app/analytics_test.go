@@ -0,0 +1,125 @@
+package app
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// completeAt 将待办标记为在 at 时刻完成
+func completeAt(t *testing.T, a *App, id int64, at time.Time) {
+	t.Helper()
+	if err := a.MarkTodoCompleted(id, true); err != nil {
+		t.Fatalf("标记完成失败: %v", err)
+	}
+	if _, err := a.db.Exec("UPDATE todos SET completed_at = ? WHERE id = ?", at, id); err != nil {
+		t.Fatalf("设置完成时间失败: %v", err)
+	}
+}
+
+// createAt 创建开始于 start、持续一小时的待办
+func createAt(t *testing.T, a *App, title string, todoType models.TodoType, start time.Time) int64 {
+	t.Helper()
+	id, err := a.CreateTodo(models.Todo{
+		Title:     title,
+		Type:      todoType,
+		StartDate: models.FlexTime{Time: start},
+		EndDate:   models.FlexTime{Time: start.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	return id
+}
+
+// 按开始时间统计范围内的待办，循环待办按实例统计
+func TestAnalytics(t *testing.T) {
+	a := newTestApp(t)
+	day := func(d, hour int) time.Time { return time.Date(2025, 6, d, hour, 0, 0, 0, time.Local) }
+
+	// 提前30分钟完成、延迟60分钟完成、逾期未完成
+	completeAt(t, a, createAt(t, a, "周会", models.TodoTypeWork, day(2, 9)), day(2, 9).Add(30*time.Minute))
+	completeAt(t, a, createAt(t, a, "评审", models.TodoTypeWork, day(3, 14)), day(3, 16))
+	createAt(t, a, "买菜", models.TodoTypeTask, day(4, 9))
+	// 范围之外
+	createAt(t, a, "体检", models.TodoTypeTask, day(9, 9))
+	// 6月5日起每天一次，共3次，第1次提前60分钟完成
+	seriesID := createDailySeries(t, a, day(5, 9), 3)
+	if err := a.MarkOccurrenceCompleted(seriesID, 1, true); err != nil {
+		t.Fatalf("标记实例完成失败: %v", err)
+	}
+	if _, err := a.db.Exec("UPDATE todo_instances SET completed_at = ? WHERE todo_id = ? AND instance_index = 1", day(5, 9), seriesID); err != nil {
+		t.Fatalf("设置实例完成时间失败: %v", err)
+	}
+
+	got, err := a.GetAnalytics("2025-06-02", "2025-06-08")
+	if err != nil {
+		t.Fatalf("获取统计失败: %v", err)
+	}
+
+	want := models.CompletionStats{Total: 6, Completed: 3, CompletionRate: 0.5, OnTime: 2, Late: 1, AvgLateMinutes: -10, Overdue: 3}
+	if !sameStats(got.Summary, want) {
+		t.Errorf("总体统计为 %+v，应为 %+v", got.Summary, want)
+	}
+	if len(got.ByType) != 2 || got.ByType[0].Type != models.TodoTypeTask || got.ByType[0].Stats.Total != 1 || got.ByType[1].Stats.Total != 5 {
+		t.Errorf("按类型统计为 %+v，应为 task 1 个、work 5 个", got.ByType)
+	}
+	if len(got.ByWeek) != 1 || got.ByWeek[0].WeekStart != "2025-06-02" || got.ByWeek[0].OpenOverdue != 3 {
+		t.Errorf("按周统计为 %+v，应为 6月2日一周、周末逾期 3 个", got.ByWeek)
+	}
+	if want := []int{1, 1, 1, 1, 1, 1, 0}; !equalInts(got.Weekdays, want) {
+		t.Errorf("星期分布为 %v，应为 %v", got.Weekdays, want)
+	}
+	if got.Hours[9] != 5 || got.Hours[14] != 1 {
+		t.Errorf("9点开始 %d 个、14点开始 %d 个，应为 5 个和 1 个", got.Hours[9], got.Hours[14])
+	}
+	if len(got.Series) != 1 {
+		t.Fatalf("循环系列统计有 %d 个，应为 1 个", len(got.Series))
+	}
+	if series := got.Series[0]; series.TodoID != seriesID || series.Due != 3 || series.Completed != 1 || series.OnTime != 1 {
+		t.Errorf("循环系列统计为 %+v，应为到期 3 次、完成和按时完成各 1 次", series)
+	}
+}
+
+// 日期格式无效或结束日期早于开始日期时报错
+func TestAnalyticsInvalidRange(t *testing.T) {
+	a := newTestApp(t)
+	for _, r := range [][2]string{
+		{"2025/06/01", "2025-06-30"},
+		{"2025-06-01", "06-30"},
+		{"2025-06-30", "2025-06-01"},
+	} {
+		if _, err := a.GetAnalytics(r[0], r[1]); err == nil {
+			t.Errorf("统计 %s 至 %s 应报错", r[0], r[1])
+		}
+	}
+	got, err := a.GetAnalytics("2025-06-01", "2025-06-01")
+	if err != nil {
+		t.Fatalf("获取空范围统计失败: %v", err)
+	}
+	if got.Summary.Total != 0 || got.Summary.CompletionRate != 0 {
+		t.Errorf("没有待办时统计为 %+v，应全部为0", got.Summary)
+	}
+}
+
+// sameStats 比较完成情况，比例和平均值允许浮点误差
+func sameStats(a, b models.CompletionStats) bool {
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return a.Total == b.Total && a.Completed == b.Completed && a.OnTime == b.OnTime && a.Late == b.Late &&
+		a.Overdue == b.Overdue && near(a.CompletionRate, b.CompletionRate) && near(a.AvgLateMinutes, b.AvgLateMinutes)
+}
+
+// equalInts 比较两个整数切片
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
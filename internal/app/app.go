@@ -5,10 +5,16 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"todo-calendar/internal/database"
+	"todo-calendar/internal/datadir"
 	"todo-calendar/internal/models"
 	"todo-calendar/internal/notification"
 	"todo-calendar/internal/utils"
@@ -23,6 +29,21 @@ type App struct {
 	todoRepo       *database.TodoRepository
 	settingsRepo   *database.SettingsRepository
 	attachmentRepo *database.AttachmentRepository
+	tagRepo        *database.TagRepository
+	todoTypeRepo   *database.TodoTypeRepository
+	calendarRepo   *database.CalendarRepository
+	fieldRepo      *database.CustomFieldRepository
+	analyticsRepo  *database.AnalyticsRepository
+	entryRepo      *database.TimeEntryRepository
+	snoozeRepo     *database.SnoozeRepository
+	logRepo        *database.NotificationLogRepository
+	subtaskRepo    *database.SubtaskRepository
+	dependencyRepo *database.DependencyRepository
+	revisionRepo   *database.RevisionRepository
+	operationRepo  *database.OperationRepository
+	purgerStop     chan struct{}  // 关闭时通知回收站清理停止
+	purgerStopOnce sync.Once      // 保证 purgerStop 只关闭一次
+	purgerWG       sync.WaitGroup // 等待回收站清理退出
 }
 
 // NewApp creates app instance
@@ -32,9 +53,28 @@ func NewApp(db *sql.DB) *App {
 		todoRepo:       database.NewTodoRepository(db),
 		settingsRepo:   database.NewSettingsRepository(db),
 		attachmentRepo: database.NewAttachmentRepository(db),
+		tagRepo:        database.NewTagRepository(db),
+		todoTypeRepo:   database.NewTodoTypeRepository(db),
+		calendarRepo:   database.NewCalendarRepository(db),
+		fieldRepo:      database.NewCustomFieldRepository(db),
+		analyticsRepo:  database.NewAnalyticsRepository(db),
+		entryRepo:      database.NewTimeEntryRepository(db),
+		snoozeRepo:     database.NewSnoozeRepository(db),
+		logRepo:        database.NewNotificationLogRepository(db),
+		subtaskRepo:    database.NewSubtaskRepository(db),
+		dependencyRepo: database.NewDependencyRepository(db),
+		revisionRepo:   database.NewRevisionRepository(db),
+		operationRepo:  database.NewOperationRepository(db),
+		purgerStop:     make(chan struct{}),
 	}
 }
 
+// SetOrigin 设置当前窗口(main/widget/notification)，记录在修改历史和操作记录中
+func (a *App) SetOrigin(origin string) {
+	a.todoRepo.SetOrigin(origin)
+	a.operationRepo.SetOrigin(origin)
+}
+
 // SetContext sets context
 func (a *App) SetContext(ctx context.Context) {
 	a.ctx = ctx
@@ -51,173 +91,1509 @@ func (a *App) GetMinimizeToTray() bool {
 
 // ==================== Todo API ====================
 
-// CreateTodo creates todo (支持循环创建多条记录)
+// CreateTodo creates todo (循环待办只创建一条系列记录，实例按需计算)
 func (a *App) CreateTodo(todo models.Todo) (int64, error) {
 	if todo.Title == "" {
 		return 0, fmt.Errorf("title cannot be empty")
 	}
-	if todo.AdvanceRemind <= 0 {
-		todo.AdvanceRemind = 15
+	if err := a.applyCalendar(&todo); err != nil {
+		return 0, err
+	}
+	if err := a.applyTypeDefaults(&todo); err != nil {
+		return 0, err
+	}
+	if err := a.applyCustomFields(&todo, nil); err != nil {
+		return 0, err
+	}
+	if err := validatePriority(todo.Priority); err != nil {
+		return 0, err
+	}
+	if err := normalizeMeeting(&todo); err != nil {
+		return 0, err
+	}
+	applyAllDay(&todo)
+	if err := applyTimeZone(&todo, ""); err != nil {
+		return 0, err
 	}
+	todo.IsOccurrence = false
 
 	// 如果没有循环，直接创建一条记录
-	if todo.RepeatType == models.RepeatTypeNone || todo.RepeatType == "" {
+	if !todo.IsSeries() {
 		// 单次任务也设置循环信息为 1/1
+		todo.RepeatType = models.RepeatTypeNone
+		todo.CronExpr = ""
+		todo.RepeatEndDate = nil
+		todo.RepeatCount = 0
 		todo.RepeatIndex = 1
 		todo.RepeatTotal = 1
-		id, err := a.todoRepo.Create(&todo)
+		id, err := a.createTodo(&todo)
 		if err != nil {
 			return 0, fmt.Errorf("创建待办失败: %w", err)
 		}
 		return id, nil
 	}
 
-	// 有循环，根据 cron 表达式和结束日期计算所有日期并创建多条记录
-	var scheduledTimes []time.Time
-
-	if todo.CronExpr != "" && todo.RepeatEndDate != nil && !todo.RepeatEndDate.Time.IsZero() {
-		scheduledTimes = utils.GetCronScheduledTimes(
-			todo.CronExpr,
-			time.Now(), // 从现在开始计算
-			1000,       // 最多1000次
-		)
-		// 过滤掉零值时间和超过结束日期的时间
-		filtered := make([]time.Time, 0)
-		for _, t := range scheduledTimes {
-			if !t.IsZero() && !t.After(todo.RepeatEndDate.Time) {
-				filtered = append(filtered, t)
-			}
-		}
-		scheduledTimes = filtered
+	if err := prepareSeries(&todo); err != nil {
+		return 0, err
+	}
+
+	id, err := a.createTodo(&todo)
+	if err != nil {
+		return 0, fmt.Errorf("创建循环待办失败: %w", err)
+	}
+	return id, nil
+}
+
+// createTodo 在事务中创建待办及其标签
+func (a *App) createTodo(todo *models.Todo) (int64, error) {
+	var id int64
+	err := a.trackOperation(models.OperationCreate, nil, func(tx *sql.Tx) ([]int64, error) {
+		var err error
+		id, err = a.todoRepo.WithTx(tx).Create(todo)
+		return []int64{id}, err
+	})
+	return id, err
+}
+
+// applyCalendar 校验待办所属的日历，未指定时使用默认日历；未指定类型时使用日历的默认类型
+func (a *App) applyCalendar(todo *models.Todo) error {
+	var calendar *models.Calendar
+	var err error
+	if todo.CalendarID <= 0 {
+		calendar, err = a.calendarRepo.Default()
+	} else {
+		calendar, err = a.calendarRepo.GetByID(todo.CalendarID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("日历不存在: %d", todo.CalendarID)
+		}
+		return err
+	}
+	todo.CalendarID = calendar.ID
+	if todo.Type == "" {
+		todo.Type = calendar.DefaultType
+	}
+	return nil
+}
+
+// applyTypeDefaults 校验待办类型并应用类型的默认提醒设置
+// 未指定类型时使用排在第一位的类型；未设置任何提醒时使用类型的默认提醒
+func (a *App) applyTypeDefaults(todo *models.Todo) error {
+	if todo.Type == "" {
+		types, err := a.todoTypeRepo.List()
+		if err != nil {
+			return err
+		}
+		if len(types) == 0 {
+			return fmt.Errorf("没有可用的待办类型")
+		}
+		todo.Type = types[0].Value
+	}
+	todoType, err := a.todoTypeRepo.GetByValue(todo.Type)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("未知的待办类型: %s", todo.Type)
+		}
+		return err
+	}
+
+	if todo.AdvanceRemind <= 0 && !todo.RemindAtStart && !todo.RemindAtEnd {
+		todo.RemindAtStart = todoType.RemindAtStart
+		todo.RemindAtEnd = todoType.RemindAtEnd
+	}
+	if todo.AdvanceRemind <= 0 {
+		todo.AdvanceRemind = todoType.AdvanceRemind
+	}
+	return nil
+}
+
+// applyExistingType 修改待办时校验类型存在，类型为空时沿用原类型
+func (a *App) applyExistingType(todo *models.Todo, existing *models.Todo) error {
+	if todo.Type == "" {
+		todo.Type = existing.Type
+	}
+	if _, err := a.todoTypeRepo.GetByValue(todo.Type); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("未知的待办类型: %s", todo.Type)
+		}
+		return err
+	}
+	return nil
+}
+
+// normalizeMeeting 整理会议信息：会议链接只允许 http/https，去掉姓名和邮箱都为空的参会人
+func normalizeMeeting(todo *models.Todo) error {
+	todo.Location = strings.TrimSpace(todo.Location)
+	todo.MeetingURL = strings.TrimSpace(todo.MeetingURL)
+	if todo.MeetingURL != "" {
+		u, err := url.Parse(todo.MeetingURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("无效的会议链接: %s", todo.MeetingURL)
+		}
+	}
+	if todo.Attendees == nil {
+		return nil
+	}
+	attendees := []models.Attendee{}
+	for _, attendee := range todo.Attendees {
+		attendee.Name = strings.TrimSpace(attendee.Name)
+		attendee.Email = strings.TrimSpace(attendee.Email)
+		if attendee.Name == "" && attendee.Email == "" {
+			continue
+		}
+		if attendee.Email != "" {
+			if _, err := mail.ParseAddress(attendee.Email); err != nil {
+				return fmt.Errorf("无效的参会人邮箱: %s", attendee.Email)
+			}
+		}
+		attendees = append(attendees, attendee)
+	}
+	todo.Attendees = attendees
+	return nil
+}
+
+// applyAllDay 全天待办去掉时间部分：开始为首日 00:00，结束为末日 23:59:59，时区为浮动时间
+func applyAllDay(todo *models.Todo) {
+	if !todo.AllDay {
+		return
+	}
+	todo.TimeZone = models.TimeZoneFloating
+	start := todo.StartDate.Time
+	if start.IsZero() {
+		return
+	}
+	end := todo.EndDate.Time
+	if end.Before(start) {
+		end = start
+	}
+	dayStart := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	dayEnd := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location())
+	todo.StartDate.Time, todo.EndDate.Time = models.AllDayRange(dayStart, dayEnd.Sub(dayStart))
+}
+
+// applyTimeZone 校验待办时区，并将起止时间按该时区的墙上时间解释
+// 未指定时区时使用 fallback，其次使用本机时区，无法确定本机时区名称时为浮动时间
+func applyTimeZone(todo *models.Todo, fallback string) error {
+	if todo.TimeZone == "" {
+		todo.TimeZone = fallback
+	}
+	if todo.TimeZone == "" {
+		todo.TimeZone = utils.LocalZoneName()
+	}
+	if todo.TimeZone == "" {
+		todo.TimeZone = models.TimeZoneFloating
+	}
+	if _, err := models.LoadZone(todo.TimeZone); err != nil {
+		return fmt.Errorf("无效的时区: %s", todo.TimeZone)
+	}
+
+	loc := todo.Zone()
+	inZone := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	}
+	if !todo.StartDate.Time.IsZero() {
+		todo.StartDate.Time = inZone(todo.StartDate.Time)
+	}
+	if !todo.EndDate.Time.IsZero() {
+		todo.EndDate.Time = inZone(todo.EndDate.Time)
+	}
+	if todo.RepeatEndDate != nil && !todo.RepeatEndDate.Time.IsZero() {
+		todo.RepeatEndDate = &models.FlexTime{Time: inZone(todo.RepeatEndDate.Time)}
+	}
+	return nil
+}
+
+// applyCustomFields 按待办类型的字段定义校验自定义字段值并转换为存储格式
+// 未传入字段值时沿用修改前的值(类型改变时视为没有值)，必填字段不能为空
+func (a *App) applyCustomFields(todo *models.Todo, before *models.Todo) error {
+	values := todo.CustomFields
+	if values == nil && before != nil && before.Type == todo.Type {
+		values = before.CustomFields
+	}
+	fields, err := a.fieldRepo.ListByType(todo.Type)
+	if err != nil {
+		return err
+	}
+	defs := make(map[string]*models.CustomFieldDef, len(fields))
+	for i := range fields {
+		defs[fields[i].Key] = &fields[i]
+	}
+
+	normalized := map[string]string{}
+	for key, value := range values {
+		def, ok := defs[key]
+		if !ok {
+			return fmt.Errorf("未知的自定义字段: %s", key)
+		}
+		value, err := def.NormalizeValue(value)
+		if err != nil {
+			return err
+		}
+		if value != "" {
+			normalized[key] = value
+		}
+	}
+	for _, field := range fields {
+		if field.Required && normalized[field.Key] == "" {
+			return fmt.Errorf("%s 不能为空", field.Label)
+		}
+	}
+	todo.CustomFields = normalized
+	return nil
+}
+
+// validatePriority 校验优先级
+func validatePriority(priority int) error {
+	if priority < models.PriorityNone || priority > models.PriorityHigh {
+		return fmt.Errorf("invalid priority: %d", priority)
+	}
+	return nil
+}
+
+// prepareSeries 校验循环规则，将系列起点对齐到第一次执行时间，并计算持续时间和总次数
+func prepareSeries(todo *models.Todo) error {
+	if !utils.IsCronExprValid(todo.CronExpr) {
+		return fmt.Errorf("无效的Cron表达式: %s", todo.CronExpr)
+	}
+
+	// 从开始时间计算第一次执行时间，作为系列的起点
+	if todo.StartDate.Time.IsZero() {
+		todo.StartDate = models.FlexTime{Time: time.Now()}
+	}
+	var repeatEnd time.Time
+	if todo.RepeatEndDate != nil {
+		repeatEnd = todo.RepeatEndDate.Time
+	}
+	bounded := !repeatEnd.IsZero() || todo.RepeatCount > 0
+	total := 0
+	if bounded {
+		total = utils.CountCronOccurrences(todo.CronExpr, todo.StartDate.Time, repeatEnd, todo.RepeatCount)
+		if total == 0 {
+			return fmt.Errorf("循环规则在终止时间前没有任何执行时间")
+		}
+	}
+	first := utils.GetCronOccurrenceAt(todo.CronExpr, todo.StartDate.Time, 1)
+	if first.IsZero() {
+		return fmt.Errorf("循环规则没有任何执行时间")
+	}
+
+	duration := todo.OccurrenceDuration()
+	todo.StartDate = models.FlexTime{Time: first}
+	todo.EndDate = models.FlexTime{Time: first.Add(duration)}
+	todo.DurationMinutes = int(duration / time.Minute)
+	if todo.AllDay {
+		start, end := models.AllDayRange(first, duration)
+		todo.StartDate = models.FlexTime{Time: start}
+		todo.EndDate = models.FlexTime{Time: end}
+	}
+	// 系列记录的序号为0，总次数在有终止条件时记录，否则为0
+	todo.RepeatIndex = 0
+	todo.RepeatTotal = 0
+	if bounded {
+		todo.RepeatTotal = total
+	}
+	return nil
+}
+
+// UpdateTodo updates todo (只更新单条记录，循环待办需使用 UpdateTodoWithScope)
+func (a *App) UpdateTodo(todo models.Todo) error {
+	if todo.ID <= 0 {
+		return fmt.Errorf("invalid todo ID")
+	}
+	if err := validatePriority(todo.Priority); err != nil {
+		return err
+	}
+	if err := normalizeMeeting(&todo); err != nil {
+		return err
+	}
+	if todo.CalendarID > 0 {
+		if err := a.applyCalendar(&todo); err != nil {
+			return err
+		}
+	}
+	existing, err := a.todoRepo.GetByID(todo.ID)
+	if err != nil {
+		return err
+	}
+	if existing.IsSeries() {
+		return fmt.Errorf("循环待办需使用 UpdateTodoWithScope 指定修改范围")
+	}
+	if err := a.applyExistingType(&todo, existing); err != nil {
+		return err
+	}
+	if err := a.applyCustomFields(&todo, existing); err != nil {
+		return err
+	}
+	if todo.TimeZone == "" {
+		todo.TimeZone = existing.TimeZone
+	}
+	applyAllDay(&todo)
+	if err := applyTimeZone(&todo, ""); err != nil {
+		return err
+	}
+	return a.trackOperation(models.OperationUpdate, []int64{todo.ID}, func(tx *sql.Tx) ([]int64, error) {
+		return nil, a.todoRepo.WithTx(tx).Update(&todo)
+	})
+}
+
+// DeleteTodo 将待办移入回收站(附件保留，清理时才删除)
+func (a *App) DeleteTodo(id int64) error {
+	return a.trackOperation(models.OperationDelete, []int64{id}, func(tx *sql.Tx) ([]int64, error) {
+		return nil, a.todoRepo.WithTx(tx).MoveToTrash(id)
+	})
+}
+
+// UpdateTodoWithScope 按范围修改循环待办，返回修改后实例所在系列的ID
+// todo.ID 为系列ID，todo.RepeatIndex 为被编辑的实例序号(0表示直接编辑系列)
+// todo.CronExpr 为空时沿用原循环规则；非循环待办忽略 scope
+func (a *App) UpdateTodoWithScope(todo models.Todo, scope models.EditScope) (int64, error) {
+	if todo.ID <= 0 {
+		return 0, fmt.Errorf("invalid todo ID")
+	}
+	if err := validatePriority(todo.Priority); err != nil {
+		return 0, err
+	}
+	if err := normalizeMeeting(&todo); err != nil {
+		return 0, err
+	}
+	series, err := a.todoRepo.GetByID(todo.ID)
+	if err != nil {
+		return 0, err
+	}
+	if todo.CalendarID > 0 {
+		if err := a.applyCalendar(&todo); err != nil {
+			return 0, err
+		}
+	}
+	if err := a.applyExistingType(&todo, series); err != nil {
+		return 0, err
+	}
+	if err := a.applyCustomFields(&todo, series); err != nil {
+		return 0, err
+	}
+	applyAllDay(&todo)
+	if err := applyTimeZone(&todo, series.TimeZone); err != nil {
+		return 0, err
+	}
+	if !series.IsSeries() {
+		return todo.ID, a.UpdateTodo(todo)
+	}
+	if todo.RepeatIndex <= 0 && scope != models.EditScopeAll {
+		return 0, fmt.Errorf("editing scope %q requires an occurrence", scope)
+	}
+
+	switch scope {
+	case models.EditScopeSingle:
+		return todo.ID, a.trackOperation(models.OperationUpdate, []int64{todo.ID}, func(tx *sql.Tx) ([]int64, error) {
+			return nil, a.todoRepo.WithTx(tx).UpdateOccurrence(&todo)
+		})
+	case models.EditScopeFollowing:
+		if todo.RepeatIndex > 1 {
+			return a.splitSeries(series, todo)
+		}
+		// 从第一个实例开始修改等同于修改整个系列
+		fallthrough
+	case models.EditScopeAll:
+		updated := applySeriesChanges(series, todo)
+		if todo.RepeatIndex <= 0 && !todo.StartDate.Time.IsZero() {
+			updated.StartDate = todo.StartDate
+		}
+		if err := prepareSeries(&updated); err != nil {
+			return 0, err
+		}
+		err := a.trackOperation(models.OperationUpdate, []int64{updated.ID}, func(tx *sql.Tx) ([]int64, error) {
+			return nil, a.todoRepo.WithTx(tx).UpdateSeries(&updated)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("修改循环待办失败: %w", err)
+		}
+		return updated.ID, nil
+	default:
+		return 0, fmt.Errorf("invalid edit scope: %s", scope)
+	}
+}
+
+// DeleteTodoWithScope 按范围删除循环待办，非循环待办忽略 scope
+func (a *App) DeleteTodoWithScope(id int64, repeatIndex int, scope models.EditScope) error {
+	series, err := a.todoRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if !series.IsSeries() {
+		return a.DeleteTodo(id)
+	}
+
+	switch scope {
+	case models.EditScopeSingle:
+		return a.trackOperation(models.OperationDelete, []int64{id}, func(tx *sql.Tx) ([]int64, error) {
+			return nil, a.todoRepo.WithTx(tx).CancelOccurrence(id, repeatIndex)
+		})
+	case models.EditScopeFollowing:
+		if repeatIndex > 1 {
+			return a.trackOperation(models.OperationDelete, []int64{id}, func(tx *sql.Tx) ([]int64, error) {
+				return nil, a.todoRepo.WithTx(tx).TruncateSeries(id, repeatIndex)
+			})
+		}
+		// 从第一个实例开始删除等同于删除整个系列
+		fallthrough
+	case models.EditScopeAll:
+		return a.DeleteTodo(id)
+	default:
+		return fmt.Errorf("invalid edit scope: %s", scope)
+	}
+}
+
+// splitSeries 从 edited.RepeatIndex 开始拆分出新系列，附件复制到新系列
+func (a *App) splitSeries(series *models.Todo, edited models.Todo) (int64, error) {
+	index := edited.RepeatIndex
+	scheduled, err := a.todoRepo.GetScheduledAt(series.ID, index)
+	if err != nil {
+		return 0, err
+	}
+
+	next := applySeriesChanges(series, edited)
+	next.ID = 0
+	next.StartDate = models.FlexTime{Time: scheduled}
+	next.IsCompleted = false
+	next.CompletedAt = nil
+	next.StartRemindTriggered = false
+	// 沿用原规则(未传规则或规则和次数未改)时，按次数限制的系列只保留剩余次数
+	sameRule := edited.CronExpr == "" || (edited.CronExpr == series.CronExpr && edited.RepeatCount == series.RepeatCount)
+	if sameRule && series.RepeatCount > 0 {
+		next.RepeatCount = series.RepeatCount - (index - 1)
+	}
+	if err := prepareSeries(&next); err != nil {
+		return 0, err
+	}
+
+	var copied []models.Attachment
+	var newID int64
+	err = a.trackOperation(models.OperationUpdate, []int64{series.ID}, func(tx *sql.Tx) ([]int64, error) {
+		var err error
+		newID, err = a.todoRepo.WithTx(tx).SplitSeries(series.ID, index, &next)
+		if err != nil {
+			return nil, err
+		}
+		copied, err = a.attachmentRepo.WithTx(tx).CopyToTodo(series.ID, newID)
+		return []int64{newID}, err
+	})
+	if err != nil {
+		for _, attachment := range copied {
+			os.Remove(attachment.StoragePath)
+		}
+		return 0, fmt.Errorf("拆分循环待办失败: %w", err)
+	}
+	return newID, nil
+}
+
+// applySeriesChanges 将编辑内容合并到系列记录上
+// 起点保持不变；edited.CronExpr 非空时替换循环规则
+func applySeriesChanges(series *models.Todo, edited models.Todo) models.Todo {
+	updated := *series
+	updated.Title = edited.Title
+	updated.Content = edited.Content
+	updated.Type = edited.Type
+	updated.IsLunar = edited.IsLunar
+	updated.HideYear = edited.HideYear
+	updated.AdvanceRemind = edited.AdvanceRemind
+	updated.RemindAtStart = edited.RemindAtStart
+	updated.RemindAtEnd = edited.RemindAtEnd
+	updated.Priority = edited.Priority
+	updated.AllDay = edited.AllDay
+	updated.Location = edited.Location
+	updated.MeetingURL = edited.MeetingURL
+	if edited.Attendees != nil {
+		updated.Attendees = edited.Attendees
+	}
+	if edited.CustomFields != nil {
+		updated.CustomFields = edited.CustomFields
+	}
+	if edited.CalendarID > 0 {
+		updated.CalendarID = edited.CalendarID
+	}
+	if edited.TimeZone != "" {
+		updated.TimeZone = edited.TimeZone
+	}
+	if updated.AdvanceRemind <= 0 {
+		updated.AdvanceRemind = 15
+	}
+
+	if edited.CronExpr != "" {
+		updated.RepeatType = edited.RepeatType
+		if updated.RepeatType == "" || updated.RepeatType == models.RepeatTypeNone {
+			updated.RepeatType = models.RepeatTypeCustom
+		}
+		updated.CronExpr = edited.CronExpr
+		updated.RepeatEndDate = edited.RepeatEndDate
+		updated.RepeatCount = edited.RepeatCount
+	}
+
+	// 持续时间优先取 DurationMinutes，其次取编辑后实例的起止时间差
+	if edited.DurationMinutes > 0 {
+		updated.DurationMinutes = edited.DurationMinutes
+	} else if edited.EndDate.Time.After(edited.StartDate.Time) {
+		updated.DurationMinutes = int(edited.EndDate.Time.Sub(edited.StartDate.Time) / time.Minute)
+	}
+	if edited.Tags != nil {
+		updated.Tags = edited.Tags
+	}
+	updated.IsOccurrence = false
+	return updated
+}
+
+// GetTodo gets single todo
+func (a *App) GetTodo(id int64) (*models.Todo, error) {
+	return a.todoRepo.GetByID(id)
+}
+
+// GetTodoList gets todo list
+func (a *App) GetTodoList(filter models.TodoFilter) (*models.TodoListResult, error) {
+	return a.todoRepo.List(filter)
+}
+
+// SearchTodos 全文搜索待办(标题、内容、附件名)，按相关度排序
+func (a *App) SearchTodos(keyword string, limit int) ([]models.SearchResult, error) {
+	return a.todoRepo.Search(keyword, limit)
+}
+
+// GetTodoOccurrence 获取循环待办的第 repeatIndex 个实例
+func (a *App) GetTodoOccurrence(id int64, repeatIndex int) (*models.Todo, error) {
+	return a.todoRepo.GetOccurrence(id, repeatIndex)
+}
+
+// OpenMeetingLink 在浏览器中打开待办的线上会议链接
+func (a *App) OpenMeetingLink(id int64) error {
+	todo, err := a.todoRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if todo.MeetingURL == "" {
+		return fmt.Errorf("待办没有会议链接")
+	}
+	runtime.BrowserOpenURL(a.ctx, todo.MeetingURL)
+	return nil
+}
+
+// GetPendingTodos gets pending todos
+func (a *App) GetPendingTodos() ([]models.Todo, error) {
+	return a.todoRepo.GetPendingTodos()
+}
+
+// GetTodayStartRemindTodos 获取今天需要开始提醒的待办（软件启动时调用）
+// 隐藏或静音的日历中的待办不提醒
+func (a *App) GetTodayStartRemindTodos() ([]models.Todo, error) {
+	todos, err := a.todoRepo.GetTodayStartRemindTodos()
+	if err != nil {
+		return nil, err
+	}
+	silenced, err := a.calendarRepo.SilencedIDs()
+	if err != nil {
+		return nil, err
+	}
+	return withoutCalendars(todos, silenced), nil
+}
+
+// MarkStartRemindTriggered 标记开始提醒已触发
+func (a *App) MarkStartRemindTriggered(id int64) error {
+	return a.todoRepo.MarkStartRemindTriggered(id)
+}
+
+// MarkOccurrenceStartRemindTriggered 标记循环实例的开始提醒已触发
+func (a *App) MarkOccurrenceStartRemindTriggered(id int64, repeatIndex int) error {
+	return a.todoRepo.MarkOccurrenceStartRemindTriggered(id, repeatIndex)
+}
+
+// GetWeekTodos gets week todos (deprecated, use GetWeekTodosNew)
+func (a *App) GetWeekTodos() (*models.WeekTodos, error) {
+	return a.todoRepo.GetWeekTodos()
+}
+
+// GetWeekTodosNew 获取本周待办(新版)
+func (a *App) GetWeekTodosNew() (*models.WeekTodosResult, error) {
+	return a.GetWeekTodosWithFilter(models.TodoFilter{})
+}
+
+// GetWeekTodosWithFilter 获取本周待办，按筛选条件过滤
+func (a *App) GetWeekTodosWithFilter(filter models.TodoFilter) (*models.WeekTodosResult, error) {
+	if err := filter.ValidateSort(); err != nil {
+		return nil, err
+	}
+	if err := filter.ValidateCustomFields(); err != nil {
+		return nil, err
+	}
+	overdue, todos, err := a.todoRepo.GetWeekTodosNew()
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := a.calendarRepo.HiddenIDs()
+	if err != nil {
+		return nil, err
+	}
+	return &models.WeekTodosResult{
+		Overdue: filterTodos(withoutCalendars(overdue, hidden), filter),
+		Todos:   filterTodos(withoutCalendars(todos, hidden), filter),
+	}, nil
+}
+
+// filterTodos 按筛选条件过滤和排序已展开的待办列表
+func filterTodos(todos []models.Todo, filter models.TodoFilter) []models.Todo {
+	filtered := []models.Todo{}
+	for _, todo := range todos {
+		if filter.MatchCalendar(todo.CalendarID) && filter.MatchTags(todo.Tags) && filter.MatchCustomFields(todo.CustomFields) {
+			filtered = append(filtered, todo)
+		}
+	}
+	filter.SortTodos(filtered)
+	return filtered
+}
+
+// withoutCalendars 去掉属于指定日历的待办
+func withoutCalendars(todos []models.Todo, calendarIDs map[int64]bool) []models.Todo {
+	if len(calendarIDs) == 0 {
+		return todos
+	}
+	kept := []models.Todo{}
+	for _, todo := range todos {
+		if !calendarIDs[todo.CalendarID] {
+			kept = append(kept, todo)
+		}
+	}
+	return kept
+}
+
+// MarkTodoCompleted marks todo completed (循环待办需使用 MarkOccurrenceCompleted)
+func (a *App) MarkTodoCompleted(id int64, completed bool) error {
+	todo, err := a.todoRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if todo.IsSeries() {
+		return fmt.Errorf("循环待办需按实例标记完成")
+	}
+	return a.trackOperation(completeAction(completed), []int64{id}, func(tx *sql.Tx) ([]int64, error) {
+		return nil, a.todoRepo.WithTx(tx).MarkCompleted(id, completed)
+	})
+}
+
+// MarkOccurrenceCompleted 标记循环实例完成状态(非循环待办等同于 MarkTodoCompleted)
+func (a *App) MarkOccurrenceCompleted(id int64, repeatIndex int, completed bool) error {
+	return a.trackOperation(completeAction(completed), []int64{id}, func(tx *sql.Tx) ([]int64, error) {
+		return nil, a.todoRepo.WithTx(tx).MarkOccurrenceCompleted(id, repeatIndex, completed)
+	})
+}
+
+// completeAction 返回标记完成状态对应的操作类型
+func completeAction(completed bool) string {
+	if completed {
+		return models.OperationComplete
+	}
+	return models.OperationUncomplete
+}
+
+// GetTodosByDate gets todos by date
+func (a *App) GetTodosByDate(dateStr string) ([]models.Todo, error) {
+	return a.GetTodosByDateWithFilter(dateStr, models.TodoFilter{})
+}
+
+// GetTodosByDateWithFilter 获取某天的待办，按筛选条件过滤和排序
+func (a *App) GetTodosByDateWithFilter(dateStr string, filter models.TodoFilter) ([]models.Todo, error) {
+	if err := filter.ValidateSort(); err != nil {
+		return nil, err
+	}
+	if err := filter.ValidateCustomFields(); err != nil {
+		return nil, err
+	}
+	date, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format")
+	}
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	end := start.Add(24*time.Hour - time.Second)
+	todos, err := a.todoRepo.GetByDateRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := a.calendarRepo.HiddenIDs()
+	if err != nil {
+		return nil, err
+	}
+	return filterTodos(withoutCalendars(todos, hidden), filter), nil
+}
+
+// GetTodosByMonth gets todos by month
+func (a *App) GetTodosByMonth(year, month int) ([]models.Todo, error) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 1, 0).Add(-time.Second)
+	todos, err := a.todoRepo.GetByDateRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := a.calendarRepo.HiddenIDs()
+	if err != nil {
+		return nil, err
+	}
+	return withoutCalendars(todos, hidden), nil
+}
+
+// ==================== Bulk API ====================
+
+// BulkMarkCompleted 批量标记完成状态，返回处理的待办数量
+func (a *App) BulkMarkCompleted(target models.BulkTarget, completed bool) (int, error) {
+	return a.bulkApply(completeAction(completed), target, func(tx *sql.Tx, id int64) error {
+		return a.todoRepo.WithTx(tx).MarkCompleted(id, completed)
+	})
+}
+
+// BulkDelete 批量将待办移入回收站，返回处理的待办数量
+func (a *App) BulkDelete(target models.BulkTarget) (int, error) {
+	return a.bulkApply(models.OperationDelete, target, func(tx *sql.Tx, id int64) error {
+		return a.todoRepo.WithTx(tx).MoveToTrash(id)
+	})
+}
+
+// BulkAddTag 批量为待办添加标签，返回处理的待办数量
+func (a *App) BulkAddTag(target models.BulkTarget, tagID int64) (int, error) {
+	if err := a.checkTag(tagID); err != nil {
+		return 0, err
+	}
+	return a.bulkApply(models.OperationUpdate, target, func(tx *sql.Tx, id int64) error {
+		return a.tagRepo.WithTx(tx).AddTodoTag(id, tagID)
+	})
+}
+
+// BulkRemoveTag 批量移除待办的标签，返回处理的待办数量
+func (a *App) BulkRemoveTag(target models.BulkTarget, tagID int64) (int, error) {
+	if err := a.checkTag(tagID); err != nil {
+		return 0, err
+	}
+	return a.bulkApply(models.OperationUpdate, target, func(tx *sql.Tx, id int64) error {
+		return a.tagRepo.WithTx(tx).RemoveTodoTag(id, tagID)
+	})
+}
+
+// BulkChangeType 批量修改待办类型，返回处理的待办数量
+// 类型改变后原类型的自定义字段值不再保留，新类型有必填字段时修改失败
+func (a *App) BulkChangeType(target models.BulkTarget, todoType models.TodoType) (int, error) {
+	if _, err := a.todoTypeRepo.GetByValue(todoType); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("未知的待办类型: %s", todoType)
+		}
+		return 0, err
+	}
+	todos, err := a.bulkTodos(target)
+	if err != nil {
+		return 0, err
+	}
+	for i := range todos {
+		before := todos[i]
+		todos[i].Type = todoType
+		todos[i].CustomFields = nil
+		if err := a.applyCustomFields(&todos[i], &before); err != nil {
+			return 0, fmt.Errorf("%s: %w", todos[i].Title, err)
+		}
+	}
+	return len(todos), a.bulkUpdate(todos)
+}
+
+// BulkShiftDates 批量平移待办的起止时间，返回处理的待办数量
+// 整天的部分按待办时区的日期平移(跨夏令时保持墙上时间)
+// 循环系列的时间由循环规则决定，不能平移，目标中包含循环待办时不做任何修改并返回错误
+func (a *App) BulkShiftDates(target models.BulkTarget, offsetMinutes int) (int, error) {
+	if offsetMinutes == 0 {
+		return 0, nil
+	}
+	todos, err := a.bulkTodos(target)
+	if err != nil {
+		return 0, err
+	}
+	days := offsetMinutes / (24 * 60)
+	rest := time.Duration(offsetMinutes%(24*60)) * time.Minute
+	shift := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return t
+		}
+		return t.AddDate(0, 0, days).Add(rest)
+	}
+
+	for i := range todos {
+		if todos[i].IsSeries() {
+			return 0, fmt.Errorf("%s: 循环待办的时间由循环规则决定，不能批量平移", todos[i].Title)
+		}
+		todos[i].StartDate.Time = shift(todos[i].StartDate.Time)
+		todos[i].EndDate.Time = shift(todos[i].EndDate.Time)
+		applyAllDay(&todos[i])
+	}
+	return len(todos), a.bulkUpdate(todos)
+}
+
+// bulkApply 在一个事务中对目标待办逐个执行 fn，整体记录为一次可撤销的操作
+func (a *App) bulkApply(action string, target models.BulkTarget, fn func(tx *sql.Tx, id int64) error) (int, error) {
+	ids, err := a.bulkTargetIDs(target)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	err = a.trackOperation(action, ids, func(tx *sql.Tx) ([]int64, error) {
+		for _, id := range ids {
+			if err := fn(tx, id); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// bulkUpdate 在一个事务中保存修改后的待办(标签保持不变)，整体记录为一次可撤销的操作
+func (a *App) bulkUpdate(todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(todos))
+	for i := range todos {
+		ids[i] = todos[i].ID
+		todos[i].Tags = nil
+	}
+	return a.trackOperation(models.OperationUpdate, ids, func(tx *sql.Tx) ([]int64, error) {
+		todoRepo := a.todoRepo.WithTx(tx)
+		for i := range todos {
+			var err error
+			if todos[i].IsSeries() {
+				err = todoRepo.UpdateSeries(&todos[i])
+			} else {
+				err = todoRepo.Update(&todos[i])
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+}
+
+// bulkTodos 获取批量操作的目标待办(循环待办为系列记录)
+func (a *App) bulkTodos(target models.BulkTarget) ([]models.Todo, error) {
+	ids, err := a.bulkTargetIDs(target)
+	if err != nil {
+		return nil, err
+	}
+	todos := make([]models.Todo, 0, len(ids))
+	for _, id := range ids {
+		todo, err := a.todoRepo.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+	}
+	return todos, nil
+}
+
+// bulkTargetIDs 解析批量操作的目标待办ID(去重)，指定的待办必须存在且不在回收站中
+func (a *App) bulkTargetIDs(target models.BulkTarget) ([]int64, error) {
+	if target.Filter != nil {
+		if len(target.IDs) > 0 {
+			return nil, fmt.Errorf("不能同时指定待办ID和筛选条件")
+		}
+		if err := target.Filter.ValidateSort(); err != nil {
+			return nil, err
+		}
+		if err := target.Filter.ValidateCustomFields(); err != nil {
+			return nil, err
+		}
+		return a.todoRepo.ListIDs(*target.Filter)
+	}
+
+	ids := []int64{}
+	seen := make(map[int64]bool)
+	for _, id := range target.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := a.todoRepo.GetByID(id); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("待办不存在: %d", id)
+			}
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// checkTag 校验标签是否存在
+func (a *App) checkTag(tagID int64) error {
+	if _, err := a.tagRepo.GetByID(tagID); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("标签不存在: %d", tagID)
+		}
+		return err
+	}
+	return nil
+}
+
+// ==================== Subtask API ====================
+
+// GetSubtasks 获取待办的子任务列表
+func (a *App) GetSubtasks(todoID int64) ([]models.Subtask, error) {
+	return a.subtaskRepo.GetByTodoID(todoID)
+}
+
+// AddSubtask 为待办添加子任务
+func (a *App) AddSubtask(todoID int64, title string) (*models.Subtask, error) {
+	if _, err := a.todoRepo.GetByID(todoID); err != nil {
+		return nil, err
+	}
+	subtask := &models.Subtask{TodoID: todoID, Title: title}
+	id, err := a.subtaskRepo.Create(subtask)
+	if err != nil {
+		return nil, fmt.Errorf("添加子任务失败: %w", err)
+	}
+	return a.subtaskRepo.GetByID(id)
+}
+
+// UpdateSubtask 修改子任务标题
+func (a *App) UpdateSubtask(id int64, title string) error {
+	return a.subtaskRepo.UpdateTitle(id, title)
+}
+
+// DeleteSubtask 删除子任务
+func (a *App) DeleteSubtask(id int64) error {
+	return a.subtaskRepo.Delete(id)
+}
+
+// ReorderSubtasks 按给定顺序排列子任务
+func (a *App) ReorderSubtasks(todoID int64, ids []int64) error {
+	return database.RunInTx(a.db, func(tx *sql.Tx) error {
+		return a.subtaskRepo.WithTx(tx).Reorder(todoID, ids)
+	})
+}
+
+// MarkSubtaskCompleted 标记子任务完成状态
+// 开启自动完成时，非循环待办的子任务全部完成后自动完成待办，与子任务在同一事务中修改
+func (a *App) MarkSubtaskCompleted(id int64, completed bool) error {
+	subtask, err := a.subtaskRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	settings, err := a.settingsRepo.Get()
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	err = database.RunInTx(a.db, func(tx *sql.Tx) error {
+		subtaskRepo := a.subtaskRepo.WithTx(tx)
+		if err := subtaskRepo.MarkCompleted(id, completed); err != nil {
+			return err
+		}
+		if !completed || !settings.AutoCompleteParent {
+			return nil
+		}
+		done, total, err := subtaskRepo.Progress(subtask.TodoID)
+		if err != nil || done < total {
+			return err
+		}
+		todoRepo := a.todoRepo.WithTx(tx)
+		parent, err := todoRepo.GetByID(subtask.TodoID)
+		if err != nil {
+			return err
+		}
+		if parent.IsSeries() || parent.IsCompleted {
+			return nil
+		}
+		// 自动完成待办记录为一次可撤销的操作
+		paths, err = a.operationRepo.WithTx(tx).Track(models.OperationComplete, []int64{parent.ID}, func() ([]int64, error) {
+			return nil, todoRepo.MarkCompleted(parent.ID, true)
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		os.Remove(path)
+	}
+	return nil
+}
+
+// ==================== Dependency API ====================
+
+// AddTodoDependency 添加依赖：todoID 需等待 dependsOnID 完成后才能开始
+// 循环待办没有整体的完成状态，不能作为前置待办
+func (a *App) AddTodoDependency(todoID, dependsOnID int64) error {
+	for _, id := range []int64{todoID, dependsOnID} {
+		todo, err := a.todoRepo.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("待办 %d 不存在: %w", id, err)
+		}
+		if id == dependsOnID && todo.IsSeries() {
+			return fmt.Errorf("循环待办不能作为前置待办")
+		}
+	}
+	return database.RunInTx(a.db, func(tx *sql.Tx) error {
+		return a.dependencyRepo.WithTx(tx).Add(todoID, dependsOnID)
+	})
+}
+
+// RemoveTodoDependency 移除依赖
+func (a *App) RemoveTodoDependency(todoID, dependsOnID int64) error {
+	return a.dependencyRepo.Remove(todoID, dependsOnID)
+}
+
+// GetTodoDependencies 获取待办的前置待办和后续待办
+func (a *App) GetTodoDependencies(todoID int64) (*models.TodoDependencies, error) {
+	blockedBy, err := a.todoRepo.GetBlockers(todoID)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := a.todoRepo.GetDependents(todoID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TodoDependencies{BlockedBy: blockedBy, Blocks: blocks}, nil
+}
+
+// GetDependencyGraph 获取日期范围内的依赖关系图，日期格式 2006-01-02
+func (a *App) GetDependencyGraph(startDateStr, endDateStr string) (*models.DependencyGraph, error) {
+	startDate, err := time.ParseInLocation("2006-01-02", startDateStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date format")
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", endDateStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date format")
+	}
+	return a.todoRepo.GetDependencyGraph(startDate, endDate.Add(24*time.Hour-time.Second))
+}
+
+// ==================== Revision API ====================
+
+// GetTodoRevisions 获取待办的修改历史，最新的在前
+func (a *App) GetTodoRevisions(todoID int64) ([]models.TodoRevision, error) {
+	return a.revisionRepo.ListByTodoID(todoID)
+}
+
+// RestoreTodoRevision 将待办恢复到指定修改之前的状态
+// 依次撤销该修改及之后的所有修改，再按修改整个待办的流程校验和保存：恢复本身也记录为一次修改，并且可以撤销
+func (a *App) RestoreTodoRevision(revisionID int64) error {
+	revision, err := a.revisionRepo.GetByID(revisionID)
+	if err != nil {
+		return err
+	}
+	todo, err := a.todoRepo.GetByID(revision.TodoID)
+	if err != nil {
+		return err
+	}
+	revisions, err := a.revisionRepo.ListSince(revision.TodoID, revision.ID)
+	if err != nil {
+		return err
+	}
+	for _, r := range revisions {
+		if err := database.RevertChanges(todo, r.Changes); err != nil {
+			return err
+		}
+	}
+	// 标签不在修改历史中，保持不变
+	todo.Tags = nil
+	todo.RepeatIndex = 0
+	_, err = a.UpdateTodoWithScope(*todo, models.EditScopeAll)
+	return err
+}
+
+// ==================== Undo API ====================
+
+// Undo 撤销最近一次操作，返回被撤销的操作
+func (a *App) Undo() (*models.Operation, error) {
+	var op *models.Operation
+	err := database.RunInTx(a.db, func(tx *sql.Tx) error {
+		var err error
+		op, err = a.operationRepo.WithTx(tx).Undo()
+		return err
+	})
+	return op, err
+}
+
+// Redo 重做最近一次撤销的操作，返回被重做的操作
+func (a *App) Redo() (*models.Operation, error) {
+	var op *models.Operation
+	err := database.RunInTx(a.db, func(tx *sql.Tx) error {
+		var err error
+		op, err = a.operationRepo.WithTx(tx).Redo()
+		return err
+	})
+	return op, err
+}
+
+// GetUndoState 获取当前可撤销和可重做的操作
+func (a *App) GetUndoState() (*models.UndoState, error) {
+	return a.operationRepo.State()
+}
+
+// trackOperation 在事务中执行 fn 并记录为可撤销的操作
+// todoIDs 为受影响的已有待办，fn 返回新创建的待办ID
+func (a *App) trackOperation(action string, todoIDs []int64, fn func(tx *sql.Tx) ([]int64, error)) error {
+	var paths []string
+	err := database.RunInTx(a.db, func(tx *sql.Tx) error {
+		var err error
+		paths, err = a.operationRepo.WithTx(tx).Track(action, todoIDs, func() ([]int64, error) {
+			return fn(tx)
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// 清理不再被引用的附件文件
+	for _, path := range paths {
+		os.Remove(path)
+	}
+	return nil
+}
+
+// ==================== Trash API ====================
+
+// GetTrash 获取回收站中的待办
+func (a *App) GetTrash() ([]models.Todo, error) {
+	return a.todoRepo.ListTrash()
+}
+
+// RestoreTodo 从回收站恢复待办
+func (a *App) RestoreTodo(id int64) error {
+	return a.todoRepo.Restore(id)
+}
+
+// PurgeTodo 彻底删除回收站中的待办及其附件文件
+func (a *App) PurgeTodo(id int64) error {
+	if _, err := a.todoRepo.GetTrashed(id); err != nil {
+		return err
+	}
+	return a.purgeTodos([]int64{id})
+}
+
+// EmptyTrash 清空回收站
+func (a *App) EmptyTrash() error {
+	ids, err := a.todoRepo.GetTrashedBefore(time.Now().Add(time.Second))
+	if err != nil {
+		return err
+	}
+	return a.purgeTodos(ids)
+}
+
+// PurgeExpiredTrash 清理超过保留天数的回收站待办
+func (a *App) PurgeExpiredTrash() error {
+	settings, err := a.settingsRepo.Get()
+	if err != nil {
+		return err
+	}
+	if settings.TrashRetentionDays <= 0 {
+		return nil
+	}
+	ids, err := a.todoRepo.GetTrashedBefore(time.Now().AddDate(0, 0, -settings.TrashRetentionDays))
+	if err != nil {
+		return err
+	}
+	return a.purgeTodos(ids)
+}
+
+// StartTrashPurger 在后台启动回收站清理：启动时及之后每天清理一次过期的回收站待办
+// 退出前需调用 StopTrashPurger
+func (a *App) StartTrashPurger() {
+	a.purgerWG.Add(1)
+	go func() {
+		defer a.purgerWG.Done()
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			if err := a.PurgeExpiredTrash(); err != nil {
+				log.Printf("清理回收站失败: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-a.purgerStop:
+				return
+			}
+		}
+	}()
+}
+
+// StopTrashPurger 停止回收站清理并等待正在进行的清理结束，需在关闭数据库之前调用
+func (a *App) StopTrashPurger() {
+	a.purgerStopOnce.Do(func() { close(a.purgerStop) })
+	a.purgerWG.Wait()
+}
+
+// purgeTodos 在事务中删除待办记录和附件记录，提交后删除加密文件
+func (a *App) purgeTodos(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
 	}
-
-	if len(scheduledTimes) == 0 {
-		// 如果没有计算出执行时间，只创建一条（也设置为1/1）
-		todo.RepeatIndex = 1
-		todo.RepeatTotal = 1
-		id, err := a.todoRepo.Create(&todo)
-		if err != nil {
-			return 0, fmt.Errorf("创建待办失败: %w", err)
+	var paths []string
+	err := database.RunInTx(a.db, func(tx *sql.Tx) error {
+		todoRepo := a.todoRepo.WithTx(tx)
+		attachmentRepo := a.attachmentRepo.WithTx(tx)
+		for _, id := range ids {
+			removed, err := attachmentRepo.DeleteRecordsByTodoID(id)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, removed...)
+			if err := todoRepo.Delete(id); err != nil {
+				return err
+			}
 		}
-		return id, nil
+		// 彻底删除的待办无法再撤销，同时清理仅被这些操作引用的附件文件
+		removed, err := a.operationRepo.WithTx(tx).DeleteByTodoIDs(ids)
+		paths = append(paths, removed...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("清理回收站失败: %w", err)
 	}
-
-	// 计算持续时间（默认1小时）
-	duration := time.Hour
-	if todo.DurationMinutes > 0 {
-		duration = time.Duration(todo.DurationMinutes) * time.Minute
+	for _, path := range paths {
+		os.Remove(path)
 	}
+	return nil
+}
 
-	// 为每个时间点创建一条独立的待办记录
-	totalCount := len(scheduledTimes)
-	var firstID int64
-	for i, scheduledTime := range scheduledTimes {
-		newTodo := todo
-		newTodo.ID = 0 // 确保创建新记录
-		newTodo.StartDate = models.FlexTime{Time: scheduledTime}
-		// 结束时间 = 开始时间 + 持续时间
-		newTodo.EndDate = models.FlexTime{Time: scheduledTime.Add(duration)}
-		// 设置循环序号信息
-		newTodo.RepeatIndex = i + 1
-		newTodo.RepeatTotal = totalCount
+// ==================== Analytics API ====================
 
-		id, err := a.todoRepo.Create(&newTodo)
-		if err != nil {
-			return 0, fmt.Errorf("创建第 %d 条待办失败: %w", i+1, err)
-		}
-		if i == 0 {
-			firstID = id
-		}
+// GetAnalytics 获取日期范围内(包含首尾两天)的完成率、延迟、逾期和忙碌时段等统计
+func (a *App) GetAnalytics(startDateStr, endDateStr string) (*models.Analytics, error) {
+	start, err := time.ParseInLocation("2006-01-02", startDateStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date format")
+	}
+	end, err := time.ParseInLocation("2006-01-02", endDateStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date format")
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("结束日期不能早于开始日期")
 	}
+	return a.analyticsRepo.Get(start, end.AddDate(0, 0, 1))
+}
+
+// ==================== Time Tracking API ====================
 
-	return firstID, nil
+// StartTimer 开始为待办计时，正在进行的计时(包括番茄钟)先结束
+func (a *App) StartTimer(todoID int64) (*models.TimeEntry, error) {
+	return a.startTimeEntry(todoID, models.TimeEntryTimer, 0)
 }
 
-// UpdateTodo updates todo (只更新单条记录)
-func (a *App) UpdateTodo(todo models.Todo) error {
-	if todo.ID <= 0 {
-		return fmt.Errorf("invalid todo ID")
+// StartPomodoro 为待办开始一个番茄钟，专注结束和休息结束时由提醒服务弹窗提醒
+func (a *App) StartPomodoro(todoID int64) (*models.TimeEntry, error) {
+	settings, err := a.settingsRepo.Get()
+	if err != nil {
+		return nil, err
 	}
-	return a.todoRepo.Update(&todo)
+	minutes := settings.PomodoroWorkMinutes
+	if minutes <= 0 {
+		minutes = models.DefaultPomodoroWorkMinutes
+	}
+	return a.startTimeEntry(todoID, models.TimeEntryPomodoro, minutes)
 }
 
-// DeleteTodo deletes todo
-func (a *App) DeleteTodo(id int64) error {
-	if err := a.attachmentRepo.DeleteByTodoID(id); err != nil {
-		return err
+// StopTimer 结束正在进行的计时，没有计时时返回 nil
+func (a *App) StopTimer() (*models.TimeEntry, error) {
+	running, err := a.entryRepo.Running()
+	if err != nil || running == nil {
+		return nil, err
 	}
-	return a.todoRepo.Delete(id)
+	if _, err := a.entryRepo.Finish(running.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	a.emitTimerChanged()
+	return a.entryRepo.GetByID(running.ID)
 }
 
-// GetTodo gets single todo
-func (a *App) GetTodo(id int64) (*models.Todo, error) {
-	return a.todoRepo.GetByID(id)
+// GetRunningTimer 获取正在进行的计时，没有时返回 nil
+func (a *App) GetRunningTimer() (*models.TimeEntry, error) {
+	return a.entryRepo.Running()
 }
 
-// GetTodoList gets todo list
-func (a *App) GetTodoList(filter models.TodoFilter) (*models.TodoListResult, error) {
-	return a.todoRepo.List(filter)
+// AddTimeEntry 手动添加计时记录
+func (a *App) AddTimeEntry(entry models.TimeEntry) (int64, error) {
+	if _, err := a.todoRepo.GetByID(entry.TodoID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("待办不存在: %d", entry.TodoID)
+		}
+		return 0, err
+	}
+	if entry.StartedAt.Time.IsZero() || entry.EndedAt == nil || !entry.EndedAt.Time.After(entry.StartedAt.Time) {
+		return 0, fmt.Errorf("结束时间必须晚于开始时间")
+	}
+	entry.Kind = models.TimeEntryManual
+	entry.PlannedMinutes = 0
+	entry.Note = strings.TrimSpace(entry.Note)
+	return a.entryRepo.Create(&entry)
 }
 
-// GetPendingTodos gets pending todos
-func (a *App) GetPendingTodos() ([]models.Todo, error) {
-	return a.todoRepo.GetPendingTodos()
+// DeleteTimeEntry 删除计时记录
+func (a *App) DeleteTimeEntry(id int64) error {
+	if err := a.entryRepo.Delete(id); err != nil {
+		return err
+	}
+	a.emitTimerChanged()
+	return nil
 }
 
-// GetTodayStartRemindTodos 获取今天需要开始提醒的待办（软件启动时调用）
-func (a *App) GetTodayStartRemindTodos() ([]models.Todo, error) {
-	return a.todoRepo.GetTodayStartRemindTodos()
+// GetTimeEntries 获取待办的计时记录，最新的在前
+func (a *App) GetTimeEntries(todoID int64) ([]models.TimeEntry, error) {
+	return a.entryRepo.ListByTodoID(todoID)
 }
 
-// MarkStartRemindTriggered 标记开始提醒已触发
-func (a *App) MarkStartRemindTriggered(id int64) error {
-	return a.todoRepo.MarkStartRemindTriggered(id)
+// GetTimeTotals 获取日期范围内(包含首尾两天)按待办、类型和日期汇总的用时
+func (a *App) GetTimeTotals(startDateStr, endDateStr string) (*models.TimeTotals, error) {
+	start, err := time.ParseInLocation("2006-01-02", startDateStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date format")
+	}
+	end, err := time.ParseInLocation("2006-01-02", endDateStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date format")
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("结束日期不能早于开始日期")
+	}
+	return a.entryRepo.Totals(start, end.AddDate(0, 0, 1))
 }
 
-// GetWeekTodos gets week todos (deprecated, use GetWeekTodosNew)
-func (a *App) GetWeekTodos() (*models.WeekTodos, error) {
-	return a.todoRepo.GetWeekTodos()
+// startTimeEntry 结束正在进行的计时并开始新的计时
+func (a *App) startTimeEntry(todoID int64, kind string, plannedMinutes int) (*models.TimeEntry, error) {
+	if _, err := a.todoRepo.GetByID(todoID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("待办不存在: %d", todoID)
+		}
+		return nil, err
+	}
+	var id int64
+	err := database.RunInTx(a.db, func(tx *sql.Tx) error {
+		entryRepo := a.entryRepo.WithTx(tx)
+		now := time.Now()
+		running, err := entryRepo.Running()
+		if err != nil {
+			return err
+		}
+		if running != nil {
+			if _, err := entryRepo.Finish(running.ID, now); err != nil {
+				return err
+			}
+		}
+		id, err = entryRepo.Create(&models.TimeEntry{
+			TodoID:         todoID,
+			Kind:           kind,
+			StartedAt:      models.FlexTime{Time: now},
+			PlannedMinutes: plannedMinutes,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.emitTimerChanged()
+	return a.entryRepo.GetByID(id)
 }
 
-// GetWeekTodosNew 获取本周待办(新版)
-func (a *App) GetWeekTodosNew() (*models.WeekTodosResult, error) {
-	overdue, todos, err := a.todoRepo.GetWeekTodosNew()
+// emitTimerChanged 通知前端计时状态已改变
+func (a *App) emitTimerChanged() {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "timer:changed")
+	}
+}
+
+// ==================== Snooze API ====================
+
+// SnoozeReminder 推迟待办(循环待办为某个实例)的提醒，到时由提醒服务重新提醒
+// 同一种提醒已推迟时改为新的时间；推迟记录保存在数据库中，重启后仍然有效
+func (a *App) SnoozeReminder(req models.SnoozeRequest) (*models.ReminderSnooze, error) {
+	if !models.IsValidReminder(req.Reminder) {
+		return nil, fmt.Errorf("未知的提醒类型: %s", req.Reminder)
+	}
+	if _, err := a.todoRepo.GetOccurrence(req.TodoID, req.RepeatIndex); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("待办不存在: %d", req.TodoID)
+		}
+		return nil, err
+	}
+	fireAt, err := req.FireAt(time.Now())
 	if err != nil {
 		return nil, err
 	}
-	return &models.WeekTodosResult{
-		Overdue: overdue,
-		Todos:   todos,
-	}, nil
+	id, err := a.snoozeRepo.Save(&models.ReminderSnooze{
+		TodoID:      req.TodoID,
+		RepeatIndex: req.RepeatIndex,
+		Reminder:    req.Reminder,
+		FireAt:      models.FlexTime{Time: fireAt},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.snoozeRepo.GetByID(id)
 }
 
-// MarkTodoCompleted marks todo completed
-func (a *App) MarkTodoCompleted(id int64, completed bool) error {
-	return a.todoRepo.MarkCompleted(id, completed)
+// CancelSnooze 取消推迟的提醒
+func (a *App) CancelSnooze(todoID int64, repeatIndex int, reminder string) error {
+	return a.snoozeRepo.Delete(todoID, repeatIndex, reminder)
 }
 
-// GetTodosByDate gets todos by date
-func (a *App) GetTodosByDate(dateStr string) ([]models.Todo, error) {
-	date, err := time.Parse("2006-01-02", dateStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid date format")
+// GetSnoozedReminders 获取所有推迟的提醒，按提醒时间排列
+func (a *App) GetSnoozedReminders() ([]models.ReminderSnooze, error) {
+	return a.snoozeRepo.List()
+}
+
+// ==================== Notification History API ====================
+
+// RespondToNotification 记录用户对通知的处理结果(关闭、稍后提醒、完成、查看)，只记录第一次处理
+func (a *App) RespondToNotification(logID int64, outcome string) error {
+	if !models.IsValidOutcome(outcome) {
+		return fmt.Errorf("未知的处理结果: %s", outcome)
 	}
-	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	end := start.Add(24*time.Hour - time.Second)
-	return a.todoRepo.GetByDateRange(start, end)
+	if logID <= 0 {
+		return nil
+	}
+	_, err := a.logRepo.Respond(logID, outcome)
+	return err
 }
 
-// GetTodosByMonth gets todos by month
-func (a *App) GetTodosByMonth(year, month int) ([]models.Todo, error) {
-	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
-	end := start.AddDate(0, 1, 0).Add(-time.Second)
-	return a.todoRepo.GetByDateRange(start, end)
+// GetNotificationHistory 按待办、日期范围和处理结果获取通知记录，最新的在前
+func (a *App) GetNotificationHistory(filter models.NotificationLogFilter) ([]models.NotificationLog, error) {
+	if filter.Outcome != "" && filter.Outcome != models.OutcomeShown && !models.IsValidOutcome(filter.Outcome) {
+		return nil, fmt.Errorf("未知的处理结果: %s", filter.Outcome)
+	}
+	var start, end time.Time
+	if filter.StartDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", filter.StartDate, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start date format")
+		}
+		start = date
+	}
+	if filter.EndDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", filter.EndDate, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end date format")
+		}
+		end = date.AddDate(0, 0, 1)
+	}
+	return a.logRepo.List(filter, start, end)
 }
 
 // ==================== Calendar API ====================
 
 // GetCalendarMonth gets calendar month view data
 func (a *App) GetCalendarMonth(year, month int) ([]models.CalendarDay, error) {
+	return a.GetCalendarMonthWithFilter(year, month, models.TodoFilter{})
+}
+
+// GetCalendarMonthWithFilter 获取月视图数据，待办按筛选条件过滤
+func (a *App) GetCalendarMonthWithFilter(year, month int, filter models.TodoFilter) ([]models.CalendarDay, error) {
+	if err := filter.ValidateCustomFields(); err != nil {
+		return nil, err
+	}
 	firstDay := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
 	lastDay := firstDay.AddDate(0, 1, 0).Add(-time.Second)
 
@@ -233,25 +1609,38 @@ func (a *App) GetCalendarMonth(year, month int) ([]models.CalendarDay, error) {
 	}
 	endDate := lastDay.AddDate(0, 0, 7-endWeekday)
 
-	todos, err := a.todoRepo.GetByDateRange(startDate, endDate.Add(24*time.Hour))
+	rangeEnd := endDate.Add(24*time.Hour - time.Second)
+	todos, err := a.todoRepo.GetByDateRange(startDate, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := a.calendarRepo.HiddenIDs()
 	if err != nil {
 		return nil, err
 	}
+	todos = withoutCalendars(todos, hidden)
 
+	// 循环待办已由仓库展开为实例，按实例开始日期归入对应日期
+	// 全天待办归入跨越的每一天，并排在当天有具体时间的待办之前
 	todoMap := make(map[string][]models.Todo)
-	for _, todo := range todos {
-		// 使用 cron 表达式计算实际应该显示在哪些日期
-		cronDates := utils.GetCronDatesInRange(
-			todo.CronExpr,
-			todo.StartDate.Time,
-			todo.EndDate.Time,
-			startDate,
-			endDate,
-		)
-
-		for dateKey := range cronDates {
-			todoMap[dateKey] = append(todoMap[dateKey], todo)
+	allDayMap := make(map[string][]models.Todo)
+	for _, todo := range filterTodos(todos, filter) {
+		start := todo.StartDate.Time
+		if todo.AllDay {
+			for day := start; !day.After(todo.EndDate.Time); day = day.AddDate(0, 0, 1) {
+				if day.Before(startDate) || day.After(rangeEnd) {
+					continue
+				}
+				dateKey := day.Format("2006-01-02")
+				allDayMap[dateKey] = append(allDayMap[dateKey], todo)
+			}
+			continue
 		}
+		if start.Before(startDate) || start.After(rangeEnd) {
+			continue
+		}
+		dateKey := start.Format("2006-01-02")
+		todoMap[dateKey] = append(todoMap[dateKey], todo)
 	}
 
 	days := []models.CalendarDay{}
@@ -260,6 +1649,7 @@ func (a *App) GetCalendarMonth(year, month int) ([]models.CalendarDay, error) {
 
 	for !current.After(endDate) {
 		dateKey := current.Format("2006-01-02")
+		dayTodos := append(allDayMap[dateKey], todoMap[dateKey]...)
 		lunarDate := utils.SolarToLunar(current.Year(), int(current.Month()), current.Day())
 		_, weekNum := current.ISOWeek()
 
@@ -270,8 +1660,9 @@ func (a *App) GetCalendarMonth(year, month int) ([]models.CalendarDay, error) {
 			Lunar:          lunarDate,
 			IsToday:        current.Year() == today.Year() && current.YearDay() == today.YearDay(),
 			IsCurrentMonth: current.Month() == time.Month(month),
-			Todos:          todoMap[dateKey],
-			TodoCount:      len(todoMap[dateKey]),
+			Todos:          dayTodos,
+			TodoCount:      len(dayTodos),
+			AllDayCount:    len(allDayMap[dateKey]),
 		}
 		days = append(days, day)
 		current = current.AddDate(0, 0, 1)
@@ -290,6 +1681,118 @@ func (a *App) ConvertLunarToSolar(year, month, day int, isLeap bool) (time.Time,
 	return utils.LunarToSolar(year, month, day, isLeap)
 }
 
+// ==================== Calendars API ====================
+
+// GetCalendars 获取所有日历
+func (a *App) GetCalendars() ([]models.Calendar, error) {
+	return a.calendarRepo.List()
+}
+
+// CreateCalendar 创建日历(新建的日历始终显示)
+func (a *App) CreateCalendar(calendar models.Calendar) (int64, error) {
+	if err := a.validateCalendarType(calendar.DefaultType); err != nil {
+		return 0, err
+	}
+	calendar.IsVisible = true
+	id, err := a.calendarRepo.Create(&calendar)
+	if err != nil {
+		return 0, fmt.Errorf("创建日历失败: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateCalendar 更新日历的名称、颜色、默认类型、显示和静音状态及排序
+func (a *App) UpdateCalendar(calendar models.Calendar) error {
+	if calendar.ID <= 0 {
+		return fmt.Errorf("invalid calendar ID")
+	}
+	if err := a.validateCalendarType(calendar.DefaultType); err != nil {
+		return err
+	}
+	return a.calendarRepo.Update(&calendar)
+}
+
+// validateCalendarType 校验日历的默认类型，空表示不指定
+func (a *App) validateCalendarType(value models.TodoType) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := a.todoTypeRepo.GetByValue(value); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("未知的待办类型: %s", value)
+		}
+		return err
+	}
+	return nil
+}
+
+// SetCalendarVisible 显示或隐藏日历(隐藏后不出现在日历视图、小部件和通知中)
+func (a *App) SetCalendarVisible(id int64, visible bool) error {
+	return a.calendarRepo.SetVisible(id, visible)
+}
+
+// SetCalendarMuted 设置日历是否静音提醒(仍然显示)
+func (a *App) SetCalendarMuted(id int64, muted bool) error {
+	return a.calendarRepo.SetMuted(id, muted)
+}
+
+// DeleteCalendar 删除日历，其中的待办移到 reassignTo 日历
+func (a *App) DeleteCalendar(id, reassignTo int64) error {
+	return database.RunInTx(a.db, func(tx *sql.Tx) error {
+		return a.calendarRepo.WithTx(tx).Delete(id, reassignTo)
+	})
+}
+
+// ==================== Tag API ====================
+
+// GetTags 获取所有标签
+func (a *App) GetTags() ([]models.Tag, error) {
+	return a.tagRepo.List()
+}
+
+// CreateTag 创建标签
+func (a *App) CreateTag(tag models.Tag) (int64, error) {
+	id, err := a.tagRepo.Create(&tag)
+	if err != nil {
+		return 0, fmt.Errorf("创建标签失败: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateTag 更新标签
+func (a *App) UpdateTag(tag models.Tag) error {
+	if tag.ID <= 0 {
+		return fmt.Errorf("invalid tag ID")
+	}
+	return a.tagRepo.Update(&tag)
+}
+
+// DeleteTag 删除标签(同时移除所有待办上的该标签)
+func (a *App) DeleteTag(id int64) error {
+	return database.RunInTx(a.db, func(tx *sql.Tx) error {
+		return a.tagRepo.WithTx(tx).Delete(id)
+	})
+}
+
+// SetTodoTags 设置待办的标签
+func (a *App) SetTodoTags(todoID int64, tagIDs []int64) error {
+	tags := make([]models.Tag, len(tagIDs))
+	for i, id := range tagIDs {
+		tags[i] = models.Tag{ID: id}
+	}
+	return database.RunInTx(a.db, func(tx *sql.Tx) error {
+		return a.tagRepo.WithTx(tx).SetTodoTags(todoID, tags)
+	})
+}
+
+// GetLocalTimeZone 获取本机时区的 IANA 名称，无法确定时返回 floating
+func (a *App) GetLocalTimeZone() string {
+	if zone := utils.LocalZoneName(); zone != "" {
+		return zone
+	}
+	return models.TimeZoneFloating
+}
+
 // ==================== Cron API ====================
 
 // ParseCronExpression parses cron expression
@@ -336,7 +1839,19 @@ func (a *App) UploadAttachment(todoID int64, fileName string, dataBase64 string,
 	if err != nil {
 		return nil, fmt.Errorf("invalid file data")
 	}
-	return a.attachmentRepo.EncryptAndSaveFile(todoID, fileName, data, mimeType)
+	var attachment *models.Attachment
+	err = a.trackOperation(models.OperationAddAttachment, []int64{todoID}, func(tx *sql.Tx) ([]int64, error) {
+		var err error
+		attachment, err = a.attachmentRepo.WithTx(tx).EncryptAndSaveFile(todoID, fileName, data, mimeType)
+		return nil, err
+	})
+	if err != nil {
+		if attachment != nil {
+			os.Remove(attachment.StoragePath)
+		}
+		return nil, err
+	}
+	return attachment, nil
 }
 
 // GetAttachment gets decrypted attachment
@@ -358,9 +1873,15 @@ func (a *App) GetTodoAttachments(todoID int64) ([]models.Attachment, error) {
 	return a.attachmentRepo.GetByTodoID(todoID)
 }
 
-// DeleteAttachment deletes attachment
+// DeleteAttachment 删除附件记录，文件保留到无法再撤销时才删除
 func (a *App) DeleteAttachment(id int64) error {
-	return a.attachmentRepo.Delete(id)
+	attachment, err := a.attachmentRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	return a.trackOperation(models.OperationDeleteAttachment, []int64{attachment.TodoID}, func(tx *sql.Tx) ([]int64, error) {
+		return nil, a.attachmentRepo.WithTx(tx).DeleteRecord(id)
+	})
 }
 
 // DownloadAttachment downloads attachment to user selected location
@@ -408,6 +1929,24 @@ func (a *App) GetSettings() (*models.Settings, error) {
 
 // UpdateSettings updates settings
 func (a *App) UpdateSettings(settings models.Settings) error {
+	if settings.AllDayRemindTime == "" {
+		settings.AllDayRemindTime = models.DefaultAllDayRemindTime
+	}
+	if _, err := time.Parse("15:04", settings.AllDayRemindTime); err != nil {
+		return fmt.Errorf("无效的全天待办提醒时间: %s", settings.AllDayRemindTime)
+	}
+	if settings.AllDayRemindDaysBefore < 0 {
+		return fmt.Errorf("全天待办提前提醒天数不能为负数")
+	}
+	if settings.PomodoroWorkMinutes <= 0 {
+		settings.PomodoroWorkMinutes = models.DefaultPomodoroWorkMinutes
+	}
+	if settings.PomodoroBreakMinutes < 0 || settings.PomodoroLongBreakMinutes < 0 || settings.PomodoroLongBreakEvery < 0 {
+		return fmt.Errorf("番茄钟设置不能为负数")
+	}
+	if settings.MissedReminderGraceMinutes < 0 || settings.MissedReminderGraceMinutes > models.MaxMissedReminderGraceMinutes {
+		return fmt.Errorf("错过提醒的补发时间必须在 0 到 %d 分钟之间", models.MaxMissedReminderGraceMinutes)
+	}
 	if settings.EnableAutoStart {
 		if err := utils.EnableAutoStart(); err != nil {
 			return fmt.Errorf("failed to enable auto start: %w", err)
@@ -420,17 +1959,167 @@ func (a *App) UpdateSettings(settings models.Settings) error {
 	return a.settingsRepo.Update(&settings)
 }
 
+// ==================== Data Directory API ====================
+
+// GetDataLocation 获取数据目录信息
+func (a *App) GetDataLocation() (*models.DataLocation, error) {
+	dir, err := datadir.Dir()
+	if err != nil {
+		return nil, err
+	}
+	defaultDir, err := datadir.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	portableDir, err := datadir.PortableDir()
+	if err != nil {
+		return nil, err
+	}
+	return &models.DataLocation{
+		Dir:         dir,
+		Source:      string(datadir.CurrentSource()),
+		DefaultDir:  defaultDir,
+		PortableDir: portableDir,
+		IsPortable:  datadir.IsPortable(),
+		CanMove:     datadir.CanRelocate(),
+	}, nil
+}
+
+// ChooseDataDir 打开目录选择对话框，返回用户选择的新数据目录
+func (a *App) ChooseDataDir() (string, error) {
+	defaultDir, _ := datadir.DefaultDir()
+	return runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                "选择数据目录",
+		DefaultDirectory:     defaultDir,
+		CanCreateDirectories: true,
+	})
+}
+
+// MoveDataDir 将数据库、附件和声音迁移到新目录，重启程序后生效
+// 迁移后到重启前数据只读
+func (a *App) MoveDataDir(newDir string) error {
+	if newDir == "" {
+		return fmt.Errorf("请选择新的数据目录")
+	}
+	return database.MoveDataDir(newDir)
+}
+
 // ==================== Todo Types API ====================
 
 // GetTodoTypes returns all todo types
-func (a *App) GetTodoTypes() []map[string]string {
-	return []map[string]string{
-		{"value": "birthday", "label": "生日", "icon": "\U0001F382", "color": "#FF6B6B"},
-		{"value": "work", "label": "工作", "icon": "\U0001F4BC", "color": "#4ECDC4"},
-		{"value": "anniversary", "label": "纪念日", "icon": "\U0001F49D", "color": "#FF69B4"},
-		{"value": "reminder", "label": "提醒", "icon": "\u23F0", "color": "#FFD93D"},
-		{"value": "task", "label": "任务", "icon": "\u2705", "color": "#6BCB77"},
+func (a *App) GetTodoTypes() ([]models.TodoTypeDef, error) {
+	return a.todoTypeRepo.List()
+}
+
+// CreateTodoType 创建自定义类型
+func (a *App) CreateTodoType(todoType models.TodoTypeDef) (int64, error) {
+	id, err := a.todoTypeRepo.Create(&todoType)
+	if err != nil {
+		return 0, fmt.Errorf("创建类型失败: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateTodoType 更新类型的名称、图标、颜色、默认提醒和排序
+func (a *App) UpdateTodoType(todoType models.TodoTypeDef) error {
+	if todoType.ID <= 0 {
+		return fmt.Errorf("invalid todo type ID")
+	}
+	return a.todoTypeRepo.Update(&todoType)
+}
+
+// ReorderTodoTypes 按给定顺序排列类型
+func (a *App) ReorderTodoTypes(values []models.TodoType) error {
+	return database.RunInTx(a.db, func(tx *sql.Tx) error {
+		return a.todoTypeRepo.WithTx(tx).Reorder(values)
+	})
+}
+
+// DeleteTodoType 删除类型，使用该类型的待办改为 reassignTo 类型
+func (a *App) DeleteTodoType(value models.TodoType, reassignTo models.TodoType) error {
+	return database.RunInTx(a.db, func(tx *sql.Tx) error {
+		if err := a.todoTypeRepo.WithTx(tx).Delete(value, reassignTo); err != nil {
+			return err
+		}
+		return a.fieldRepo.WithTx(tx).DeleteByType(value)
+	})
+}
+
+// ==================== Custom Fields API ====================
+
+// GetCustomFields 获取类型的自定义字段，类型为空时返回所有字段
+func (a *App) GetCustomFields(todoType models.TodoType) ([]models.CustomFieldDef, error) {
+	if todoType == "" {
+		return a.fieldRepo.List()
+	}
+	return a.fieldRepo.ListByType(todoType)
+}
+
+// CreateCustomField 为待办类型创建自定义字段
+func (a *App) CreateCustomField(field models.CustomFieldDef) (int64, error) {
+	if _, err := a.todoTypeRepo.GetByValue(field.TodoType); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("类型不存在: %s", field.TodoType)
+		}
+		return 0, err
+	}
+	field.Key = strings.TrimSpace(field.Key)
+	if field.Key == "" {
+		return 0, fmt.Errorf("字段标识不能为空")
+	}
+	if !models.IsValidFieldType(field.FieldType) {
+		return 0, fmt.Errorf("未知的字段类型: %s", field.FieldType)
+	}
+	if err := validateCustomField(&field); err != nil {
+		return 0, err
+	}
+	id, err := a.fieldRepo.Create(&field)
+	if err != nil {
+		return 0, fmt.Errorf("创建字段失败: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateCustomField 更新自定义字段的名称、可选值、必填和排序
+func (a *App) UpdateCustomField(field models.CustomFieldDef) error {
+	existing, err := a.fieldRepo.GetByID(field.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("字段不存在: %d", field.ID)
+		}
+		return err
+	}
+	field.FieldType = existing.FieldType
+	if err := validateCustomField(&field); err != nil {
+		return err
+	}
+	return a.fieldRepo.Update(&field)
+}
+
+// DeleteCustomField 删除自定义字段及所有待办上该字段的值
+func (a *App) DeleteCustomField(id int64) error {
+	return database.RunInTx(a.db, func(tx *sql.Tx) error {
+		return a.fieldRepo.WithTx(tx).Delete(id)
+	})
+}
+
+// validateCustomField 校验字段名称和单选字段的可选值
+func validateCustomField(field *models.CustomFieldDef) error {
+	field.Label = strings.TrimSpace(field.Label)
+	if field.Label == "" {
+		return fmt.Errorf("字段名称不能为空")
 	}
+	options := []string{}
+	for _, option := range field.Options {
+		if option = strings.TrimSpace(option); option != "" {
+			options = append(options, option)
+		}
+	}
+	if field.FieldType == models.FieldTypeChoice && len(options) == 0 {
+		return fmt.Errorf("单选字段至少需要一个可选值")
+	}
+	field.Options = options
+	return nil
 }
 
 // OpenWidget 打开桌面小部件窗口
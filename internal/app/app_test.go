@@ -0,0 +1,19 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+
+	"todo-calendar/internal/database"
+)
+
+// newTestApp 创建使用临时数据库的 App
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "todo_calendar.db"))
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewApp(db)
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 批量平移起止时间，撤销后恢复原时间
+func TestBulkShiftDates(t *testing.T) {
+	a := newTestApp(t)
+	ids := []int64{createTask(t, a, "写周报"), createTask(t, a, "开会")}
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+
+	n, err := a.BulkShiftDates(models.BulkTarget{IDs: ids}, 24*60+30)
+	if err != nil {
+		t.Fatalf("批量平移失败: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("平移了 %d 个待办，应为 2 个", n)
+	}
+	for _, id := range ids {
+		todo, _ := a.GetTodo(id)
+		if want := start.AddDate(0, 0, 1).Add(30 * time.Minute); !todo.StartDate.Time.Equal(want) {
+			t.Errorf("待办 %d 平移后开始时间为 %v，应为 %v", id, todo.StartDate.Time, want)
+		}
+	}
+
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("撤销平移失败: %v", err)
+	}
+	for _, id := range ids {
+		if todo, _ := a.GetTodo(id); !todo.StartDate.Time.Equal(start) {
+			t.Errorf("撤销后待办 %d 的开始时间为 %v，应为 %v", id, todo.StartDate.Time, start)
+		}
+	}
+}
+
+// 目标中包含循环待办时报错，其他待办也不平移
+func TestBulkShiftDatesRejectsSeries(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	taskID := createTask(t, a, "写周报")
+	seriesID := createDailySeries(t, a, start, 10)
+
+	for name, target := range map[string]models.BulkTarget{
+		"指定ID": {IDs: []int64{taskID, seriesID}},
+		"筛选条件": {Filter: &models.TodoFilter{}},
+	} {
+		if n, err := a.BulkShiftDates(target, 60); err == nil {
+			t.Errorf("%s: 包含循环待办时平移应报错，实际平移了 %d 个待办", name, n)
+		}
+	}
+	if todo, _ := a.GetTodo(taskID); !todo.StartDate.Time.Equal(start) {
+		t.Errorf("平移失败后普通待办的开始时间为 %v，应保持 %v", todo.StartDate.Time, start)
+	}
+	if got := occurrence(t, a, seriesID, 1); !got.StartDate.Time.Equal(start) {
+		t.Errorf("平移失败后循环待办的开始时间为 %v，应保持 %v", got.StartDate.Time, start)
+	}
+
+	// 失败的平移不记录撤销操作，撤销的是最后一次创建
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("撤销失败: %v", err)
+	}
+	if _, err := a.GetTodo(seriesID); err == nil {
+		t.Errorf("撤销后循环待办仍然存在，失败的平移不应记录为操作")
+	}
+}
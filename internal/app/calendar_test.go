@@ -0,0 +1,52 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 隐藏日历中的待办不出现在月视图和日视图中
+func TestHiddenCalendarTodos(t *testing.T) {
+	a := newTestApp(t)
+	calendarID, err := a.CreateCalendar(models.Calendar{Name: "私人", Color: "#409eff"})
+	if err != nil {
+		t.Fatalf("创建日历失败: %v", err)
+	}
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	for _, todo := range []models.Todo{
+		{Title: "开会", Type: models.TodoTypeTask},
+		{Title: "看电影", Type: models.TodoTypeTask, CalendarID: calendarID},
+	} {
+		todo.StartDate = models.FlexTime{Time: start}
+		todo.EndDate = models.FlexTime{Time: start.Add(time.Hour)}
+		if _, err := a.CreateTodo(todo); err != nil {
+			t.Fatalf("创建待办失败: %v", err)
+		}
+	}
+	if err := a.SetCalendarVisible(calendarID, false); err != nil {
+		t.Fatalf("隐藏日历失败: %v", err)
+	}
+
+	month, err := a.GetTodosByMonth(2025, 6)
+	if err != nil {
+		t.Fatalf("获取月视图失败: %v", err)
+	}
+	day, err := a.GetTodosByDate("2025-06-02")
+	if err != nil {
+		t.Fatalf("获取日视图失败: %v", err)
+	}
+	for name, todos := range map[string][]models.Todo{"月视图": month, "日视图": day} {
+		if len(todos) != 1 || todos[0].Title != "开会" {
+			t.Errorf("%s有 %d 个待办，应只有默认日历的待办", name, len(todos))
+		}
+	}
+
+	if err := a.SetCalendarVisible(calendarID, true); err != nil {
+		t.Fatalf("显示日历失败: %v", err)
+	}
+	if month, _ := a.GetTodosByMonth(2025, 6); len(month) != 2 {
+		t.Errorf("显示日历后月视图有 %d 个待办，应为 2 个", len(month))
+	}
+}
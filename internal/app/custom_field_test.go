@@ -0,0 +1,161 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// createFields 为 work 类型创建必填数字字段 points 和单选字段 status
+func createFields(t *testing.T, a *App) {
+	t.Helper()
+	for _, field := range []models.CustomFieldDef{
+		{TodoType: models.TodoTypeWork, Key: "points", Label: "点数", FieldType: models.FieldTypeNumber, Required: true},
+		{TodoType: models.TodoTypeWork, Key: "status", Label: "状态", FieldType: models.FieldTypeChoice, Options: []string{"进行中", "已阻塞"}},
+		{TodoType: models.TodoTypeWork, Key: "due", Label: "截止日期", FieldType: models.FieldTypeDate},
+		{TodoType: models.TodoTypeWork, Key: "link", Label: "链接", FieldType: models.FieldTypeURL},
+	} {
+		if _, err := a.CreateCustomField(field); err != nil {
+			t.Fatalf("创建字段 %s 失败: %v", field.Key, err)
+		}
+	}
+}
+
+// createWork 创建带自定义字段值的工作待办
+func createWork(t *testing.T, a *App, values map[string]string) (int64, error) {
+	t.Helper()
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	return a.CreateTodo(models.Todo{
+		Title:        "需求开发",
+		Type:         models.TodoTypeWork,
+		CustomFields: values,
+		StartDate:    models.FlexTime{Time: start},
+		EndDate:      models.FlexTime{Time: start.Add(time.Hour)},
+	})
+}
+
+// 字段定义需指定已有类型、唯一标识、名称和有效的字段类型
+func TestCustomFieldDefinitionValidation(t *testing.T) {
+	a := newTestApp(t)
+	createFields(t, a)
+	tests := map[string]models.CustomFieldDef{
+		"类型不存在":  {TodoType: "nope", Key: "a", Label: "A", FieldType: models.FieldTypeText},
+		"标识为空":   {TodoType: models.TodoTypeWork, Key: " ", Label: "A", FieldType: models.FieldTypeText},
+		"名称为空":   {TodoType: models.TodoTypeWork, Key: "a", Label: " ", FieldType: models.FieldTypeText},
+		"未知字段类型": {TodoType: models.TodoTypeWork, Key: "a", Label: "A", FieldType: "color"},
+		"单选无可选值": {TodoType: models.TodoTypeWork, Key: "a", Label: "A", FieldType: models.FieldTypeChoice, Options: []string{" "}},
+		"标识重复":   {TodoType: models.TodoTypeWork, Key: "points", Label: "A", FieldType: models.FieldTypeText},
+	}
+	for name, field := range tests {
+		if _, err := a.CreateCustomField(field); err == nil {
+			t.Errorf("%s: 创建字段应报错", name)
+		}
+	}
+	if err := a.UpdateCustomField(models.CustomFieldDef{ID: 999, Label: "A"}); err == nil {
+		t.Errorf("修改不存在的字段应报错")
+	}
+}
+
+// 字段值按字段类型校验并转换为存储格式，必填字段不能为空
+func TestCustomFieldValues(t *testing.T) {
+	a := newTestApp(t)
+	createFields(t, a)
+	invalid := map[string]map[string]string{
+		"缺少必填字段": {"status": "进行中"},
+		"数字无效":   {"points": "abc"},
+		"不是可选值":  {"points": "3", "status": "已完成"},
+		"日期无效":   {"points": "3", "due": "2025/06/02"},
+		"链接无效":   {"points": "3", "link": "javascript:alert(1)"},
+		"未知字段":   {"points": "3", "owner": "张三"},
+	}
+	for name, values := range invalid {
+		if _, err := createWork(t, a, values); err == nil {
+			t.Errorf("%s: 创建待办应报错", name)
+		}
+	}
+
+	id, err := createWork(t, a, map[string]string{"points": " 05 ", "due": "2025-06-30", "link": ""})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	todo, _ := a.GetTodo(id)
+	if len(todo.CustomFields) != 2 || todo.CustomFields["points"] != "5" || todo.CustomFields["due"] != "2025-06-30" {
+		t.Errorf("字段值为 %v，应为 points=5 due=2025-06-30", todo.CustomFields)
+	}
+
+	// 未传入字段值时保留原值，改为其他类型后清空
+	todo.CustomFields = nil
+	todo.Title = "需求评审"
+	if err := a.UpdateTodo(*todo); err != nil {
+		t.Fatalf("修改待办失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); todo.CustomFields["points"] != "5" {
+		t.Errorf("修改后字段值为 %v，应保留原值", todo.CustomFields)
+	}
+	todo.Type = models.TodoTypeTask
+	if err := a.UpdateTodo(*todo); err != nil {
+		t.Fatalf("修改类型失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); len(todo.CustomFields) != 0 {
+		t.Errorf("改为其他类型后字段值为 %v，应清空", todo.CustomFields)
+	}
+}
+
+// 按自定义字段筛选：数字字段按数值比较，无效的筛选条件报错
+func TestCustomFieldFilter(t *testing.T) {
+	a := newTestApp(t)
+	createFields(t, a)
+	for _, values := range []map[string]string{
+		{"points": "5", "status": "进行中"},
+		{"points": "12"},
+		{"points": "8", "status": "已阻塞"},
+	} {
+		if _, err := createWork(t, a, values); err != nil {
+			t.Fatalf("创建待办失败: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter models.CustomFieldFilter
+		want   int
+	}{
+		{"大于", models.CustomFieldFilter{Key: "points", Op: models.FieldOpGt, Value: "6"}, 2},
+		{"小于等于", models.CustomFieldFilter{Key: "points", Op: models.FieldOpLte, Value: "5"}, 1},
+		{"等于", models.CustomFieldFilter{Key: "status", Value: "已阻塞"}, 1},
+		{"包含", models.CustomFieldFilter{Key: "status", Op: models.FieldOpContains, Value: "进行"}, 1},
+		{"有值", models.CustomFieldFilter{Key: "status", Op: models.FieldOpExists}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := models.TodoFilter{CustomFields: []models.CustomFieldFilter{tt.filter}}
+			list, err := a.GetTodoList(filter)
+			if err != nil {
+				t.Fatalf("查询待办列表失败: %v", err)
+			}
+			if list.Total != int64(tt.want) {
+				t.Errorf("待办列表有 %d 个待办，应为 %d 个", list.Total, tt.want)
+			}
+			day, err := a.GetTodosByDateWithFilter("2025-06-02", filter)
+			if err != nil {
+				t.Fatalf("查询日视图失败: %v", err)
+			}
+			if len(day) != tt.want {
+				t.Errorf("日视图有 %d 个待办，应为 %d 个", len(day), tt.want)
+			}
+		})
+	}
+
+	for name, filter := range map[string]models.CustomFieldFilter{
+		"缺少字段标识": {Op: models.FieldOpExists},
+		"无效比较方式": {Key: "points", Op: "like", Value: "5"},
+	} {
+		if _, err := a.GetTodoList(models.TodoFilter{CustomFields: []models.CustomFieldFilter{filter}}); err == nil {
+			t.Errorf("%s: 查询待办列表应报错", name)
+		}
+		if _, err := a.GetTodosByDateWithFilter("2025-06-02", models.TodoFilter{CustomFields: []models.CustomFieldFilter{filter}}); err == nil {
+			t.Errorf("%s: 查询日视图应报错", name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+// 前置待办完成前后续待办被阻塞，不能形成循环依赖
+func TestDependencyBlocksUntilCompleted(t *testing.T) {
+	a := newTestApp(t)
+	design := createTask(t, a, "设计")
+	build := createTask(t, a, "开发")
+	if err := a.AddTodoDependency(build, design); err != nil {
+		t.Fatalf("添加依赖失败: %v", err)
+	}
+	if err := a.AddTodoDependency(design, build); err == nil {
+		t.Errorf("形成循环依赖时应报错")
+	}
+
+	if todo, _ := a.GetTodo(build); !todo.IsBlocked {
+		t.Errorf("前置待办未完成时后续待办应被阻塞")
+	}
+	if err := a.MarkTodoCompleted(design, true); err != nil {
+		t.Fatalf("标记完成失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(build); todo.IsBlocked {
+		t.Errorf("前置待办完成后后续待办仍被阻塞")
+	}
+}
+
+// 循环待办不能作为前置待办，但可以依赖其他待办；已有的此类依赖不阻塞
+func TestSeriesAsBlocker(t *testing.T) {
+	a := newTestApp(t)
+	seriesID := createDailySeries(t, a, time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local), 3)
+	review := createTask(t, a, "复盘")
+
+	if err := a.AddTodoDependency(review, seriesID); err == nil {
+		t.Errorf("循环待办作为前置待办时应报错")
+	}
+	if err := a.AddTodoDependency(seriesID, review); err != nil {
+		t.Errorf("循环待办依赖普通待办失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(seriesID); !todo.IsBlocked {
+		t.Errorf("循环待办的前置待办未完成时应被阻塞")
+	}
+
+	other := createTask(t, a, "总结")
+	if _, err := a.db.Exec("INSERT INTO todo_dependencies (todo_id, depends_on_id, created_at) VALUES (?, ?, ?)", other, seriesID, time.Now()); err != nil {
+		t.Fatalf("写入依赖失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(other); todo.IsBlocked {
+		t.Errorf("以循环待办为前置的待办不应被永久阻塞")
+	}
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// createMeeting 创建带会议信息的待办
+func createMeeting(t *testing.T, a *App, meetingURL string, attendees []models.Attendee) (int64, error) {
+	t.Helper()
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	return a.CreateTodo(models.Todo{
+		Title:      "评审会",
+		Type:       models.TodoTypeWork,
+		Location:   " 会议室A305 ",
+		MeetingURL: meetingURL,
+		Attendees:  attendees,
+		StartDate:  models.FlexTime{Time: start},
+		EndDate:    models.FlexTime{Time: start.Add(time.Hour)},
+	})
+}
+
+// 会议链接只允许 http/https，参会人邮箱需有效
+func TestMeetingValidation(t *testing.T) {
+	a := newTestApp(t)
+	tests := []struct {
+		name       string
+		meetingURL string
+		attendees  []models.Attendee
+		wantErr    bool
+	}{
+		{"https 链接", "https://meet.example.com/abc", nil, false},
+		{"无链接", "", nil, false},
+		{"javascript 链接", "javascript:alert(1)", nil, true},
+		{"ftp 链接", "ftp://example.com/a", nil, true},
+		{"缺少主机", "https://", nil, true},
+		{"无效邮箱", "", []models.Attendee{{Name: "张三", Email: "zhangsan"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createMeeting(t, a, tt.meetingURL, tt.attendees)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("创建待办的错误为 %v，是否应报错: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 保存时去掉首尾空格和空白参会人；修改时参会人为 nil 保持不变
+func TestMeetingDetailsSaved(t *testing.T) {
+	a := newTestApp(t)
+	id, err := createMeeting(t, a, " https://meet.example.com/abc ", []models.Attendee{
+		{Name: " 张三 ", Email: "zhangsan@example.com"},
+		{Name: " ", Email: ""},
+		{Name: "Bob"},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	todo, _ := a.GetTodo(id)
+	if todo.Location != "会议室A305" || todo.MeetingURL != "https://meet.example.com/abc" {
+		t.Errorf("地点为 %q，会议链接为 %q，应去掉首尾空格", todo.Location, todo.MeetingURL)
+	}
+	if len(todo.Attendees) != 2 || todo.Attendees[0].Name != "张三" {
+		t.Errorf("参会人为 %v，应去掉空白参会人", todo.Attendees)
+	}
+
+	todo.Attendees = nil
+	todo.Location = "会议室B"
+	if err := a.UpdateTodo(*todo); err != nil {
+		t.Fatalf("修改待办失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); len(todo.Attendees) != 2 || todo.Location != "会议室B" {
+		t.Errorf("修改后地点为 %q，参会人为 %v，参会人应保持不变", todo.Location, todo.Attendees)
+	}
+
+	if err := a.OpenMeetingLink(createTask(t, a, "写周报")); err == nil {
+		t.Errorf("打开没有会议链接的待办应报错")
+	}
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// addLog 添加一条在 notifiedAt 发送的通知记录
+func addLog(t *testing.T, a *App, todoID int64, notifiedAt time.Time) int64 {
+	t.Helper()
+	id, err := a.logRepo.Create(&models.NotificationLog{TodoID: todoID, Reminder: models.ReminderStart, Title: "⏰开始: 提醒"})
+	if err != nil {
+		t.Fatalf("添加通知记录失败: %v", err)
+	}
+	if _, err := a.db.Exec("UPDATE notification_logs SET notified_at = ? WHERE id = ?", notifiedAt, id); err != nil {
+		t.Fatalf("设置通知时间失败: %v", err)
+	}
+	return id
+}
+
+// logIDs 返回通知记录的ID
+func logIDs(logs []models.NotificationLog) []int64 {
+	ids := make([]int64, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+	return ids
+}
+
+// 通知记录按待办、日期范围和处理结果筛选，最新的在前
+func TestNotificationHistory(t *testing.T) {
+	a := newTestApp(t)
+	first := createTask(t, a, "写周报")
+	second := createTask(t, a, "开会")
+	day := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	early := addLog(t, a, first, day)
+	late := addLog(t, a, first, day.AddDate(0, 0, 1))
+	other := addLog(t, a, second, day.Add(time.Hour))
+	if err := a.RespondToNotification(early, models.OutcomeSnoozed); err != nil {
+		t.Fatalf("记录处理结果失败: %v", err)
+	}
+	// 只记录第一次处理
+	if err := a.RespondToNotification(early, models.OutcomeDismissed); err != nil {
+		t.Fatalf("记录处理结果失败: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		filter models.NotificationLogFilter
+		want   []int64
+	}{
+		"全部":    {models.NotificationLogFilter{}, []int64{late, other, early}},
+		"按待办":   {models.NotificationLogFilter{TodoID: first}, []int64{late, early}},
+		"按日期":   {models.NotificationLogFilter{StartDate: "2025-06-02", EndDate: "2025-06-02"}, []int64{other, early}},
+		"按处理结果": {models.NotificationLogFilter{Outcome: models.OutcomeSnoozed}, []int64{early}},
+		"未处理":   {models.NotificationLogFilter{Outcome: models.OutcomeShown}, []int64{late, other}},
+		"限制条数":  {models.NotificationLogFilter{Limit: 1}, []int64{late}},
+	} {
+		logs, err := a.GetNotificationHistory(tc.filter)
+		if err != nil {
+			t.Fatalf("%s: 获取通知记录失败: %v", name, err)
+		}
+		if !slices.Equal(logIDs(logs), tc.want) {
+			t.Errorf("%s: 通知记录为 %v，应为 %v", name, logIDs(logs), tc.want)
+		}
+	}
+
+	logs, _ := a.GetNotificationHistory(models.NotificationLogFilter{TodoID: first, Outcome: models.OutcomeSnoozed})
+	if len(logs) != 1 || logs[0].RespondedAt == nil {
+		t.Errorf("已处理的通知记录为 %+v，应记录处理时间", logs)
+	}
+}
+
+// 处理结果或日期无效时报错
+func TestNotificationHistoryValidation(t *testing.T) {
+	a := newTestApp(t)
+	id := addLog(t, a, createTask(t, a, "写周报"), time.Now())
+
+	if err := a.RespondToNotification(id, models.OutcomeShown); err == nil {
+		t.Errorf("处理结果为 shown 时应报错")
+	}
+	if err := a.RespondToNotification(id, "bogus"); err == nil {
+		t.Errorf("未知的处理结果应报错")
+	}
+	for name, filter := range map[string]models.NotificationLogFilter{
+		"未知处理结果": {Outcome: "bogus"},
+		"开始日期无效": {StartDate: "2025/06/02"},
+		"结束日期无效": {EndDate: "tomorrow"},
+	} {
+		if _, err := a.GetNotificationHistory(filter); err == nil {
+			t.Errorf("%s: 获取通知记录应报错", name)
+		}
+	}
+	if logs, _ := a.GetNotificationHistory(models.NotificationLogFilter{}); len(logs) != 1 || logs[0].Outcome != models.OutcomeShown {
+		t.Errorf("通知记录为 %+v，无效的处理结果不应写入", logs)
+	}
+}
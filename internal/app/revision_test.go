@@ -0,0 +1,87 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 恢复修改历史按正常修改流程保存，可以撤销，并校验恢复后的内容
+func TestRestoreTodoRevision(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:     "草稿",
+		Type:      models.TodoTypeTask,
+		StartDate: models.FlexTime{Time: start},
+		EndDate:   models.FlexTime{Time: start.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	for _, title := range []string{"初稿", "终稿"} {
+		todo, _ := a.GetTodo(id)
+		todo.Title = title
+		if err := a.UpdateTodo(*todo); err != nil {
+			t.Fatalf("修改待办失败: %v", err)
+		}
+	}
+
+	revisions, err := a.GetTodoRevisions(id)
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("修改历史有 %d 条 (%v)，应为 2 条", len(revisions), err)
+	}
+	// 恢复到第一次修改之前
+	if err := a.RestoreTodoRevision(revisions[1].ID); err != nil {
+		t.Fatalf("恢复修改历史失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); todo.Title != "草稿" {
+		t.Errorf("恢复后标题为 %q，应为 草稿", todo.Title)
+	}
+
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("撤销恢复失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); todo.Title != "终稿" {
+		t.Errorf("撤销恢复后标题为 %q，应为 终稿", todo.Title)
+	}
+}
+
+// 恢复到的类型已被删除时拒绝恢复
+func TestRestoreTodoRevisionValidates(t *testing.T) {
+	a := newTestApp(t)
+	if _, err := a.CreateTodoType(models.TodoTypeDef{Value: "study", Label: "学习"}); err != nil {
+		t.Fatalf("创建类型失败: %v", err)
+	}
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:     "读书",
+		Type:      "study",
+		StartDate: models.FlexTime{Time: start},
+		EndDate:   models.FlexTime{Time: start.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	todo, _ := a.GetTodo(id)
+	todo.Type = models.TodoTypeTask
+	todo.CustomFields = nil
+	if err := a.UpdateTodo(*todo); err != nil {
+		t.Fatalf("修改待办失败: %v", err)
+	}
+	if err := a.DeleteTodoType("study", models.TodoTypeTask); err != nil {
+		t.Fatalf("删除类型失败: %v", err)
+	}
+
+	revisions, _ := a.GetTodoRevisions(id)
+	if len(revisions) == 0 {
+		t.Fatalf("没有修改历史")
+	}
+	if err := a.RestoreTodoRevision(revisions[0].ID); err == nil {
+		t.Errorf("恢复到已删除的类型应失败")
+	}
+	if todo, _ := a.GetTodo(id); todo.Type != models.TodoTypeTask {
+		t.Errorf("恢复失败后类型为 %q，应保持 task", todo.Type)
+	}
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// createDailySeries 创建从 start 开始每天9点的循环待办
+func createDailySeries(t *testing.T, a *App, start time.Time, count int) int64 {
+	t.Helper()
+	id, err := a.CreateTodo(models.Todo{
+		Title:       "周报",
+		Type:        "work",
+		RepeatType:  models.RepeatTypeDaily,
+		CronExpr:    "0 9 * * *",
+		RepeatCount: count,
+		StartDate:   models.FlexTime{Time: start},
+		EndDate:     models.FlexTime{Time: start.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建循环待办失败: %v", err)
+	}
+	return id
+}
+
+// occurrence 获取系列的第 index 个实例
+func occurrence(t *testing.T, a *App, id int64, index int) *models.Todo {
+	t.Helper()
+	todo, err := a.todoRepo.GetOccurrence(id, index)
+	if err != nil {
+		t.Fatalf("获取实例 %d 失败: %v", index, err)
+	}
+	return todo
+}
+
+func TestUpdateSingleOccurrence(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id := createDailySeries(t, a, start, 10)
+
+	edited := *occurrence(t, a, id, 3)
+	edited.Title = "周报(改期)"
+	edited.StartDate = models.FlexTime{Time: start.AddDate(0, 0, 20)}
+	edited.EndDate = models.FlexTime{Time: start.AddDate(0, 0, 20).Add(time.Hour)}
+	if _, err := a.UpdateTodoWithScope(edited, models.EditScopeSingle); err != nil {
+		t.Fatalf("修改单个实例失败: %v", err)
+	}
+
+	if got := occurrence(t, a, id, 3).Title; got != "周报(改期)" {
+		t.Errorf("实例 3 的标题为 %q", got)
+	}
+	for _, index := range []int{2, 4} {
+		if got := occurrence(t, a, id, index).Title; got != "周报" {
+			t.Errorf("实例 %d 的标题被改为 %q", index, got)
+		}
+	}
+
+	// 改期到系列结束之后的实例出现在新的日期，原日期不再显示
+	day := func(d time.Time) []models.Todo {
+		dayStart := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.Local)
+		return seriesOccurrences(t, a, id, dayStart, dayStart.AddDate(0, 0, 1).Add(-time.Second))
+	}
+	if moved := day(start.AddDate(0, 0, 20)); len(moved) != 1 || moved[0].RepeatIndex != 3 {
+		t.Errorf("改期后的日期有实例 %v，应为实例 3", moved)
+	}
+	if original := day(start.AddDate(0, 0, 2)); len(original) != 0 {
+		t.Errorf("原日期仍有 %d 个实例", len(original))
+	}
+}
+
+func TestUpdateFollowingSplitsSeries(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id := createDailySeries(t, a, start, 10)
+
+	edited := *occurrence(t, a, id, 5)
+	edited.Title = "月报"
+	newID, err := a.UpdateTodoWithScope(edited, models.EditScopeFollowing)
+	if err != nil {
+		t.Fatalf("修改此实例及之后失败: %v", err)
+	}
+	if newID == id {
+		t.Fatalf("拆分后应返回新系列的ID")
+	}
+
+	// 原系列截断为前4个实例
+	if got := occurrence(t, a, id, 4); got.RepeatTotal != 4 || got.Title != "周报" {
+		t.Errorf("原系列实例 4 为 %q %d/%d，应为 周报 4/4", got.Title, got.RepeatIndex, got.RepeatTotal)
+	}
+	if _, err := a.todoRepo.GetOccurrence(id, 5); err == nil {
+		t.Errorf("原系列截断后不应还有实例 5")
+	}
+
+	// 新系列从原实例5的时间开始，保留剩余的6次
+	first := occurrence(t, a, newID, 1)
+	if first.Title != "月报" || !first.StartDate.Time.Equal(start.AddDate(0, 0, 4)) {
+		t.Errorf("新系列第一个实例为 %q %v", first.Title, first.StartDate.Time)
+	}
+	if first.RepeatTotal != 6 {
+		t.Errorf("新系列共 %d 次，应为 6 次", first.RepeatTotal)
+	}
+}
+
+func TestUpdateAllOccurrences(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id := createDailySeries(t, a, start, 0)
+
+	edited := *occurrence(t, a, id, 7)
+	edited.Title = "日报"
+	if _, err := a.UpdateTodoWithScope(edited, models.EditScopeAll); err != nil {
+		t.Fatalf("修改整个系列失败: %v", err)
+	}
+	for _, index := range []int{1, 7, 30} {
+		if got := occurrence(t, a, id, index); got.Title != "日报" || !got.StartDate.Time.Equal(start.AddDate(0, 0, index-1)) {
+			t.Errorf("实例 %d 为 %q %v", index, got.Title, got.StartDate.Time)
+		}
+	}
+}
+
+func TestDeleteWithScope(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id := createDailySeries(t, a, start, 10)
+	rangeEnd := start.AddDate(0, 0, 30)
+
+	if err := a.DeleteTodoWithScope(id, 2, models.EditScopeSingle); err != nil {
+		t.Fatalf("删除单个实例失败: %v", err)
+	}
+	if got := seriesOccurrences(t, a, id, start, rangeEnd); len(got) != 9 {
+		t.Errorf("删除单个实例后有 %d 个实例，应为 9 个", len(got))
+	}
+
+	if err := a.DeleteTodoWithScope(id, 4, models.EditScopeFollowing); err != nil {
+		t.Fatalf("删除此实例及之后失败: %v", err)
+	}
+	got := seriesOccurrences(t, a, id, start, rangeEnd)
+	if len(got) != 2 || got[0].RepeatIndex != 1 || got[1].RepeatIndex != 3 {
+		t.Errorf("截断后的实例为 %v，应为实例 1 和 3", got)
+	}
+	if got[0].RepeatTotal != 3 {
+		t.Errorf("截断后共 %d 次，应为 3 次", got[0].RepeatTotal)
+	}
+
+	if err := a.DeleteTodoWithScope(id, 1, models.EditScopeAll); err != nil {
+		t.Fatalf("删除整个系列失败: %v", err)
+	}
+	if got := seriesOccurrences(t, a, id, start, rangeEnd); len(got) != 0 {
+		t.Errorf("删除整个系列后仍有 %d 个实例", len(got))
+	}
+}
+
+// 修改循环规则后，实例的完成状态按原计划时间对应到新规则的实例
+func TestRuleChangeRemapsInstances(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+
+	// 整个系列：每天改为每周一，6月4日的完成状态不应落到新的第3个实例(6月16日)上
+	id := createDailySeries(t, a, start, 10)
+	for _, index := range []int{3, 8} {
+		if err := a.MarkOccurrenceCompleted(id, index, true); err != nil {
+			t.Fatalf("标记实例 %d 完成失败: %v", index, err)
+		}
+	}
+	edited := *occurrence(t, a, id, 1)
+	edited.CronExpr = "0 9 * * 1"
+	edited.RepeatType = models.RepeatTypeWeekly
+	if _, err := a.UpdateTodoWithScope(edited, models.EditScopeAll); err != nil {
+		t.Fatalf("修改整个系列失败: %v", err)
+	}
+	if got := occurrence(t, a, id, 3); got.IsCompleted {
+		t.Errorf("新的实例 3 (%v) 不应为已完成", got.StartDate.Time)
+	}
+	if got := occurrence(t, a, id, 2); !got.IsCompleted || !got.StartDate.Time.Equal(start.AddDate(0, 0, 7)) {
+		t.Errorf("实例 2 为 %v (完成 %v)，6月9日的完成状态应保留", got.StartDate.Time, got.IsCompleted)
+	}
+
+	// 此实例及之后：拆分出的系列改为每周一、三，只保留仍在新规则中的完成状态
+	id = createDailySeries(t, a, start, 30)
+	for _, index := range []int{12, 15} {
+		if err := a.MarkOccurrenceCompleted(id, index, true); err != nil {
+			t.Fatalf("标记实例 %d 完成失败: %v", index, err)
+		}
+	}
+	edited = *occurrence(t, a, id, 10)
+	edited.CronExpr = "0 9 * * 1,3"
+	edited.RepeatType = models.RepeatTypeWeekly
+	newID, err := a.UpdateTodoWithScope(edited, models.EditScopeFollowing)
+	if err != nil {
+		t.Fatalf("修改此实例及之后失败: %v", err)
+	}
+	for index, want := range map[int]bool{1: false, 2: true, 3: false, 6: false} {
+		if got := occurrence(t, a, newID, index); got.IsCompleted != want {
+			t.Errorf("新系列实例 %d (%v) 完成状态为 %v，应为 %v", index, got.StartDate.Time, got.IsCompleted, want)
+		}
+	}
+}
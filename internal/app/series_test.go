@@ -0,0 +1,171 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// seriesOccurrences 获取范围内指定系列的实例
+func seriesOccurrences(t *testing.T, a *App, id int64, start, end time.Time) []models.Todo {
+	t.Helper()
+	todos, err := a.todoRepo.GetByDateRange(start, end)
+	if err != nil {
+		t.Fatalf("查询实例失败: %v", err)
+	}
+	var occurrences []models.Todo
+	for _, todo := range todos {
+		if todo.ID == id {
+			occurrences = append(occurrences, todo)
+		}
+	}
+	return occurrences
+}
+
+// 循环待办只保存一条系列记录，实例按需计算
+func TestCreateSeriesExpandsOnDemand(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:      "站会",
+		Type:       "work",
+		RepeatType: models.RepeatTypeDaily,
+		CronExpr:   "0 9 * * *",
+		StartDate:  models.FlexTime{Time: start},
+		EndDate:    models.FlexTime{Time: start.Add(15 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("创建循环待办失败: %v", err)
+	}
+
+	var rows int
+	a.db.QueryRow("SELECT COUNT(*) FROM todos").Scan(&rows)
+	if rows != 1 {
+		t.Errorf("数据库中有 %d 条待办，应只有系列记录", rows)
+	}
+
+	weekStart := time.Date(2025, 6, 9, 0, 0, 0, 0, time.Local)
+	occurrences := seriesOccurrences(t, a, id, weekStart, weekStart.AddDate(0, 0, 7).Add(-time.Second))
+	if len(occurrences) != 7 {
+		t.Fatalf("一周内有 %d 个实例，应为 7 个", len(occurrences))
+	}
+	for i, occurrence := range occurrences {
+		if occurrence.RepeatIndex != 8+i {
+			t.Errorf("第 %d 个实例序号为 %d，应为 %d", i, occurrence.RepeatIndex, 8+i)
+		}
+		if want := weekStart.AddDate(0, 0, i).Add(9 * time.Hour); !occurrence.StartDate.Time.Equal(want) {
+			t.Errorf("实例 %d 开始于 %v，应为 %v", occurrence.RepeatIndex, occurrence.StartDate.Time, want)
+		}
+		if occurrence.EndDate.Time.Sub(occurrence.StartDate.Time) != 15*time.Minute {
+			t.Errorf("实例 %d 的持续时间为 %v，应为 15 分钟", occurrence.RepeatIndex, occurrence.EndDate.Time.Sub(occurrence.StartDate.Time))
+		}
+	}
+}
+
+// 长期运行的高频系列超过展开上限后，当前的实例和序号仍然正确
+func TestLongRunningSeriesReachesCurrentWindow(t *testing.T) {
+	a := newTestApp(t)
+	now := time.Now()
+	start := time.Date(now.Year()-2, now.Month(), 1, 0, 0, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:      "每小时喝水",
+		Type:       "reminder",
+		RepeatType: models.RepeatTypeCustom,
+		CronExpr:   "0 * * * *",
+		StartDate:  models.FlexTime{Time: start},
+		EndDate:    models.FlexTime{Time: start.Add(5 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("创建循环待办失败: %v", err)
+	}
+
+	hourStart := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, time.Local)
+	occurrences := seriesOccurrences(t, a, id, hourStart.Add(-2*time.Hour), hourStart.Add(time.Hour-time.Second))
+	if len(occurrences) != 3 {
+		t.Fatalf("最近三小时有 %d 个实例，应为 3 个", len(occurrences))
+	}
+	last := occurrences[len(occurrences)-1]
+	if !last.StartDate.Time.Equal(hourStart) {
+		t.Errorf("最后一个实例开始于 %v，应为 %v", last.StartDate.Time, hourStart)
+	}
+	if last.RepeatIndex <= 10000 {
+		t.Errorf("两年的每小时系列实例序号为 %d，应超过展开上限", last.RepeatIndex)
+	}
+
+	// 按序号取回的实例与展开的结果一致
+	occurrence, err := a.todoRepo.GetOccurrence(id, last.RepeatIndex)
+	if err != nil {
+		t.Fatalf("获取实例失败: %v", err)
+	}
+	if !occurrence.StartDate.Time.Equal(last.StartDate.Time) {
+		t.Errorf("第 %d 个实例开始于 %v，展开结果为 %v", last.RepeatIndex, occurrence.StartDate.Time, last.StartDate.Time)
+	}
+}
+
+// 长期运行的高频系列：逾期只列出最近几个实例，本周实例完整
+func TestWeekTodosLongRunningSeries(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Now().AddDate(-2, 0, 0).Truncate(time.Hour)
+	id, err := a.CreateTodo(models.Todo{
+		Title:      "巡检",
+		Type:       models.TodoTypeTask,
+		RepeatType: models.RepeatTypeCustom,
+		CronExpr:   "0 * * * *",
+		StartDate:  models.FlexTime{Time: start},
+		EndDate:    models.FlexTime{Time: start.Add(30 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("创建循环待办失败: %v", err)
+	}
+
+	week, err := a.GetWeekTodosNew()
+	if err != nil {
+		t.Fatalf("获取本周待办失败: %v", err)
+	}
+	if len(week.Overdue) == 0 || len(week.Overdue) > 5 {
+		t.Errorf("逾期有 %d 个实例，应为 1 到 5 个", len(week.Overdue))
+	}
+	// 一周 168 小时，跨夏令时切换时相差一小时
+	if len(week.Todos) < 167 {
+		t.Errorf("本周有 %d 个实例，应约为 168 个", len(week.Todos))
+	}
+	for _, todo := range append(week.Overdue, week.Todos...) {
+		if todo.ID != id {
+			t.Errorf("出现了其他待办 %d", todo.ID)
+		}
+	}
+
+	legacy, err := a.GetWeekTodos()
+	if err != nil {
+		t.Fatalf("获取本周待办失败: %v", err)
+	}
+	if len(legacy.Overdue) == 0 || len(legacy.Overdue) > 5 {
+		t.Errorf("旧版接口逾期有 %d 个实例，应为 1 到 5 个", len(legacy.Overdue))
+	}
+}
+
+// 按记录修改和标记完成的接口拒绝循环系列，避免一次实例的操作影响整个系列
+func TestSeriesRejectsRecordAPIs(t *testing.T) {
+	a := newTestApp(t)
+	id := createDailySeries(t, a, time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local), 10)
+
+	if err := a.MarkTodoCompleted(id, true); err == nil {
+		t.Errorf("标记循环系列完成应失败")
+	}
+	todo := occurrence(t, a, id, 3)
+	todo.Title = "改期"
+	if err := a.UpdateTodo(*todo); err == nil {
+		t.Errorf("不指定范围修改循环系列应失败")
+	}
+
+	if err := a.MarkOccurrenceCompleted(id, 3, true); err != nil {
+		t.Fatalf("标记实例完成失败: %v", err)
+	}
+	if !occurrence(t, a, id, 3).IsCompleted || occurrence(t, a, id, 4).IsCompleted {
+		t.Errorf("只有第 3 个实例应完成")
+	}
+	if series, _ := a.GetTodo(id); series.IsCompleted {
+		t.Errorf("标记实例完成后系列不应完成")
+	}
+}
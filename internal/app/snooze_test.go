@@ -0,0 +1,74 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 推迟提醒按推迟方式计算提醒时间，同一种提醒再次推迟时改为新的时间
+func TestSnoozeReminder(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "写周报")
+	seriesID := createDailySeries(t, a, time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local), 3)
+
+	before := time.Now()
+	snooze, err := a.SnoozeReminder(models.SnoozeRequest{TodoID: id, Reminder: models.ReminderStart, Option: models.Snooze10Minutes})
+	if err != nil {
+		t.Fatalf("推迟提醒失败: %v", err)
+	}
+	if fireAt := snooze.FireAt.Time; fireAt.Before(before.Add(10*time.Minute)) || fireAt.After(time.Now().Add(10*time.Minute)) {
+		t.Errorf("推迟10分钟后的提醒时间为 %v", fireAt)
+	}
+	snooze, err = a.SnoozeReminder(models.SnoozeRequest{TodoID: id, Reminder: models.ReminderStart, Option: models.SnoozeTomorrow})
+	if err != nil {
+		t.Fatalf("推迟到明天失败: %v", err)
+	}
+	now := time.Now()
+	if want := time.Date(now.Year(), now.Month(), now.Day()+1, 9, 0, 0, 0, time.Local); !snooze.FireAt.Time.Equal(want) {
+		t.Errorf("推迟到明天的提醒时间为 %v，应为 %v", snooze.FireAt.Time, want)
+	}
+	until := now.Add(3 * time.Hour).Truncate(time.Second)
+	if _, err := a.SnoozeReminder(models.SnoozeRequest{TodoID: seriesID, RepeatIndex: 2, Reminder: models.ReminderAdvance, Option: models.SnoozeCustom, Until: models.FlexTime{Time: until}}); err != nil {
+		t.Fatalf("推迟循环实例的提醒失败: %v", err)
+	}
+
+	snoozes, err := a.GetSnoozedReminders()
+	if err != nil {
+		t.Fatalf("获取推迟的提醒失败: %v", err)
+	}
+	if len(snoozes) != 2 || snoozes[0].TodoID != seriesID || snoozes[0].RepeatIndex != 2 || snoozes[1].TodoID != id {
+		t.Errorf("推迟的提醒为 %+v，应按提醒时间排列且同一种提醒只有一条", snoozes)
+	}
+
+	if err := a.CancelSnooze(id, 0, models.ReminderStart); err != nil {
+		t.Fatalf("取消推迟失败: %v", err)
+	}
+	if snoozes, _ := a.GetSnoozedReminders(); len(snoozes) != 1 {
+		t.Errorf("取消后还有 %d 个推迟的提醒，应为 1 个", len(snoozes))
+	}
+}
+
+// 提醒类型、推迟方式或待办无效时报错
+func TestSnoozeReminderValidation(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "写周报")
+	seriesID := createDailySeries(t, a, time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local), 3)
+	past := models.FlexTime{Time: time.Now().Add(-time.Minute)}
+
+	for name, req := range map[string]models.SnoozeRequest{
+		"未知提醒类型":  {TodoID: id, Reminder: "bogus", Option: models.Snooze5Minutes},
+		"未知推迟方式":  {TodoID: id, Reminder: models.ReminderStart, Option: "1h"},
+		"指定过去的时间": {TodoID: id, Reminder: models.ReminderEnd, Option: models.SnoozeCustom, Until: past},
+		"待办不存在":   {TodoID: 999, Reminder: models.ReminderStart, Option: models.Snooze5Minutes},
+		"实例不存在":   {TodoID: seriesID, RepeatIndex: 4, Reminder: models.ReminderStart, Option: models.Snooze5Minutes},
+	} {
+		if _, err := a.SnoozeReminder(req); err == nil {
+			t.Errorf("%s: 推迟提醒应报错", name)
+		}
+	}
+	if snoozes, _ := a.GetSnoozedReminders(); len(snoozes) != 0 {
+		t.Errorf("推迟失败后有 %d 个推迟的提醒", len(snoozes))
+	}
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+// addSubtasks 为待办添加子任务，返回子任务ID
+func addSubtasks(t *testing.T, a *App, todoID int64, titles ...string) []int64 {
+	t.Helper()
+	var ids []int64
+	for _, title := range titles {
+		subtask, err := a.AddSubtask(todoID, title)
+		if err != nil {
+			t.Fatalf("添加子任务失败: %v", err)
+		}
+		ids = append(ids, subtask.ID)
+	}
+	return ids
+}
+
+// setAutoCompleteParent 设置子任务全部完成后是否自动完成待办
+func setAutoCompleteParent(t *testing.T, a *App, enabled bool) {
+	t.Helper()
+	if _, err := a.db.Exec("UPDATE settings SET auto_complete_parent = ? WHERE id = 1", enabled); err != nil {
+		t.Fatalf("修改设置失败: %v", err)
+	}
+}
+
+// 子任务全部完成后自动完成待办，撤销后待办恢复未完成
+func TestSubtasksAutoCompleteParent(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "发布")
+	subtasks := addSubtasks(t, a, id, "打包", "上传")
+
+	if err := a.MarkSubtaskCompleted(subtasks[0], true); err != nil {
+		t.Fatalf("完成子任务失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); todo.IsCompleted {
+		t.Errorf("还有未完成的子任务时待办不应完成")
+	}
+	if err := a.MarkSubtaskCompleted(subtasks[1], true); err != nil {
+		t.Fatalf("完成子任务失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); !todo.IsCompleted {
+		t.Errorf("子任务全部完成后待办应自动完成")
+	}
+
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("撤销自动完成失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); todo.IsCompleted {
+		t.Errorf("撤销后待办应恢复未完成")
+	}
+}
+
+// 关闭自动完成或待办为循环待办时，子任务全部完成不改变待办
+func TestSubtasksWithoutAutoComplete(t *testing.T) {
+	a := newTestApp(t)
+	setAutoCompleteParent(t, a, false)
+	id := createTask(t, a, "发布")
+	if err := a.MarkSubtaskCompleted(addSubtasks(t, a, id, "打包")[0], true); err != nil {
+		t.Fatalf("完成子任务失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); todo.IsCompleted {
+		t.Errorf("关闭自动完成时待办不应完成")
+	}
+
+	setAutoCompleteParent(t, a, true)
+	seriesID := createDailySeries(t, a, time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local), 3)
+	if err := a.MarkSubtaskCompleted(addSubtasks(t, a, seriesID, "写周报")[0], true); err != nil {
+		t.Fatalf("完成循环待办的子任务失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(seriesID); todo.IsCompleted {
+		t.Errorf("循环待办不应被自动完成")
+	}
+}
+
+// 自动完成待办失败时子任务的完成状态一并回滚
+func TestSubtaskCompletionRollsBack(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "发布")
+	subtasks := addSubtasks(t, a, id, "打包")
+	if _, err := a.db.Exec(`CREATE TRIGGER fail_complete BEFORE UPDATE OF is_completed ON todos
+		BEGIN SELECT RAISE(ABORT, 'complete failed'); END`); err != nil {
+		t.Fatalf("创建触发器失败: %v", err)
+	}
+
+	if err := a.MarkSubtaskCompleted(subtasks[0], true); err == nil {
+		t.Fatalf("自动完成待办失败时应报错")
+	}
+	got, err := a.GetSubtasks(id)
+	if err != nil {
+		t.Fatalf("获取子任务失败: %v", err)
+	}
+	if got[0].IsCompleted {
+		t.Errorf("自动完成待办失败后子任务仍为已完成")
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 月视图按标签筛选时，循环待办的每个实例都带有系列的标签
+func TestCalendarMonthFilterByTag(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	seriesID := createDailySeries(t, a, start, 3)
+	tagID, err := a.CreateTag(models.Tag{Name: "例会"})
+	if err != nil {
+		t.Fatalf("创建标签失败: %v", err)
+	}
+	if err := a.SetTodoTags(seriesID, []int64{tagID}); err != nil {
+		t.Fatalf("设置标签失败: %v", err)
+	}
+	createTask(t, a, "写周报")
+
+	days, err := a.GetCalendarMonthWithFilter(2025, 6, models.TodoFilter{TagIDs: []int64{tagID}})
+	if err != nil {
+		t.Fatalf("获取月视图失败: %v", err)
+	}
+	count := 0
+	for _, day := range days {
+		for _, todo := range day.Todos {
+			if todo.ID != seriesID {
+				t.Errorf("%s 出现了未带标签的待办 %q", day.Date, todo.Title)
+			}
+			count++
+		}
+	}
+	if count != 3 {
+		t.Errorf("月视图有 %d 个带标签的实例，应为 3 个", count)
+	}
+
+	if _, err := a.CreateTag(models.Tag{Name: "例会"}); err == nil {
+		t.Errorf("创建重名标签应报错")
+	}
+	if err := a.UpdateTag(models.Tag{Name: "周会"}); err == nil {
+		t.Errorf("修改无效ID的标签应报错")
+	}
+}
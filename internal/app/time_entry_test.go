@@ -0,0 +1,136 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// addEntry 手动添加一条计时记录
+func addEntry(t *testing.T, a *App, todoID int64, start time.Time, duration time.Duration) {
+	t.Helper()
+	end := models.FlexTime{Time: start.Add(duration)}
+	if _, err := a.AddTimeEntry(models.TimeEntry{TodoID: todoID, StartedAt: models.FlexTime{Time: start}, EndedAt: &end}); err != nil {
+		t.Fatalf("添加计时记录失败: %v", err)
+	}
+}
+
+// 同一时间只有一个计时，开始新的计时或番茄钟时结束正在进行的计时
+func TestTimerSwitchesRunningEntry(t *testing.T) {
+	a := newTestApp(t)
+	writing := createTask(t, a, "写周报")
+	meeting := createTask(t, a, "开会")
+
+	first, err := a.StartTimer(writing)
+	if err != nil {
+		t.Fatalf("开始计时失败: %v", err)
+	}
+	pomodoro, err := a.StartPomodoro(meeting)
+	if err != nil {
+		t.Fatalf("开始番茄钟失败: %v", err)
+	}
+	if pomodoro.Kind != models.TimeEntryPomodoro || pomodoro.PlannedMinutes != models.DefaultPomodoroWorkMinutes {
+		t.Errorf("番茄钟为 %s %d 分钟，应为默认专注时长", pomodoro.Kind, pomodoro.PlannedMinutes)
+	}
+	entries, _ := a.GetTimeEntries(writing)
+	if len(entries) != 1 || entries[0].ID != first.ID || entries[0].IsRunning() {
+		t.Errorf("开始番茄钟后原计时应已结束: %+v", entries)
+	}
+	if running, _ := a.GetRunningTimer(); running == nil || running.ID != pomodoro.ID {
+		t.Errorf("正在进行的计时为 %+v，应为番茄钟", running)
+	}
+
+	stopped, err := a.StopTimer()
+	if err != nil || stopped == nil || stopped.IsRunning() {
+		t.Fatalf("结束计时为 %+v (%v)，应结束番茄钟", stopped, err)
+	}
+	if stopped, err := a.StopTimer(); stopped != nil || err != nil {
+		t.Errorf("没有计时时结束计时返回 %+v (%v)，应为 nil", stopped, err)
+	}
+	if _, err := a.StartTimer(999); err == nil {
+		t.Errorf("为不存在的待办计时应报错")
+	}
+}
+
+// 手动添加的计时记录需属于已有待办，结束时间晚于开始时间
+func TestAddTimeEntryValidation(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "写周报")
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	before := models.FlexTime{Time: start.Add(-time.Minute)}
+	after := models.FlexTime{Time: start.Add(time.Hour)}
+
+	for name, entry := range map[string]models.TimeEntry{
+		"待办不存在":  {TodoID: 999, StartedAt: models.FlexTime{Time: start}, EndedAt: &after},
+		"缺少结束时间": {TodoID: id, StartedAt: models.FlexTime{Time: start}},
+		"结束早于开始": {TodoID: id, StartedAt: models.FlexTime{Time: start}, EndedAt: &before},
+		"缺少开始时间": {TodoID: id, EndedAt: &after},
+	} {
+		if _, err := a.AddTimeEntry(entry); err == nil {
+			t.Errorf("%s: 添加计时记录应报错", name)
+		}
+	}
+
+	entryID, err := a.AddTimeEntry(models.TimeEntry{TodoID: id, Kind: models.TimeEntryPomodoro, PlannedMinutes: 25, Note: " 初稿 ",
+		StartedAt: models.FlexTime{Time: start}, EndedAt: &after})
+	if err != nil {
+		t.Fatalf("添加计时记录失败: %v", err)
+	}
+	entries, _ := a.GetTimeEntries(id)
+	if len(entries) != 1 || entries[0].ID != entryID || entries[0].Kind != models.TimeEntryManual || entries[0].Note != "初稿" || entries[0].Seconds != 3600 {
+		t.Errorf("计时记录为 %+v，应为 1 小时的手动记录", entries)
+	}
+	if err := a.DeleteTimeEntry(entryID); err != nil {
+		t.Fatalf("删除计时记录失败: %v", err)
+	}
+	if entries, _ := a.GetTimeEntries(id); len(entries) != 0 {
+		t.Errorf("删除后还有 %d 条计时记录", len(entries))
+	}
+}
+
+// 用时按待办、类型和日期汇总，跨天的记录拆到每一天，休息不计入
+func TestTimeTotals(t *testing.T) {
+	a := newTestApp(t)
+	writing := createTask(t, a, "写周报")
+	meeting, err := a.CreateTodo(models.Todo{Title: "开会", Type: models.TodoTypeWork,
+		StartDate: models.FlexTime{Time: time.Date(2025, 6, 3, 10, 0, 0, 0, time.Local)},
+		EndDate:   models.FlexTime{Time: time.Date(2025, 6, 3, 11, 0, 0, 0, time.Local)}})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	addEntry(t, a, writing, time.Date(2025, 6, 2, 23, 0, 0, 0, time.Local), 2*time.Hour)
+	addEntry(t, a, meeting, time.Date(2025, 6, 3, 10, 0, 0, 0, time.Local), 30*time.Minute)
+	breakEnd := models.FlexTime{Time: time.Date(2025, 6, 3, 12, 0, 0, 0, time.Local)}
+	if _, err := a.entryRepo.Create(&models.TimeEntry{TodoID: meeting, Kind: models.TimeEntryBreak,
+		StartedAt: models.FlexTime{Time: time.Date(2025, 6, 3, 11, 0, 0, 0, time.Local)}, EndedAt: &breakEnd}); err != nil {
+		t.Fatalf("添加休息记录失败: %v", err)
+	}
+
+	totals, err := a.GetTimeTotals("2025-06-02", "2025-06-03")
+	if err != nil {
+		t.Fatalf("获取用时汇总失败: %v", err)
+	}
+	if totals.TotalSeconds != 9000 {
+		t.Errorf("总用时为 %d 秒，应为 9000 秒", totals.TotalSeconds)
+	}
+	if len(totals.ByTodo) != 2 || totals.ByTodo[0].TodoID != writing || totals.ByTodo[0].Seconds != 7200 {
+		t.Errorf("按待办汇总为 %+v，写周报应以 7200 秒排在最前", totals.ByTodo)
+	}
+	if len(totals.ByType) != 2 || totals.ByType[0].Type != models.TodoTypeTask || totals.ByType[1].Seconds != 1800 {
+		t.Errorf("按类型汇总为 %+v", totals.ByType)
+	}
+	if len(totals.ByDay) != 2 || totals.ByDay[0].Seconds != 3600 || totals.ByDay[1].Seconds != 5400 {
+		t.Errorf("按日期汇总为 %+v，应为 3600 和 5400 秒", totals.ByDay)
+	}
+
+	// 范围只包含第二天时，跨天的记录只算当天的部分
+	if totals, _ := a.GetTimeTotals("2025-06-03", "2025-06-03"); totals.TotalSeconds != 5400 {
+		t.Errorf("6月3日总用时为 %d 秒，应为 5400 秒", totals.TotalSeconds)
+	}
+	for _, r := range [][2]string{{"2025-06-03", "2025-06-02"}, {"20250602", "2025-06-03"}} {
+		if _, err := a.GetTimeTotals(r[0], r[1]); err == nil {
+			t.Errorf("汇总 %s 至 %s 应报错", r[0], r[1])
+		}
+	}
+}
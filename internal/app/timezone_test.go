@@ -0,0 +1,75 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// setLocal 在测试期间把本机时区改为 name
+func setLocal(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("缺少时区数据: %v", err)
+	}
+	old := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = old })
+	return loc
+}
+
+// 传入的起止时间按待办时区的墙上时间解释
+func TestCreateTodoInTimeZone(t *testing.T) {
+	setLocal(t, "Asia/Shanghai")
+	a := newTestApp(t)
+	start := time.Date(2025, 3, 15, 9, 0, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:     "东京会议",
+		Type:      models.TodoTypeWork,
+		TimeZone:  "Asia/Tokyo",
+		StartDate: models.FlexTime{Time: start},
+		EndDate:   models.FlexTime{Time: start.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+
+	setLocal(t, "Europe/Berlin")
+	todo, _ := a.GetTodo(id)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	if want := time.Date(2025, 3, 15, 9, 0, 0, 0, tokyo); !todo.StartDate.Time.Equal(want) {
+		t.Errorf("开始时间为 %v，应为 %v", todo.StartDate.Time, want)
+	}
+	if todo.TimeZone != "Asia/Tokyo" {
+		t.Errorf("时区为 %q，应为 Asia/Tokyo", todo.TimeZone)
+	}
+}
+
+// 全天待办始终为浮动时间，换时区后日期不变
+func TestAllDayTodoIsFloating(t *testing.T) {
+	setLocal(t, "Asia/Shanghai")
+	a := newTestApp(t)
+	day := time.Date(2025, 5, 1, 10, 0, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:     "劳动节",
+		Type:      models.TodoTypeTask,
+		AllDay:    true,
+		TimeZone:  "America/New_York",
+		StartDate: models.FlexTime{Time: day},
+		EndDate:   models.FlexTime{Time: day},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+
+	berlin := setLocal(t, "Europe/Berlin")
+	todo, _ := a.GetTodo(id)
+	if !todo.IsFloating() {
+		t.Errorf("全天待办的时区为 %q，应为 floating", todo.TimeZone)
+	}
+	if want := time.Date(2025, 5, 1, 0, 0, 0, 0, berlin); !todo.StartDate.Time.Equal(want) {
+		t.Errorf("换时区后开始时间为 %v，应为 %v", todo.StartDate.Time, want)
+	}
+}
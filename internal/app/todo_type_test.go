@@ -0,0 +1,49 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 修改待办和修改循环系列时都只接受存在的类型
+func TestUpdateRejectsUnknownType(t *testing.T) {
+	a := newTestApp(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:     "写文档",
+		Type:      models.TodoTypeTask,
+		StartDate: models.FlexTime{Time: start},
+		EndDate:   models.FlexTime{Time: start.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	seriesID := createDailySeries(t, a, start, 5)
+
+	todo, _ := a.GetTodo(id)
+	todo.Type = "missing"
+	if err := a.UpdateTodo(*todo); err == nil {
+		t.Errorf("修改为不存在的类型应失败")
+	}
+
+	edited := *occurrence(t, a, seriesID, 2)
+	edited.Type = "missing"
+	for _, scope := range []models.EditScope{models.EditScopeSingle, models.EditScopeFollowing, models.EditScopeAll} {
+		if _, err := a.UpdateTodoWithScope(edited, scope); err == nil {
+			t.Errorf("按范围 %s 修改为不存在的类型应失败", scope)
+		}
+	}
+
+	// 类型为空时沿用原类型
+	todo, _ = a.GetTodo(id)
+	todo.Type = ""
+	todo.Title = "写文档(修订)"
+	if err := a.UpdateTodo(*todo); err != nil {
+		t.Fatalf("修改待办失败: %v", err)
+	}
+	if todo, _ = a.GetTodo(id); todo.Type != models.TodoTypeTask {
+		t.Errorf("类型为空时应沿用原类型，实际为 %q", todo.Type)
+	}
+}
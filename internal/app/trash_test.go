@@ -0,0 +1,27 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+// 回收站清理可以停止，未启动时停止也立即返回
+func TestStopTrashPurger(t *testing.T) {
+	a := newTestApp(t)
+	a.StartTrashPurger()
+
+	stopped := make(chan struct{})
+	go func() {
+		a.StopTrashPurger()
+		a.StopTrashPurger()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("回收站清理没有停止")
+	}
+
+	idle := newTestApp(t)
+	idle.StopTrashPurger()
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// countRows 统计表中属于待办的记录数
+func countRows(t *testing.T, a *App, table string, todoID int64) int {
+	t.Helper()
+	var n int
+	if err := a.db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE todo_id = ?", todoID).Scan(&n); err != nil {
+		t.Fatalf("统计 %s 失败: %v", table, err)
+	}
+	return n
+}
+
+// createTask 创建一个普通待办
+func createTask(t *testing.T, a *App, title string) int64 {
+	t.Helper()
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id, err := a.CreateTodo(models.Todo{
+		Title:     title,
+		Type:      models.TodoTypeTask,
+		StartDate: models.FlexTime{Time: start},
+		EndDate:   models.FlexTime{Time: start.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	return id
+}
+
+func TestUndoRedoCreate(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "写周报")
+
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("撤销创建失败: %v", err)
+	}
+	if _, err := a.GetTodo(id); err == nil {
+		t.Errorf("撤销创建后待办仍然存在")
+	}
+	if _, err := a.Redo(); err != nil {
+		t.Fatalf("重做创建失败: %v", err)
+	}
+	if todo, err := a.GetTodo(id); err != nil || todo.Title != "写周报" {
+		t.Errorf("重做创建后待办为 %+v (%v)，应恢复原待办", todo, err)
+	}
+}
+
+func TestUndoRedoUpdate(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "初稿")
+	todo, _ := a.GetTodo(id)
+	todo.Title = "终稿"
+	if err := a.UpdateTodo(*todo); err != nil {
+		t.Fatalf("修改待办失败: %v", err)
+	}
+
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("撤销修改失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); todo.Title != "初稿" {
+		t.Errorf("撤销后标题为 %q，应为 初稿", todo.Title)
+	}
+	if _, err := a.Redo(); err != nil {
+		t.Fatalf("重做修改失败: %v", err)
+	}
+	if todo, _ := a.GetTodo(id); todo.Title != "终稿" {
+		t.Errorf("重做后标题为 %q，应为 终稿", todo.Title)
+	}
+}
+
+func TestUndoDelete(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "归档")
+	if err := a.DeleteTodo(id); err != nil {
+		t.Fatalf("删除待办失败: %v", err)
+	}
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("撤销删除失败: %v", err)
+	}
+	if todo, err := a.GetTodo(id); err != nil || todo.Title != "归档" {
+		t.Errorf("撤销删除后待办为 %+v (%v)，应恢复原待办", todo, err)
+	}
+}
+
+// 撤销创建时一并删除计时、推迟和已发送提醒记录，不留下孤立数据
+func TestUndoCreateRemovesReminderState(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "开会")
+	now := time.Now()
+	end := models.FlexTime{Time: now}
+	if _, err := a.entryRepo.Create(&models.TimeEntry{
+		TodoID:    id,
+		Kind:      models.TimeEntryManual,
+		StartedAt: models.FlexTime{Time: now.Add(-time.Hour)},
+		EndedAt:   &end,
+	}); err != nil {
+		t.Fatalf("添加计时记录失败: %v", err)
+	}
+	if _, err := a.SnoozeReminder(models.SnoozeRequest{TodoID: id, Reminder: models.ReminderStart, Option: models.Snooze10Minutes}); err != nil {
+		t.Fatalf("推迟提醒失败: %v", err)
+	}
+	if _, err := a.db.Exec("INSERT INTO fired_reminders (key, todo_id, fired_at) VALUES (?, ?, ?)", "test-key", id, now); err != nil {
+		t.Fatalf("登记提醒失败: %v", err)
+	}
+
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("撤销创建失败: %v", err)
+	}
+	for _, table := range []string{"time_entries", "reminder_snoozes", "fired_reminders"} {
+		if n := countRows(t, a, table, id); n != 0 {
+			t.Errorf("撤销创建后 %s 仍有 %d 条记录", table, n)
+		}
+	}
+}
+
+// 提醒服务标记开始提醒后，修改仍可撤销
+func TestUndoIgnoresReminderState(t *testing.T) {
+	a := newTestApp(t)
+	id := createTask(t, a, "初稿")
+	todo, _ := a.GetTodo(id)
+	todo.Title = "终稿"
+	if err := a.UpdateTodo(*todo); err != nil {
+		t.Fatalf("修改待办失败: %v", err)
+	}
+	if err := a.MarkStartRemindTriggered(id); err != nil {
+		t.Fatalf("标记提醒失败: %v", err)
+	}
+	if _, err := a.Undo(); err != nil {
+		t.Errorf("标记提醒后撤销失败: %v", err)
+	}
+
+	// 循环实例：提醒服务为尚无记录的实例插入提醒状态
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	seriesID := createDailySeries(t, a, start, 10)
+	series := occurrence(t, a, seriesID, 1)
+	series.Title = "日报"
+	if _, err := a.UpdateTodoWithScope(*series, models.EditScopeAll); err != nil {
+		t.Fatalf("修改循环待办失败: %v", err)
+	}
+	if err := a.MarkOccurrenceStartRemindTriggered(seriesID, 3); err != nil {
+		t.Fatalf("标记实例提醒失败: %v", err)
+	}
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("标记实例提醒后撤销失败: %v", err)
+	}
+	if got := occurrence(t, a, seriesID, 3); got.Title != "周报" {
+		t.Errorf("撤销后实例标题为 %q，应为 周报", got.Title)
+	}
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// AnalyticsRepository 统计仓库，在待办数据上计算完成情况等统计
+type AnalyticsRepository struct {
+	todos *TodoRepository
+}
+
+// NewAnalyticsRepository 创建统计仓库实例
+func NewAnalyticsRepository(db *sql.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{todos: NewTodoRepository(db)}
+}
+
+// Get 统计开始时间在 [start, end) 内的待办，循环待办展开为实例
+func (r *AnalyticsRepository) Get(start, end time.Time) (*models.Analytics, error) {
+	todos, err := r.todos.GetByDateRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	result := &models.Analytics{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Add(-time.Second).Format("2006-01-02"),
+		ByType:    []models.TypeStats{},
+		ByWeek:    []models.WeekStats{},
+		Weekdays:  make([]int, 7),
+		Hours:     make([]int, 24),
+		Series:    []models.SeriesStats{},
+	}
+
+	summary := &completionCounter{}
+	byType := map[models.TodoType]*completionCounter{}
+	typeOrder := []models.TodoType{}
+	series := map[int64]*models.SeriesStats{}
+	seriesOrder := []int64{}
+
+	// 按周分组，周一开始
+	weeks := []time.Time{}
+	byWeek := map[string]*completionCounter{}
+	for week := mondayOf(start); week.Before(end); week = week.AddDate(0, 0, 7) {
+		weeks = append(weeks, week)
+		byWeek[week.Format("2006-01-02")] = &completionCounter{}
+	}
+
+	inRange := []models.Todo{}
+	for _, todo := range todos {
+		startAt := todo.StartDate.Time.In(time.Local)
+		if startAt.Before(start) || !startAt.Before(end) {
+			continue
+		}
+		inRange = append(inRange, todo)
+
+		summary.add(&todo, now)
+		if byType[todo.Type] == nil {
+			byType[todo.Type] = &completionCounter{}
+			typeOrder = append(typeOrder, todo.Type)
+		}
+		byType[todo.Type].add(&todo, now)
+		if counter := byWeek[mondayOf(startAt).Format("2006-01-02")]; counter != nil {
+			counter.add(&todo, now)
+		}
+
+		result.Weekdays[(int(startAt.Weekday())+6)%7]++
+		if !todo.AllDay {
+			result.Hours[startAt.Hour()]++
+		}
+
+		if todo.IsOccurrence && todo.EndDate.Time.Before(now) {
+			stats := series[todo.ID]
+			if stats == nil {
+				stats = &models.SeriesStats{TodoID: todo.ID, Title: todo.Title, Type: todo.Type}
+				series[todo.ID] = stats
+				seriesOrder = append(seriesOrder, todo.ID)
+			}
+			stats.Due++
+			if todo.IsCompleted {
+				stats.Completed++
+				if todo.CompletedAt != nil && !todo.CompletedAt.Time.After(todo.EndDate.Time) {
+					stats.OnTime++
+				}
+			}
+		}
+	}
+
+	result.Summary = summary.stats()
+	sort.Slice(typeOrder, func(i, j int) bool { return typeOrder[i] < typeOrder[j] })
+	for _, todoType := range typeOrder {
+		result.ByType = append(result.ByType, models.TypeStats{Type: todoType, Stats: byType[todoType].stats()})
+	}
+	for _, week := range weeks {
+		weekEnd := week.AddDate(0, 0, 7)
+		if weekEnd.After(now) {
+			weekEnd = now
+		}
+		key := week.Format("2006-01-02")
+		result.ByWeek = append(result.ByWeek, models.WeekStats{
+			WeekStart:   key,
+			Stats:       byWeek[key].stats(),
+			OpenOverdue: openOverdueAt(inRange, weekEnd),
+		})
+	}
+	for _, id := range seriesOrder {
+		stats := series[id]
+		stats.CompletionRate = ratio(stats.Completed, stats.Due)
+		stats.OnTimeRate = ratio(stats.OnTime, stats.Due)
+		result.Series = append(result.Series, *stats)
+	}
+	return result, nil
+}
+
+// completionCounter 累计完成情况
+type completionCounter struct {
+	total, completed, onTime, late, overdue int
+	lateMinutes                             float64
+}
+
+// add 统计一个待办
+func (c *completionCounter) add(todo *models.Todo, now time.Time) {
+	c.total++
+	end := todo.EndDate.Time
+	if !todo.IsCompleted {
+		if end.Before(now) {
+			c.overdue++
+		}
+		return
+	}
+	c.completed++
+	if todo.CompletedAt == nil || todo.CompletedAt.Time.IsZero() {
+		return
+	}
+	diff := todo.CompletedAt.Time.Sub(end)
+	if diff > 0 {
+		c.late++
+	} else {
+		c.onTime++
+	}
+	c.lateMinutes += diff.Minutes()
+}
+
+// stats 返回统计结果
+func (c *completionCounter) stats() models.CompletionStats {
+	stats := models.CompletionStats{
+		Total:          c.total,
+		Completed:      c.completed,
+		CompletionRate: ratio(c.completed, c.total),
+		OnTime:         c.onTime,
+		Late:           c.late,
+		Overdue:        c.overdue,
+	}
+	if timed := c.onTime + c.late; timed > 0 {
+		stats.AvgLateMinutes = c.lateMinutes / float64(timed)
+	}
+	return stats
+}
+
+// openOverdueAt 统计在 at 时刻已过结束时间且尚未完成的待办数量
+func openOverdueAt(todos []models.Todo, at time.Time) int {
+	count := 0
+	for _, todo := range todos {
+		if !todo.EndDate.Time.Before(at) {
+			continue
+		}
+		if todo.IsCompleted && (todo.CompletedAt == nil || !todo.CompletedAt.Time.After(at)) {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// mondayOf 返回 t 所在周的周一 00:00(本地时间)
+func mondayOf(t time.Time) time.Time {
+	t = t.In(time.Local)
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()-weekday+1, 0, 0, 0, 0, time.Local)
+}
+
+// ratio 计算比例，分母为0时返回0
+func ratio(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
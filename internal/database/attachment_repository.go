@@ -12,12 +12,13 @@ import (
 	"path/filepath"
 	"time"
 
+	"todo-calendar/internal/datadir"
 	"todo-calendar/internal/models"
 )
 
 // AttachmentRepository 附件仓库
 type AttachmentRepository struct {
-	db *sql.DB
+	db dbExecutor
 }
 
 // NewAttachmentRepository 创建附件仓库实例
@@ -25,6 +26,11 @@ func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
 	return &AttachmentRepository{db: db}
 }
 
+// WithTx 返回使用指定事务的仓库副本
+func (r *AttachmentRepository) WithTx(tx *sql.Tx) *AttachmentRepository {
+	return &AttachmentRepository{db: tx}
+}
+
 // Create 创建附件记录
 func (r *AttachmentRepository) Create(attachment *models.Attachment) (int64, error) {
 	query := `
@@ -141,6 +147,75 @@ func (r *AttachmentRepository) DeleteByTodoID(todoID int64) error {
 	return err
 }
 
+// DeleteRecord 只删除附件记录，保留文件以便撤销
+func (r *AttachmentRepository) DeleteRecord(id int64) error {
+	result, err := r.db.Exec("DELETE FROM attachments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteRecordsByTodoID 删除待办的所有附件记录，返回对应的文件路径
+// 调用方在事务提交后删除文件
+func (r *AttachmentRepository) DeleteRecordsByTodoID(todoID int64) ([]string, error) {
+	attachments, err := r.GetByTodoID(todoID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.db.Exec("DELETE FROM attachments WHERE todo_id = ?", todoID); err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		paths[i] = attachment.StoragePath
+	}
+	return paths, nil
+}
+
+// CopyToTodo 将待办的所有附件复制给另一个待办(复制加密文件，沿用原密钥)
+// 返回新附件列表，调用方在事务失败时负责删除其中的文件
+func (r *AttachmentRepository) CopyToTodo(fromTodoID, toTodoID int64) ([]models.Attachment, error) {
+	attachments, err := r.GetByTodoID(fromTodoID)
+	if err != nil {
+		return nil, err
+	}
+
+	storageDir, err := getAttachmentDir()
+	if err != nil {
+		return nil, err
+	}
+
+	copied := []models.Attachment{}
+	for _, attachment := range attachments {
+		data, err := os.ReadFile(attachment.StoragePath)
+		if err != nil {
+			return copied, fmt.Errorf("failed to read attachment file: %w", err)
+		}
+
+		uniqueName := fmt.Sprintf("%d_%d_%s", toTodoID, time.Now().UnixNano(), attachment.FileName)
+		storagePath := filepath.Join(storageDir, uniqueName+".enc")
+		if err := os.WriteFile(storagePath, data, 0600); err != nil {
+			return copied, fmt.Errorf("failed to copy attachment file: %w", err)
+		}
+
+		attachment.ID = 0
+		attachment.TodoID = toTodoID
+		attachment.StoragePath = storagePath
+		id, err := r.Create(&attachment)
+		if err != nil {
+			os.Remove(storagePath)
+			return copied, err
+		}
+		attachment.ID = id
+		copied = append(copied, attachment)
+	}
+	return copied, nil
+}
+
 // EncryptAndSaveFile 加密并保存文件
 func (r *AttachmentRepository) EncryptAndSaveFile(todoID int64, fileName string, data []byte, mimeType string) (*models.Attachment, error) {
 	// 生成32字节的AES密钥
@@ -220,15 +295,7 @@ func (r *AttachmentRepository) DecryptFile(id int64) ([]byte, error) {
 
 // getAttachmentDir 获取附件存储目录
 func getAttachmentDir() (string, error) {
-	exe, err := os.Executable()
-	if err != nil {
-		return "", err
-	}
-	dir := filepath.Join(filepath.Dir(exe), "data", "attachments")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
-	}
-	return dir, nil
+	return datadir.AttachmentsDir()
 }
 
 // encryptAES AES加密
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// calendarColumns 日历查询字段列表，与 scanCalendar 的扫描顺序一致
+const calendarColumns = `c.id, c.name, COALESCE(c.color, ''), COALESCE(c.default_type, ''),
+			   COALESCE(c.is_visible, 1), COALESCE(c.is_muted, 0), COALESCE(c.sort_order, 0), c.created_at,
+			   (SELECT COUNT(*) FROM todos WHERE todos.calendar_id = c.id AND todos.deleted_at IS NULL)`
+
+// CalendarRepository 日历仓库
+type CalendarRepository struct {
+	db dbExecutor
+}
+
+// NewCalendarRepository 创建日历仓库实例
+func NewCalendarRepository(db *sql.DB) *CalendarRepository {
+	return &CalendarRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *CalendarRepository) WithTx(tx *sql.Tx) *CalendarRepository {
+	return &CalendarRepository{db: tx}
+}
+
+// List 获取所有日历，按排序号排列
+func (r *CalendarRepository) List() ([]models.Calendar, error) {
+	query := `SELECT ` + calendarColumns + ` FROM calendars c ORDER BY c.sort_order ASC, c.id ASC`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calendars := []models.Calendar{}
+	for rows.Next() {
+		calendar, err := scanCalendar(rows)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, calendar)
+	}
+	return calendars, rows.Err()
+}
+
+// GetByID 根据ID获取日历
+func (r *CalendarRepository) GetByID(id int64) (*models.Calendar, error) {
+	query := `SELECT ` + calendarColumns + ` FROM calendars c WHERE c.id = ?`
+	calendar, err := scanCalendar(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+	return &calendar, nil
+}
+
+// Default 获取默认日历(排在第一位的日历)
+func (r *CalendarRepository) Default() (*models.Calendar, error) {
+	query := `SELECT ` + calendarColumns + ` FROM calendars c ORDER BY c.sort_order ASC, c.id ASC LIMIT 1`
+	calendar, err := scanCalendar(r.db.QueryRow(query))
+	if err != nil {
+		return nil, err
+	}
+	return &calendar, nil
+}
+
+// Create 创建日历，未指定排序号时排在最后
+func (r *CalendarRepository) Create(calendar *models.Calendar) (int64, error) {
+	calendar.Name = strings.TrimSpace(calendar.Name)
+	if calendar.Name == "" {
+		return 0, fmt.Errorf("日历名称不能为空")
+	}
+	if calendar.SortOrder <= 0 {
+		if err := r.db.QueryRow("SELECT COALESCE(MAX(sort_order), 0) + 1 FROM calendars").Scan(&calendar.SortOrder); err != nil {
+			return 0, err
+		}
+	}
+
+	query := `
+		INSERT INTO calendars (name, color, default_type, is_visible, is_muted, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now()
+	result, err := r.db.Exec(query,
+		calendar.Name,
+		calendar.Color,
+		calendar.DefaultType,
+		calendar.IsVisible,
+		calendar.IsMuted,
+		calendar.SortOrder,
+		now,
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Update 更新日历
+func (r *CalendarRepository) Update(calendar *models.Calendar) error {
+	calendar.Name = strings.TrimSpace(calendar.Name)
+	if calendar.Name == "" {
+		return fmt.Errorf("日历名称不能为空")
+	}
+	query := `
+		UPDATE calendars SET
+			name = ?,
+			color = ?,
+			default_type = ?,
+			is_visible = ?,
+			is_muted = ?,
+			sort_order = ?,
+			updated_at = ?
+		WHERE id = ?
+	`
+	_, err := r.db.Exec(query,
+		calendar.Name,
+		calendar.Color,
+		calendar.DefaultType,
+		calendar.IsVisible,
+		calendar.IsMuted,
+		calendar.SortOrder,
+		time.Now(),
+		calendar.ID,
+	)
+	return err
+}
+
+// SetVisible 设置日历是否显示
+func (r *CalendarRepository) SetVisible(id int64, visible bool) error {
+	_, err := r.db.Exec("UPDATE calendars SET is_visible = ?, updated_at = ? WHERE id = ?", visible, time.Now(), id)
+	return err
+}
+
+// SetMuted 设置日历是否静音提醒
+func (r *CalendarRepository) SetMuted(id int64, muted bool) error {
+	_, err := r.db.Exec("UPDATE calendars SET is_muted = ?, updated_at = ? WHERE id = ?", muted, time.Now(), id)
+	return err
+}
+
+// Delete 删除日历，其中的待办(包括回收站中的)移到 reassignTo 日历
+func (r *CalendarRepository) Delete(id, reassignTo int64) error {
+	if id == reassignTo {
+		return fmt.Errorf("不能将待办移到要删除的日历")
+	}
+	if _, err := r.GetByID(reassignTo); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("日历不存在: %d", reassignTo)
+		}
+		return err
+	}
+	if _, err := r.db.Exec("UPDATE todos SET calendar_id = ?, updated_at = ? WHERE calendar_id = ?", reassignTo, time.Now(), id); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("DELETE FROM calendars WHERE id = ?", id)
+	return err
+}
+
+// HiddenIDs 获取隐藏的日历ID
+func (r *CalendarRepository) HiddenIDs() (map[int64]bool, error) {
+	return r.queryIDs("SELECT id FROM calendars WHERE is_visible = 0")
+}
+
+// SilencedIDs 获取不发送提醒的日历ID(隐藏或静音的日历)
+func (r *CalendarRepository) SilencedIDs() (map[int64]bool, error) {
+	return r.queryIDs("SELECT id FROM calendars WHERE is_visible = 0 OR is_muted = 1")
+}
+
+// queryIDs 查询日历ID集合
+func (r *CalendarRepository) queryIDs(query string) (map[int64]bool, error) {
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// scanCalendar 扫描单个日历
+func scanCalendar(row rowScanner) (models.Calendar, error) {
+	var calendar models.Calendar
+	err := row.Scan(
+		&calendar.ID,
+		&calendar.Name,
+		&calendar.Color,
+		&calendar.DefaultType,
+		&calendar.IsVisible,
+		&calendar.IsMuted,
+		&calendar.SortOrder,
+		&calendar.CreatedAt,
+		&calendar.TodoCount,
+	)
+	return calendar, err
+}
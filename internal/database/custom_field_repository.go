@@ -0,0 +1,310 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// customFieldColumns 自定义字段查询字段列表，与 scanCustomField 的扫描顺序一致
+const customFieldColumns = `id, todo_type, key, label, field_type, COALESCE(options, '[]'),
+			   COALESCE(required, 0), COALESCE(sort_order, 0)`
+
+// customFieldBatchSize 批量加载字段值时每批的待办数量
+const customFieldBatchSize = 500
+
+// CustomFieldRepository 自定义字段仓库
+type CustomFieldRepository struct {
+	db dbExecutor
+}
+
+// NewCustomFieldRepository 创建自定义字段仓库实例
+func NewCustomFieldRepository(db *sql.DB) *CustomFieldRepository {
+	return &CustomFieldRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *CustomFieldRepository) WithTx(tx *sql.Tx) *CustomFieldRepository {
+	return &CustomFieldRepository{db: tx}
+}
+
+// List 获取所有自定义字段，按类型和排序号排列
+func (r *CustomFieldRepository) List() ([]models.CustomFieldDef, error) {
+	return r.queryFields(`SELECT ` + customFieldColumns + ` FROM custom_fields ORDER BY todo_type ASC, sort_order ASC, id ASC`)
+}
+
+// ListByType 获取类型的自定义字段，按排序号排列
+func (r *CustomFieldRepository) ListByType(todoType models.TodoType) ([]models.CustomFieldDef, error) {
+	return r.queryFields(`SELECT `+customFieldColumns+` FROM custom_fields WHERE todo_type = ? ORDER BY sort_order ASC, id ASC`, todoType)
+}
+
+// GetByID 根据ID获取自定义字段
+func (r *CustomFieldRepository) GetByID(id int64) (*models.CustomFieldDef, error) {
+	field, err := scanCustomField(r.db.QueryRow(`SELECT `+customFieldColumns+` FROM custom_fields WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+// Create 创建自定义字段，未指定排序号时排在该类型的最后
+func (r *CustomFieldRepository) Create(field *models.CustomFieldDef) (int64, error) {
+	if field.SortOrder <= 0 {
+		if err := r.db.QueryRow("SELECT COALESCE(MAX(sort_order), 0) + 1 FROM custom_fields WHERE todo_type = ?", field.TodoType).Scan(&field.SortOrder); err != nil {
+			return 0, err
+		}
+	}
+	options, err := json.Marshal(field.Options)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO custom_fields (todo_type, key, label, field_type, options, required, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now()
+	result, err := r.db.Exec(query,
+		field.TodoType,
+		field.Key,
+		field.Label,
+		field.FieldType,
+		string(options),
+		field.Required,
+		field.SortOrder,
+		now,
+		now,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, fmt.Errorf("字段标识已存在: %s", field.Key)
+		}
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Update 更新自定义字段的名称、可选值、必填和排序(类型、标识和字段类型不可修改)
+func (r *CustomFieldRepository) Update(field *models.CustomFieldDef) error {
+	options, err := json.Marshal(field.Options)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE custom_fields SET
+			label = ?,
+			options = ?,
+			required = ?,
+			sort_order = ?,
+			updated_at = ?
+		WHERE id = ?
+	`
+	_, err = r.db.Exec(query,
+		field.Label,
+		string(options),
+		field.Required,
+		field.SortOrder,
+		time.Now(),
+		field.ID,
+	)
+	return err
+}
+
+// Delete 删除自定义字段及所有待办上该字段的值
+func (r *CustomFieldRepository) Delete(id int64) error {
+	if _, err := r.db.Exec("DELETE FROM todo_field_values WHERE field_id = ?", id); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("DELETE FROM custom_fields WHERE id = ?", id)
+	return err
+}
+
+// DeleteByType 删除类型的所有自定义字段及其值
+func (r *CustomFieldRepository) DeleteByType(todoType models.TodoType) error {
+	if _, err := r.db.Exec("DELETE FROM todo_field_values WHERE field_id IN (SELECT id FROM custom_fields WHERE todo_type = ?)", todoType); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("DELETE FROM custom_fields WHERE todo_type = ?", todoType)
+	return err
+}
+
+// SetTodoValues 设置待办的自定义字段值(替换原有的值)，键为待办类型下的字段标识，空值不保存
+func (r *CustomFieldRepository) SetTodoValues(todoID int64, todoType models.TodoType, values map[string]string) error {
+	if err := r.DeleteByTodoID(todoID); err != nil {
+		return err
+	}
+	for key, value := range values {
+		if value == "" {
+			continue
+		}
+		_, err := r.db.Exec(`
+			INSERT INTO todo_field_values (todo_id, field_id, value)
+			SELECT ?, id, ? FROM custom_fields WHERE todo_type = ? AND key = ?
+		`, todoID, value, todoType, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteByTodoID 删除待办的所有自定义字段值
+func (r *CustomFieldRepository) DeleteByTodoID(todoID int64) error {
+	_, err := r.db.Exec("DELETE FROM todo_field_values WHERE todo_id = ?", todoID)
+	return err
+}
+
+// GetByTodoIDs 批量获取待办的自定义字段值，只包含待办当前类型的字段
+func (r *CustomFieldRepository) GetByTodoIDs(todoIDs []int64) (map[int64]map[string]string, error) {
+	result := make(map[int64]map[string]string)
+	for start := 0; start < len(todoIDs); start += customFieldBatchSize {
+		end := start + customFieldBatchSize
+		if end > len(todoIDs) {
+			end = len(todoIDs)
+		}
+		batch := todoIDs[start:end]
+
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		query := `
+			SELECT v.todo_id, f.key, v.value
+			FROM todo_field_values v
+			JOIN custom_fields f ON f.id = v.field_id
+			JOIN todos t ON t.id = v.todo_id AND t.type = f.todo_type
+			WHERE v.todo_id IN (` + placeholders(len(batch)) + `)
+		`
+		rows, err := r.db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var todoID int64
+			var key, value string
+			if err := rows.Scan(&todoID, &key, &value); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if result[todoID] == nil {
+				result[todoID] = make(map[string]string)
+			}
+			result[todoID][key] = value
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// loadInto 为待办列表填充自定义字段值
+func (r *CustomFieldRepository) loadInto(todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(todos))
+	for i := range todos {
+		ids[i] = todos[i].ID
+	}
+	valueMap, err := r.GetByTodoIDs(ids)
+	if err != nil {
+		return err
+	}
+	for i := range todos {
+		todos[i].CustomFields = valueMap[todos[i].ID]
+		if todos[i].CustomFields == nil {
+			todos[i].CustomFields = map[string]string{}
+		}
+	}
+	return nil
+}
+
+// queryFields 执行查询并扫描自定义字段列表
+func (r *CustomFieldRepository) queryFields(query string, args ...interface{}) ([]models.CustomFieldDef, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []models.CustomFieldDef{}
+	for rows.Next() {
+		field, err := scanCustomField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, rows.Err()
+}
+
+// scanCustomField 扫描单个自定义字段
+func scanCustomField(row rowScanner) (models.CustomFieldDef, error) {
+	var field models.CustomFieldDef
+	var options string
+	err := row.Scan(
+		&field.ID,
+		&field.TodoType,
+		&field.Key,
+		&field.Label,
+		&field.FieldType,
+		&options,
+		&field.Required,
+		&field.SortOrder,
+	)
+	if err != nil {
+		return field, err
+	}
+	if err := json.Unmarshal([]byte(options), &field.Options); err != nil || field.Options == nil {
+		field.Options = []string{}
+	}
+	return field, nil
+}
+
+// customFieldCondition 返回按自定义字段筛选待办的条件，用于 todos 表的查询
+// 只匹配待办当前类型的字段；比较值为数字时，数字字段按数值比较
+func customFieldCondition(filters []models.CustomFieldFilter) (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	for _, filter := range filters {
+		var cond string
+		condArgs := []interface{}{filter.Key}
+		switch filter.Op {
+		case models.FieldOpExists:
+			cond = "v.value != ''"
+		case models.FieldOpContains:
+			cond = `v.value LIKE ? ESCAPE '\'`
+			condArgs = append(condArgs, "%"+escapeLike(filter.Value)+"%")
+		default:
+			op := map[string]string{
+				models.FieldOpGt:  ">",
+				models.FieldOpGte: ">=",
+				models.FieldOpLt:  "<",
+				models.FieldOpLte: "<=",
+			}[filter.Op]
+			if op == "" {
+				op = "="
+			}
+			value := strings.TrimSpace(filter.Value)
+			if number, err := strconv.ParseFloat(value, 64); err == nil {
+				cond = "CASE WHEN f.field_type = '" + models.FieldTypeNumber + "' THEN CAST(v.value AS REAL) " + op + " ? ELSE v.value " + op + " ? END"
+				condArgs = append(condArgs, number, filter.Value)
+			} else {
+				cond = "v.value " + op + " ?"
+				condArgs = append(condArgs, filter.Value)
+			}
+		}
+		conds = append(conds, `id IN (SELECT v.todo_id FROM todo_field_values v
+			JOIN custom_fields f ON f.id = v.field_id
+			WHERE f.todo_type = todos.type AND f.key = ? AND `+cond+`)`)
+		args = append(args, condArgs...)
+	}
+	return strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// DependencyRepository 待办依赖仓库
+type DependencyRepository struct {
+	db dbExecutor
+}
+
+// NewDependencyRepository 创建待办依赖仓库实例
+func NewDependencyRepository(db *sql.DB) *DependencyRepository {
+	return &DependencyRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *DependencyRepository) WithTx(tx *sql.Tx) *DependencyRepository {
+	return &DependencyRepository{db: tx}
+}
+
+// Add 添加依赖：todoID 需等待 dependsOnID 完成，形成循环依赖时返回错误
+func (r *DependencyRepository) Add(todoID, dependsOnID int64) error {
+	if todoID == dependsOnID {
+		return fmt.Errorf("待办不能依赖自身")
+	}
+	cyclic, err := r.reaches(dependsOnID, todoID)
+	if err != nil {
+		return err
+	}
+	if cyclic {
+		return fmt.Errorf("添加依赖会形成循环依赖")
+	}
+	_, err = r.db.Exec("INSERT OR IGNORE INTO todo_dependencies (todo_id, depends_on_id, created_at) VALUES (?, ?, ?)",
+		todoID, dependsOnID, time.Now())
+	return err
+}
+
+// Remove 移除依赖
+func (r *DependencyRepository) Remove(todoID, dependsOnID int64) error {
+	_, err := r.db.Exec("DELETE FROM todo_dependencies WHERE todo_id = ? AND depends_on_id = ?", todoID, dependsOnID)
+	return err
+}
+
+// DeleteByTodoID 删除待办的所有依赖关系(包括前置和后续)
+func (r *DependencyRepository) DeleteByTodoID(todoID int64) error {
+	_, err := r.db.Exec("DELETE FROM todo_dependencies WHERE todo_id = ? OR depends_on_id = ?", todoID, todoID)
+	return err
+}
+
+// GetEdges 获取两端都在给定待办内的依赖关系
+func (r *DependencyRepository) GetEdges(todoIDs []int64) ([]models.DependencyEdge, error) {
+	edges := []models.DependencyEdge{}
+	if len(todoIDs) == 0 {
+		return edges, nil
+	}
+	ids := make(map[int64]bool, len(todoIDs))
+	for _, id := range todoIDs {
+		ids[id] = true
+	}
+
+	rows, err := r.db.Query("SELECT depends_on_id, todo_id FROM todo_dependencies ORDER BY depends_on_id, todo_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var edge models.DependencyEdge
+		if err := rows.Scan(&edge.From, &edge.To); err != nil {
+			return nil, err
+		}
+		if ids[edge.From] && ids[edge.To] {
+			edges = append(edges, edge)
+		}
+	}
+	return edges, rows.Err()
+}
+
+// reaches 判断从 fromID 沿依赖关系(前置方向)能否到达 toID
+func (r *DependencyRepository) reaches(fromID, toID int64) (bool, error) {
+	query := `
+		WITH RECURSIVE reach(id) AS (
+			SELECT ?
+			UNION
+			SELECT d.depends_on_id FROM todo_dependencies d JOIN reach ON d.todo_id = reach.id
+		)
+		SELECT EXISTS (SELECT 1 FROM reach WHERE id = ?)
+	`
+	var found bool
+	err := r.db.QueryRow(query, fromID, toID).Scan(&found)
+	return found, err
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FiredReminderRepository 已发送提醒仓库
+// 提醒发送前先在这里登记，登记成功的进程才发送，重启后和多个进程之间不会重复提醒
+type FiredReminderRepository struct {
+	db dbExecutor
+}
+
+// NewFiredReminderRepository 创建已发送提醒仓库实例
+func NewFiredReminderRepository(db *sql.DB) *FiredReminderRepository {
+	return &FiredReminderRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *FiredReminderRepository) WithTx(tx *sql.Tx) *FiredReminderRepository {
+	return &FiredReminderRepository{db: tx}
+}
+
+// Claim 登记提醒并返回是否由本次调用登记，已登记过的提醒返回 false
+func (r *FiredReminderRepository) Claim(key string, todoID int64, firedAt time.Time) (bool, error) {
+	result, err := r.db.Exec("INSERT OR IGNORE INTO fired_reminders (key, todo_id, fired_at) VALUES (?, ?, ?)", key, todoID, firedAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Prune 删除指定时间之前登记的提醒，返回删除的数量
+func (r *FiredReminderRepository) Prune(before time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM fired_reminders WHERE fired_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,891 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"todo-calendar/internal/models"
+	"todo-calendar/internal/utils"
+)
+
+// migration 数据库迁移
+// 每个迁移在独立事务中执行，成功后记录到 schema_migrations
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations 按版本号顺序排列的迁移列表，只允许追加，不允许修改已发布的迁移
+var migrations = []migration{
+	{1, "initial schema", migrateInitialSchema},
+	{2, "settings backend url and sound file", migrateSettingsColumns},
+	{3, "todo repeat index", migrateRepeatIndex},
+	{4, "recurring todo series", migrateRecurringSeries},
+	{5, "todo instance overrides", migrateInstanceOverrides},
+	{6, "full-text search index", migrateFullTextSearch},
+	{7, "tags", migrateTags},
+	{8, "todo types", migrateTodoTypes},
+	{9, "todo priority", migrateTodoPriority},
+	{10, "subtasks", migrateSubtasks},
+	{11, "todo dependencies", migrateTodoDependencies},
+	{12, "trash", migrateTrash},
+	{13, "todo revisions", migrateTodoRevisions},
+	{14, "operation log", migrateOperationLog},
+	{15, "utc times and todo time zones", migrateUTCTimes},
+	{16, "all-day todos", migrateAllDay},
+	{17, "calendars", migrateCalendars},
+	{18, "meeting details", migrateMeetingDetails},
+	{19, "custom fields", migrateCustomFields},
+	{20, "time entries", migrateTimeEntries},
+	{21, "reminder snoozes", migrateReminderSnoozes},
+	{22, "notification log details", migrateNotificationLogs},
+	{23, "fired reminders", migrateFiredReminders},
+	{24, "missed reminder catch-up", migrateMissedReminders},
+}
+
+// maxBackups 保留的迁移前备份数量
+const maxBackups = 5
+
+// migrate 执行未应用的迁移
+// 迁移前备份数据库；数据库版本高于程序支持的版本时返回错误，避免旧版本程序破坏数据
+func migrate(db *sql.DB, dbPath string) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	latest := migrations[len(migrations)-1].version
+	if current > latest {
+		return fmt.Errorf("数据库版本(%d)高于程序支持的版本(%d)，请升级程序后再打开", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	// 已有数据(包括引入迁移框架之前的数据库)时先备份
+	hasData, err := tableExists(db, "todos")
+	if err != nil {
+		return err
+	}
+	if hasData {
+		if err := backupDatabase(db, dbPath, current); err != nil {
+			return fmt.Errorf("failed to backup database before migration: %w", err)
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// schemaVersion 获取当前数据库版本
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// applyMigration 在事务中执行单个迁移并记录版本
+func applyMigration(db *sql.DB, m migration) error {
+	return RunInTx(db, func(tx *sql.Tx) error {
+		if err := m.up(tx); err != nil {
+			return err
+		}
+		_, err := tx.Exec(
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			m.version, m.name, time.Now(),
+		)
+		return err
+	})
+}
+
+// backupDatabase 迁移前备份数据库到 backups 目录，只保留最近的几份
+func backupDatabase(db *sql.DB, dbPath string, version int) error {
+	backupDir := filepath.Join(filepath.Dir(dbPath), "backups")
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("todo_calendar-v%d-%s.db", version, time.Now().Format("20060102-150405"))
+	backupPath := filepath.Join(backupDir, name)
+	if _, err := db.Exec("VACUUM INTO ?", backupPath); err != nil {
+		return err
+	}
+
+	// 清理旧备份
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return nil
+	}
+	backups := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "todo_calendar-v") {
+			backups = append(backups, entry.Name())
+		}
+	}
+	if len(backups) <= maxBackups {
+		return nil
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backupTimestamp(backups[i]) < backupTimestamp(backups[j])
+	})
+	for _, old := range backups[:len(backups)-maxBackups] {
+		os.Remove(filepath.Join(backupDir, old))
+	}
+	return nil
+}
+
+// backupTimestamp 从备份文件名中取出时间戳部分用于排序
+func backupTimestamp(name string) string {
+	name = strings.TrimSuffix(name, ".db")
+	if i := strings.Index(name, "-"); i >= 0 {
+		if j := strings.Index(name[i+1:], "-"); j >= 0 {
+			return name[i+j+2:]
+		}
+	}
+	return name
+}
+
+// tableExists 检查表是否存在
+func tableExists(db dbExecutor, table string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	return count > 0, err
+}
+
+// columnExists 检查字段是否存在
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name, typ  string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultVal, &pk); err != nil {
+			return false, err
+		}
+		if strings.EqualFold(name, column) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// addColumns 添加字段，已存在的字段跳过(兼容引入迁移框架之前手动添加过字段的数据库)
+func addColumns(tx *sql.Tx, table string, columns [][2]string) error {
+	for _, column := range columns {
+		exists, err := columnExists(tx, table, column[0])
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column[0], column[1])
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execAll 依次执行多条语句
+func execAll(tx *sql.Tx, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateInitialSchema 初始表结构
+func migrateInitialSchema(tx *sql.Tx) error {
+	// 创建待办事项表
+	todoTable := `
+	CREATE TABLE IF NOT EXISTS todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		content TEXT DEFAULT '',
+		type TEXT NOT NULL DEFAULT 'task',
+		start_date DATETIME NOT NULL,
+		end_date DATETIME NOT NULL,
+		is_lunar INTEGER DEFAULT 0,
+		hide_year INTEGER DEFAULT 0,
+		cron_expr TEXT DEFAULT '',
+		repeat_count INTEGER DEFAULT 1,
+		current_repeat INTEGER DEFAULT 1,
+		advance_remind INTEGER DEFAULT 15,
+		remind_at_start INTEGER DEFAULT 1,
+		remind_at_end INTEGER DEFAULT 1,
+		start_remind_triggered INTEGER DEFAULT 0,
+		is_completed INTEGER DEFAULT 0,
+		completed_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_todos_date ON todos(start_date, end_date);
+	CREATE INDEX IF NOT EXISTS idx_todos_type ON todos(type);
+	CREATE INDEX IF NOT EXISTS idx_todos_completed ON todos(is_completed);
+	`
+
+	// 创建附件表
+	attachmentTable := `
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL,
+		file_name TEXT NOT NULL,
+		storage_path TEXT NOT NULL,
+		file_size INTEGER DEFAULT 0,
+		mime_type TEXT DEFAULT '',
+		is_encrypted INTEGER DEFAULT 1,
+		encryption_key TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachments_todo ON attachments(todo_id);
+	`
+
+	// 创建设置表
+	settingsTable := `
+	CREATE TABLE IF NOT EXISTS settings (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		enable_widget INTEGER DEFAULT 1,
+		enable_auto_start INTEGER DEFAULT 0,
+		minimize_to_tray INTEGER DEFAULT 1,
+		notification_sound INTEGER DEFAULT 1,
+		notification_duration INTEGER DEFAULT 5,
+		widget_position TEXT DEFAULT 'bottom-right',
+		widget_opacity INTEGER DEFAULT 90,
+		theme TEXT DEFAULT 'light'
+	);
+	INSERT OR IGNORE INTO settings (id) VALUES (1);
+	`
+
+	// 创建通知记录表
+	notificationTable := `
+	CREATE TABLE IF NOT EXISTS notification_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL,
+		notified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_notification_todo ON notification_logs(todo_id);
+	`
+
+	// 创建待办实例表（存储每次循环执行的实例）
+	todoInstanceTable := `
+	CREATE TABLE IF NOT EXISTS todo_instances (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL,
+		instance_index INTEGER NOT NULL,
+		scheduled_at DATETIME NOT NULL,
+		is_completed INTEGER DEFAULT 0,
+		completed_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+		UNIQUE(todo_id, instance_index)
+	);
+	CREATE INDEX IF NOT EXISTS idx_todo_instances_todo ON todo_instances(todo_id);
+	CREATE INDEX IF NOT EXISTS idx_todo_instances_scheduled ON todo_instances(scheduled_at);
+	CREATE INDEX IF NOT EXISTS idx_todo_instances_completed ON todo_instances(is_completed);
+	`
+
+	return execAll(tx, todoTable, attachmentTable, settingsTable, notificationTable, todoInstanceTable)
+}
+
+// migrateSettingsColumns 设置表添加 backend_url 和 notification_sound_file 字段
+func migrateSettingsColumns(tx *sql.Tx) error {
+	return addColumns(tx, "settings", [][2]string{
+		{"backend_url", "TEXT DEFAULT ''"},
+		{"notification_sound_file", "TEXT DEFAULT ''"},
+	})
+}
+
+// migrateRepeatIndex 待办表添加 repeat_index 和 repeat_total 字段
+func migrateRepeatIndex(tx *sql.Tx) error {
+	return addColumns(tx, "todos", [][2]string{
+		{"repeat_index", "INTEGER DEFAULT 0"},
+		{"repeat_total", "INTEGER DEFAULT 0"},
+	})
+}
+
+// migrateRecurringSeries 循环待办改为单条系列记录，存储循环规则
+func migrateRecurringSeries(tx *sql.Tx) error {
+	return addColumns(tx, "todos", [][2]string{
+		{"repeat_type", "TEXT DEFAULT 'none'"},
+		{"repeat_end_date", "DATETIME"},
+		{"duration_minutes", "INTEGER DEFAULT 0"},
+	})
+}
+
+// migrateInstanceOverrides 循环实例表存储完成状态和单次修改
+func migrateInstanceOverrides(tx *sql.Tx) error {
+	return addColumns(tx, "todo_instances", [][2]string{
+		{"is_cancelled", "INTEGER DEFAULT 0"},
+		{"start_remind_triggered", "INTEGER DEFAULT 0"},
+		{"title", "TEXT"},
+		{"content", "TEXT"},
+		{"start_date", "DATETIME"},
+		{"end_date", "DATETIME"},
+		{"updated_at", "DATETIME"},
+	})
+}
+
+// migrateFullTextSearch 创建标题、内容和附件名的全文索引
+// 使用 trigram 分词以支持中文等无空格分隔的文本，通过触发器与 todos、attachments 保持同步
+func migrateFullTextSearch(tx *sql.Tx) error {
+	ftsTable := `
+	CREATE VIRTUAL TABLE IF NOT EXISTS todo_fts USING fts5(
+		title, content, attachments,
+		tokenize = 'trigram'
+	);
+	`
+
+	todoTriggers := `
+	CREATE TRIGGER IF NOT EXISTS todos_fts_insert AFTER INSERT ON todos BEGIN
+		INSERT INTO todo_fts (rowid, title, content, attachments)
+		VALUES (new.id, new.title, COALESCE(new.content, ''),
+			(SELECT COALESCE(group_concat(file_name, ' '), '') FROM attachments WHERE todo_id = new.id));
+	END;
+	CREATE TRIGGER IF NOT EXISTS todos_fts_update AFTER UPDATE OF title, content ON todos BEGIN
+		UPDATE todo_fts SET title = new.title, content = COALESCE(new.content, '') WHERE rowid = new.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS todos_fts_delete AFTER DELETE ON todos BEGIN
+		DELETE FROM todo_fts WHERE rowid = old.id;
+	END;
+	`
+
+	attachmentTriggers := `
+	CREATE TRIGGER IF NOT EXISTS attachments_fts_insert AFTER INSERT ON attachments BEGIN
+		UPDATE todo_fts SET attachments =
+			(SELECT COALESCE(group_concat(file_name, ' '), '') FROM attachments WHERE todo_id = new.todo_id)
+		WHERE rowid = new.todo_id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS attachments_fts_update AFTER UPDATE OF file_name, todo_id ON attachments BEGIN
+		UPDATE todo_fts SET attachments =
+			(SELECT COALESCE(group_concat(file_name, ' '), '') FROM attachments WHERE todo_id = todo_fts.rowid)
+		WHERE rowid IN (old.todo_id, new.todo_id);
+	END;
+	CREATE TRIGGER IF NOT EXISTS attachments_fts_delete AFTER DELETE ON attachments BEGIN
+		UPDATE todo_fts SET attachments =
+			(SELECT COALESCE(group_concat(file_name, ' '), '') FROM attachments WHERE todo_id = old.todo_id)
+		WHERE rowid = old.todo_id;
+	END;
+	`
+
+	// 为已有数据建立索引
+	populate := `
+	DELETE FROM todo_fts;
+	INSERT INTO todo_fts (rowid, title, content, attachments)
+	SELECT id, title, COALESCE(content, ''),
+		(SELECT COALESCE(group_concat(file_name, ' '), '') FROM attachments WHERE todo_id = todos.id)
+	FROM todos;
+	`
+
+	return execAll(tx, ftsTable, todoTriggers, attachmentTriggers, populate)
+}
+
+// migrateTags 创建标签表和待办标签关联表
+func migrateTags(tx *sql.Tx) error {
+	tagTable := `
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		color TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	todoTagTable := `
+	CREATE TABLE IF NOT EXISTS todo_tags (
+		todo_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (todo_id, tag_id),
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_todo_tags_tag ON todo_tags(tag_id);
+	`
+
+	return execAll(tx, tagTable, todoTagTable)
+}
+
+// migrateTodoTypes 创建待办类型表，写入内置类型
+// 已有待办中不在内置类型内的类型作为自定义类型保留
+func migrateTodoTypes(tx *sql.Tx) error {
+	typeTable := `
+	CREATE TABLE IF NOT EXISTS todo_types (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		value TEXT NOT NULL UNIQUE,
+		label TEXT NOT NULL,
+		icon TEXT DEFAULT '',
+		color TEXT DEFAULT '',
+		advance_remind INTEGER DEFAULT 15,
+		remind_at_start INTEGER DEFAULT 1,
+		remind_at_end INTEGER DEFAULT 1,
+		sort_order INTEGER DEFAULT 0,
+		is_builtin INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	builtinTypes := `
+	INSERT OR IGNORE INTO todo_types (value, label, icon, color, sort_order, is_builtin) VALUES
+		('birthday', '生日', '🎂', '#FF6B6B', 1, 1),
+		('work', '工作', '💼', '#4ECDC4', 2, 1),
+		('anniversary', '纪念日', '💝', '#FF69B4', 3, 1),
+		('reminder', '提醒', '⏰', '#FFD93D', 4, 1),
+		('task', '任务', '✅', '#6BCB77', 5, 1);
+	`
+
+	existingTypes := `
+	INSERT OR IGNORE INTO todo_types (value, label, icon, color, sort_order)
+	SELECT DISTINCT type, type, '', '#999999', 100 FROM todos WHERE type != '';
+	`
+
+	return execAll(tx, typeTable, builtinTypes, existingTypes)
+}
+
+// migrateTodoPriority 待办表添加优先级字段
+func migrateTodoPriority(tx *sql.Tx) error {
+	if err := addColumns(tx, "todos", [][2]string{
+		{"priority", "INTEGER DEFAULT 0"},
+	}); err != nil {
+		return err
+	}
+	return execAll(tx, "CREATE INDEX IF NOT EXISTS idx_todos_priority ON todos(priority);")
+}
+
+// migrateSubtasks 创建子任务表，设置表添加子任务全部完成时自动完成待办的开关
+func migrateSubtasks(tx *sql.Tx) error {
+	subtaskTable := `
+	CREATE TABLE IF NOT EXISTS subtasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		is_completed INTEGER DEFAULT 0,
+		completed_at DATETIME,
+		sort_order INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_subtasks_todo ON subtasks(todo_id, sort_order);
+	`
+	if err := execAll(tx, subtaskTable); err != nil {
+		return err
+	}
+	return addColumns(tx, "settings", [][2]string{
+		{"auto_complete_parent", "INTEGER DEFAULT 1"},
+	})
+}
+
+// migrateTodoDependencies 创建待办依赖表，todo_id 需等待 depends_on_id 完成后才能开始
+func migrateTodoDependencies(tx *sql.Tx) error {
+	return execAll(tx, `
+	CREATE TABLE IF NOT EXISTS todo_dependencies (
+		todo_id INTEGER NOT NULL,
+		depends_on_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (todo_id, depends_on_id),
+		CHECK (todo_id != depends_on_id),
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+		FOREIGN KEY (depends_on_id) REFERENCES todos(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_todo_dependencies_depends_on ON todo_dependencies(depends_on_id);
+	`)
+}
+
+// migrateTrash 待办表添加 deleted_at 字段实现回收站，设置表添加回收站保留天数
+func migrateTrash(tx *sql.Tx) error {
+	if err := addColumns(tx, "todos", [][2]string{
+		{"deleted_at", "DATETIME"},
+	}); err != nil {
+		return err
+	}
+	if err := execAll(tx, "CREATE INDEX IF NOT EXISTS idx_todos_deleted ON todos(deleted_at);"); err != nil {
+		return err
+	}
+	return addColumns(tx, "settings", [][2]string{
+		{"trash_retention_days", "INTEGER DEFAULT 30"},
+	})
+}
+
+// migrateTodoRevisions 创建待办修改历史表
+func migrateTodoRevisions(tx *sql.Tx) error {
+	return execAll(tx, `
+	CREATE TABLE IF NOT EXISTS todo_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL,
+		changes TEXT NOT NULL,
+		origin TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_todo_revisions_todo ON todo_revisions(todo_id, id);
+	`)
+}
+
+// migrateOperationLog 创建撤销/重做的操作记录表
+// 每个操作对应多条明细，记录涉及的每个待办在操作前后的快照(JSON)，不存在时为 NULL
+func migrateOperationLog(tx *sql.Tx) error {
+	return execAll(tx, `
+	CREATE TABLE IF NOT EXISTS operation_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action TEXT NOT NULL,
+		origin TEXT DEFAULT '',
+		undone INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS operation_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		operation_id INTEGER NOT NULL,
+		todo_id INTEGER NOT NULL,
+		before_data TEXT,
+		after_data TEXT,
+		FOREIGN KEY (operation_id) REFERENCES operation_log(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_operation_items_operation ON operation_items(operation_id);
+	CREATE INDEX IF NOT EXISTS idx_operation_items_todo ON operation_items(todo_id);
+	`)
+}
+
+// migrateUTCTimes 将所有时间字段改写为 UTC，并为待办增加时区
+// 已有待办绑定本机时区，无法确定本机时区名称时改为浮动时间(墙上时间不变)
+func migrateUTCTimes(tx *sql.Tx) error {
+	if err := addColumns(tx, "todos", [][2]string{
+		{"time_zone", "TEXT DEFAULT '" + models.TimeZoneFloating + "'"},
+	}); err != nil {
+		return err
+	}
+
+	tables, err := queryStrings(tx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT LIKE 'todo_fts%'")
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		columns, err := queryStrings(tx, "SELECT name FROM pragma_table_info(?) WHERE upper(type) IN ('DATE', 'DATETIME', 'TIMESTAMP')", table)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			// 读出时驱动按原格式解析，写回时统一为 UTC
+			if err := rewriteTimes(tx, "SELECT rowid, "+column+" FROM "+table+" WHERE "+column+" IS NOT NULL",
+				"UPDATE "+table+" SET "+column+" = ? WHERE rowid = ?", func(t time.Time) time.Time { return t }); err != nil {
+				return err
+			}
+		}
+	}
+
+	zone := utils.LocalZoneName()
+	if zone != "" {
+		_, err := tx.Exec("UPDATE todos SET time_zone = ?", zone)
+		return err
+	}
+	for _, column := range []string{"start_date", "end_date", "repeat_end_date"} {
+		if err := rewriteTimes(tx, "SELECT id, "+column+" FROM todos WHERE "+column+" IS NOT NULL",
+			"UPDATE todos SET "+column+" = ? WHERE id = ?", wallClockUTC); err != nil {
+			return err
+		}
+	}
+	for _, column := range []string{"scheduled_at", "start_date", "end_date"} {
+		if err := rewriteTimes(tx, "SELECT id, "+column+" FROM todo_instances WHERE "+column+" IS NOT NULL",
+			"UPDATE todo_instances SET "+column+" = ? WHERE id = ?", wallClockUTC); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryStrings 查询单列字符串
+func queryStrings(tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// rewriteTimes 读出 (id, 时间) 并按 convert 转换后写回，无法解析为时间的值保持不变
+func rewriteTimes(tx *sql.Tx, query, update string, convert func(time.Time) time.Time) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	values := map[int64]time.Time{}
+	for rows.Next() {
+		var id int64
+		var value interface{}
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		if t, ok := value.(time.Time); ok {
+			values[id] = t
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, t := range values {
+		if _, err := tx.Exec(update, convert(t), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateAllDay 待办表添加全天标记，设置表添加全天待办的提醒时间
+func migrateAllDay(tx *sql.Tx) error {
+	if err := addColumns(tx, "todos", [][2]string{
+		{"all_day", "INTEGER DEFAULT 0"},
+	}); err != nil {
+		return err
+	}
+	return addColumns(tx, "settings", [][2]string{
+		{"all_day_remind_time", "TEXT DEFAULT '09:00'"},
+		{"all_day_remind_days_before", "INTEGER DEFAULT 0"},
+	})
+}
+
+// migrateCalendars 创建日历表和默认日历，已有待办归入默认日历
+func migrateCalendars(tx *sql.Tx) error {
+	calendarTable := `
+	CREATE TABLE IF NOT EXISTS calendars (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		color TEXT DEFAULT '',
+		default_type TEXT DEFAULT '',
+		is_visible INTEGER DEFAULT 1,
+		is_muted INTEGER DEFAULT 0,
+		sort_order INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	defaultCalendar := `
+	INSERT INTO calendars (name, color, sort_order)
+	SELECT '个人', '#409EFF', 1 WHERE NOT EXISTS (SELECT 1 FROM calendars);
+	`
+
+	if err := execAll(tx, calendarTable, defaultCalendar); err != nil {
+		return err
+	}
+	if err := addColumns(tx, "todos", [][2]string{
+		{"calendar_id", "INTEGER"},
+	}); err != nil {
+		return err
+	}
+	return execAll(tx,
+		"UPDATE todos SET calendar_id = (SELECT MIN(id) FROM calendars) WHERE calendar_id IS NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_todos_calendar ON todos(calendar_id);",
+	)
+}
+
+// migrateMeetingDetails 待办表添加地点、会议链接和参会人，并重建全文索引加入这些字段
+// FTS5 表不能添加列，需要删除后重建
+func migrateMeetingDetails(tx *sql.Tx) error {
+	if err := addColumns(tx, "todos", [][2]string{
+		{"location", "TEXT DEFAULT ''"},
+		{"meeting_url", "TEXT DEFAULT ''"},
+		{"attendees", "TEXT DEFAULT '[]'"},
+	}); err != nil {
+		return err
+	}
+
+	// 参会人以 "姓名 邮箱" 的形式加入索引
+	details := func(row string) string {
+		return `COALESCE(` + row + `.location, '') || ' ' || COALESCE(` + row + `.meeting_url, '') || ' ' ||
+			COALESCE((SELECT group_concat(COALESCE(json_extract(value, '$.name'), '') || ' ' || COALESCE(json_extract(value, '$.email'), ''), ' ')
+				FROM json_each(CASE WHEN json_valid(` + row + `.attendees) THEN ` + row + `.attendees ELSE '[]' END)), '')`
+	}
+
+	ftsTable := `
+	DROP TRIGGER IF EXISTS todos_fts_insert;
+	DROP TRIGGER IF EXISTS todos_fts_update;
+	DROP TABLE IF EXISTS todo_fts;
+	CREATE VIRTUAL TABLE todo_fts USING fts5(
+		title, content, attachments, details,
+		tokenize = 'trigram'
+	);
+	`
+
+	todoTriggers := `
+	CREATE TRIGGER todos_fts_insert AFTER INSERT ON todos BEGIN
+		INSERT INTO todo_fts (rowid, title, content, attachments, details)
+		VALUES (new.id, new.title, COALESCE(new.content, ''),
+			(SELECT COALESCE(group_concat(file_name, ' '), '') FROM attachments WHERE todo_id = new.id),
+			` + details("new") + `);
+	END;
+	CREATE TRIGGER todos_fts_update AFTER UPDATE OF title, content, location, meeting_url, attendees ON todos BEGIN
+		UPDATE todo_fts SET title = new.title, content = COALESCE(new.content, ''),
+			details = ` + details("new") + `
+		WHERE rowid = new.id;
+	END;
+	`
+
+	populate := `
+	INSERT INTO todo_fts (rowid, title, content, attachments, details)
+	SELECT id, title, COALESCE(content, ''),
+		(SELECT COALESCE(group_concat(file_name, ' '), '') FROM attachments WHERE todo_id = todos.id),
+		` + details("todos") + `
+	FROM todos;
+	`
+
+	return execAll(tx, ftsTable, todoTriggers, populate)
+}
+
+// migrateCustomFields 创建自定义字段定义表和待办字段值表
+func migrateCustomFields(tx *sql.Tx) error {
+	return execAll(tx, `
+	CREATE TABLE IF NOT EXISTS custom_fields (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_type TEXT NOT NULL,
+		key TEXT NOT NULL,
+		label TEXT NOT NULL,
+		field_type TEXT NOT NULL,
+		options TEXT DEFAULT '[]',
+		required INTEGER DEFAULT 0,
+		sort_order INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (todo_type, key)
+	);
+	`, `
+	CREATE TABLE IF NOT EXISTS todo_field_values (
+		todo_id INTEGER NOT NULL,
+		field_id INTEGER NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (todo_id, field_id),
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+		FOREIGN KEY (field_id) REFERENCES custom_fields(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_todo_field_values_field ON todo_field_values(field_id, value);
+	`)
+}
+
+// migrateTimeEntries 创建计时记录表，添加番茄钟设置
+func migrateTimeEntries(tx *sql.Tx) error {
+	err := execAll(tx, `
+	CREATE TABLE IF NOT EXISTS time_entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME,
+		planned_minutes INTEGER DEFAULT 0,
+		note TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_time_entries_todo ON time_entries(todo_id);
+	CREATE INDEX IF NOT EXISTS idx_time_entries_started ON time_entries(started_at);
+	CREATE INDEX IF NOT EXISTS idx_time_entries_running ON time_entries(ended_at) WHERE ended_at IS NULL;
+	`)
+	if err != nil {
+		return err
+	}
+	return addColumns(tx, "settings", [][2]string{
+		{"pomodoro_work_minutes", "INTEGER DEFAULT 25"},
+		{"pomodoro_break_minutes", "INTEGER DEFAULT 5"},
+		{"pomodoro_long_break_minutes", "INTEGER DEFAULT 15"},
+		{"pomodoro_long_break_every", "INTEGER DEFAULT 4"},
+	})
+}
+
+// migrateReminderSnoozes 创建提醒推迟表，每个待办实例的每种提醒最多推迟一次
+func migrateReminderSnoozes(tx *sql.Tx) error {
+	return execAll(tx, `
+	CREATE TABLE IF NOT EXISTS reminder_snoozes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL,
+		repeat_index INTEGER NOT NULL DEFAULT 0,
+		reminder TEXT NOT NULL,
+		fire_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (todo_id, repeat_index, reminder),
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_reminder_snoozes_fire ON reminder_snoozes(fire_at);
+	`)
+}
+
+// migrateNotificationLogs 为通知记录添加提醒内容和用户的处理结果
+func migrateNotificationLogs(tx *sql.Tx) error {
+	if err := addColumns(tx, "notification_logs", [][2]string{
+		{"repeat_index", "INTEGER DEFAULT 0"},
+		{"reminder", "TEXT DEFAULT ''"},
+		{"title", "TEXT DEFAULT ''"},
+		{"message", "TEXT DEFAULT ''"},
+		{"outcome", "TEXT DEFAULT 'shown'"},
+		{"responded_at", "DATETIME"},
+	}); err != nil {
+		return err
+	}
+	return execAll(tx, `
+	CREATE INDEX IF NOT EXISTS idx_notification_notified ON notification_logs(notified_at);
+	`)
+}
+
+// migrateFiredReminders 创建已发送提醒表，记录在数据库中使重启后和多个进程之间不会重复提醒
+func migrateFiredReminders(tx *sql.Tx) error {
+	return execAll(tx, `
+	CREATE TABLE IF NOT EXISTS fired_reminders (
+		key TEXT PRIMARY KEY,
+		todo_id INTEGER NOT NULL,
+		fired_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_fired_reminders_fired ON fired_reminders(fired_at);
+	`)
+}
+
+// migrateMissedReminders 添加错过提醒的补发时间设置和上次检查提醒的时间
+func migrateMissedReminders(tx *sql.Tx) error {
+	return addColumns(tx, "settings", [][2]string{
+		{"missed_reminder_grace_minutes", "INTEGER DEFAULT 60"},
+		{"last_reminder_check_at", "DATETIME"},
+	})
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"todo-calendar/internal/models"
+)
+
+// baselineSchema 引入迁移框架之前 createTables 创建的表结构
+const baselineSchema = `
+CREATE TABLE todos (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	content TEXT DEFAULT '',
+	type TEXT NOT NULL DEFAULT 'task',
+	start_date DATETIME NOT NULL,
+	end_date DATETIME NOT NULL,
+	is_lunar INTEGER DEFAULT 0,
+	hide_year INTEGER DEFAULT 0,
+	cron_expr TEXT DEFAULT '',
+	repeat_count INTEGER DEFAULT 1,
+	current_repeat INTEGER DEFAULT 1,
+	advance_remind INTEGER DEFAULT 15,
+	remind_at_start INTEGER DEFAULT 1,
+	remind_at_end INTEGER DEFAULT 1,
+	start_remind_triggered INTEGER DEFAULT 0,
+	is_completed INTEGER DEFAULT 0,
+	completed_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	repeat_index INTEGER DEFAULT 0,
+	repeat_total INTEGER DEFAULT 0
+);
+CREATE TABLE attachments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	file_name TEXT NOT NULL,
+	storage_path TEXT NOT NULL,
+	file_size INTEGER DEFAULT 0,
+	mime_type TEXT DEFAULT '',
+	is_encrypted INTEGER DEFAULT 1,
+	encryption_key TEXT DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE settings (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	enable_widget INTEGER DEFAULT 1,
+	enable_auto_start INTEGER DEFAULT 0,
+	minimize_to_tray INTEGER DEFAULT 1,
+	notification_sound INTEGER DEFAULT 1,
+	notification_duration INTEGER DEFAULT 5,
+	widget_position TEXT DEFAULT 'bottom-right',
+	widget_opacity INTEGER DEFAULT 90,
+	theme TEXT DEFAULT 'light',
+	backend_url TEXT DEFAULT '',
+	notification_sound_file TEXT DEFAULT ''
+);
+INSERT INTO settings (id, theme) VALUES (1, 'dark');
+CREATE TABLE notification_logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	notified_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE todo_instances (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	instance_index INTEGER NOT NULL,
+	scheduled_at DATETIME NOT NULL,
+	is_completed INTEGER DEFAULT 0,
+	completed_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(todo_id, instance_index)
+);
+INSERT INTO todos (title, content, type, start_date, end_date, repeat_index, repeat_total)
+VALUES ('旧待办', '迁移前的数据', 'work', '2024-01-01 09:00:00', '2024-01-01 10:00:00', 1, 1);
+`
+
+// 从引入迁移框架之前的数据库升级到最新版本，数据保留并在升级前备份
+func TestMigrateFromBaselineSchema(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "todo_calendar.db")
+	legacy, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("创建旧数据库失败: %v", err)
+	}
+	if _, err := legacy.Exec(baselineSchema); err != nil {
+		t.Fatalf("创建旧表结构失败: %v", err)
+	}
+	legacy.Close()
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("升级数据库失败: %v", err)
+	}
+	defer func() { db.Close() }()
+
+	latest := migrations[len(migrations)-1].version
+	if version, err := schemaVersion(db); err != nil || version != latest {
+		t.Fatalf("升级后版本为 %d (%v)，应为 %d", version, err, latest)
+	}
+	backups, _ := os.ReadDir(filepath.Join(dir, "backups"))
+	if len(backups) != 1 {
+		t.Errorf("升级前应备份一次，实际有 %d 个备份", len(backups))
+	}
+
+	todo, err := NewTodoRepository(db).GetByID(1)
+	if err != nil {
+		t.Fatalf("读取旧待办失败: %v", err)
+	}
+	if todo.Title != "旧待办" || todo.Content != "迁移前的数据" || todo.Type != "work" {
+		t.Errorf("旧待办内容为 %q %q %q", todo.Title, todo.Content, todo.Type)
+	}
+	if start := todo.StartDate.Time; start.Hour() != 9 || start.Minute() != 0 || start.Day() != 1 {
+		t.Errorf("旧待办开始时间为 %v，墙上时间应保持 2024-01-01 09:00", start)
+	}
+	if todo.RepeatType != models.RepeatTypeNone || todo.RepeatIndex != 1 {
+		t.Errorf("旧待办的循环信息为 %q %d", todo.RepeatType, todo.RepeatIndex)
+	}
+
+	settings, err := NewSettingsRepository(db).Get()
+	if err != nil {
+		t.Fatalf("读取设置失败: %v", err)
+	}
+	if settings.Theme != "dark" {
+		t.Errorf("原有设置主题为 %q，应保留 dark", settings.Theme)
+	}
+	if settings.AllDayRemindTime != models.DefaultAllDayRemindTime || settings.MissedReminderGraceMinutes != models.DefaultMissedReminderGraceMinutes {
+		t.Errorf("新增设置为 %q %d，应为默认值", settings.AllDayRemindTime, settings.MissedReminderGraceMinutes)
+	}
+	if types, err := NewTodoTypeRepository(db).List(); err != nil || len(types) == 0 {
+		t.Errorf("升级后应有内置待办类型: %v", err)
+	}
+
+	// 已是最新版本时再次打开不再迁移和备份
+	db.Close()
+	db, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("再次打开数据库失败: %v", err)
+	}
+	backups, _ = os.ReadDir(filepath.Join(dir, "backups"))
+	if len(backups) != 1 {
+		t.Errorf("再次打开后有 %d 个备份，不应再备份", len(backups))
+	}
+}
+
+// 数据库版本高于程序支持的版本时拒绝打开
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "todo_calendar.db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	latest := migrations[len(migrations)-1].version
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, 'future')", latest+1); err != nil {
+		t.Fatalf("写入版本失败: %v", err)
+	}
+	db.Close()
+
+	if db, err := Open(dbPath); err == nil {
+		db.Close()
+		t.Fatalf("打开更高版本的数据库应失败")
+	}
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// notificationLogDefaultLimit 通知记录默认返回条数
+const notificationLogDefaultLimit = 200
+
+// NotificationLogRepository 通知记录仓库
+type NotificationLogRepository struct {
+	db dbExecutor
+}
+
+// NewNotificationLogRepository 创建通知记录仓库实例
+func NewNotificationLogRepository(db *sql.DB) *NotificationLogRepository {
+	return &NotificationLogRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *NotificationLogRepository) WithTx(tx *sql.Tx) *NotificationLogRepository {
+	return &NotificationLogRepository{db: tx}
+}
+
+// Create 记录一次已发送的通知，处理结果为未处理
+func (r *NotificationLogRepository) Create(log *models.NotificationLog) (int64, error) {
+	query := `
+		INSERT INTO notification_logs (todo_id, repeat_index, reminder, title, message, outcome, notified_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := r.db.Exec(query,
+		log.TodoID,
+		log.RepeatIndex,
+		log.Reminder,
+		log.Title,
+		log.Message,
+		models.OutcomeShown,
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Respond 记录用户对通知的处理，只记录第一次处理，返回是否记录成功
+func (r *NotificationLogRepository) Respond(id int64, outcome string) (bool, error) {
+	result, err := r.db.Exec("UPDATE notification_logs SET outcome = ?, responded_at = ? WHERE id = ? AND outcome = ?",
+		outcome, time.Now(), id, models.OutcomeShown)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// List 按筛选条件获取通知记录，最新的在前；start/end 为零值时不限制
+func (r *NotificationLogRepository) List(filter models.NotificationLogFilter, start, end time.Time) ([]models.NotificationLog, error) {
+	where := "WHERE 1 = 1"
+	args := []interface{}{}
+	if filter.TodoID > 0 {
+		where += " AND todo_id = ?"
+		args = append(args, filter.TodoID)
+	}
+	if !start.IsZero() {
+		where += " AND notified_at >= ?"
+		args = append(args, start)
+	}
+	if !end.IsZero() {
+		where += " AND notified_at < ?"
+		args = append(args, end)
+	}
+	if filter.Outcome != "" {
+		where += " AND COALESCE(outcome, ?) = ?"
+		args = append(args, models.OutcomeShown, filter.Outcome)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = notificationLogDefaultLimit
+	}
+	args = append(args, limit)
+
+	query := `
+		SELECT id, todo_id, COALESCE(repeat_index, 0), COALESCE(reminder, ''), COALESCE(title, ''),
+			   COALESCE(message, ''), COALESCE(outcome, '` + models.OutcomeShown + `'), notified_at, responded_at
+		FROM notification_logs ` + where + `
+		ORDER BY notified_at DESC, id DESC
+		LIMIT ?
+	`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []models.NotificationLog{}
+	for rows.Next() {
+		var log models.NotificationLog
+		var respondedAt sql.NullTime
+		err := rows.Scan(
+			&log.ID,
+			&log.TodoID,
+			&log.RepeatIndex,
+			&log.Reminder,
+			&log.Title,
+			&log.Message,
+			&log.Outcome,
+			&log.NotifiedAt,
+			&respondedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if respondedAt.Valid {
+			log.RespondedAt = &models.FlexTime{Time: respondedAt.Time}
+		}
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
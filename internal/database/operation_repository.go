@@ -0,0 +1,658 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// maxOperations 保留的可撤销操作数量
+const maxOperations = 100
+
+// snapshotTable 待办快照包含的表，key 为关联待办的字段
+type snapshotTable struct {
+	name    string
+	key     string
+	orderBy string
+}
+
+// snapshotTables 快照包含的表，todos 必须排在第一位
+var snapshotTables = []snapshotTable{
+	{"todos", "id", "id"},
+	{"todo_instances", "todo_id", "instance_index"},
+	{"todo_tags", "todo_id", "tag_id"},
+	{"todo_field_values", "todo_id", "field_id"},
+	{"attachments", "todo_id", "id"},
+}
+
+// volatileColumns 由提醒服务自动修改的字段，按表名索引，判断待办是否被修改过时忽略
+var volatileColumns = map[string]map[string]bool{
+	"todos":          {"start_remind_triggered": true},
+	"todo_instances": {"start_remind_triggered": true, "updated_at": true},
+}
+
+// instanceStateColumns 循环实例中由用户修改的字段
+// 这些字段均为空时，实例记录只保存提醒状态，是提醒服务自行插入的
+var instanceStateColumns = []string{"is_completed", "completed_at", "is_cancelled", "title", "content", "start_date", "end_date"}
+
+// snapshotValue 快照中的字段值，记录类型以便原样写回
+type snapshotValue struct {
+	Kind  string      `json:"t"` // null/int/float/text/blob/time
+	Value interface{} `json:"v,omitempty"`
+}
+
+// snapshotRow 快照中的一行，按字段名索引
+type snapshotRow map[string]snapshotValue
+
+// todoSnapshot 待办及其实例、标签关联、附件记录的快照，按表名索引
+// 待办不存在时快照为 nil
+type todoSnapshot map[string][]snapshotRow
+
+// OperationRepository 操作记录仓库，用于撤销/重做
+type OperationRepository struct {
+	db     dbExecutor
+	origin string
+}
+
+// NewOperationRepository 创建操作记录仓库实例
+func NewOperationRepository(db *sql.DB) *OperationRepository {
+	return &OperationRepository{db: db, origin: models.OriginMain}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *OperationRepository) WithTx(tx *sql.Tx) *OperationRepository {
+	return &OperationRepository{db: tx, origin: r.origin}
+}
+
+// SetOrigin 设置操作来源
+func (r *OperationRepository) SetOrigin(origin string) {
+	r.origin = origin
+}
+
+// Track 记录 fn 对待办的修改，需在事务中调用
+// todoIDs 为修改前已存在的待办，fn 返回新创建的待办ID
+// 返回因清理旧操作而不再被引用的附件文件，调用方在事务提交后删除
+func (r *OperationRepository) Track(action string, todoIDs []int64, fn func() ([]int64, error)) ([]string, error) {
+	before := make(map[int64]todoSnapshot)
+	for _, id := range todoIDs {
+		snapshot, err := r.snapshot(id)
+		if err != nil {
+			return nil, err
+		}
+		before[id] = snapshot
+	}
+
+	created, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := append(append([]int64{}, todoIDs...), created...)
+	type item struct {
+		todoID        int64
+		before, after []byte
+	}
+	items := []item{}
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		after, err := r.snapshot(id)
+		if err != nil {
+			return nil, err
+		}
+		beforeData, err := encodeSnapshot(before[id])
+		if err != nil {
+			return nil, err
+		}
+		afterData, err := encodeSnapshot(after)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(beforeData, afterData) {
+			items = append(items, item{id, beforeData, afterData})
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	// 新操作使已撤销的操作无法再重做
+	paths, err := r.deleteOperations("undone = 1")
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec("INSERT INTO operation_log (action, origin, undone, created_at) VALUES (?, ?, 0, ?)",
+		action, r.origin, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	opID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		if _, err := r.db.Exec("INSERT INTO operation_items (operation_id, todo_id, before_data, after_data) VALUES (?, ?, ?, ?)",
+			opID, it.todoID, nullableJSON(it.before), nullableJSON(it.after)); err != nil {
+			return nil, err
+		}
+	}
+
+	expired, err := r.deleteOperations(`id <= (SELECT id FROM operation_log ORDER BY id DESC LIMIT 1 OFFSET ?)`, maxOperations)
+	if err != nil {
+		return nil, err
+	}
+	return r.unreferencedFiles(append(paths, expired...))
+}
+
+// Undo 撤销最近一次未撤销的操作，需在事务中调用
+// 涉及的待办在操作之后被修改过时返回错误
+func (r *OperationRepository) Undo() (*models.Operation, error) {
+	op, err := r.getOperation("undone = 0 ORDER BY id DESC")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("没有可撤销的操作")
+		}
+		return nil, err
+	}
+	if err := r.apply(op.ID, "after_data", "before_data"); err != nil {
+		return nil, err
+	}
+	if _, err := r.db.Exec("UPDATE operation_log SET undone = 1 WHERE id = ?", op.ID); err != nil {
+		return nil, err
+	}
+	op.Undone = true
+	return op, nil
+}
+
+// Redo 重做最早一次已撤销的操作，需在事务中调用
+func (r *OperationRepository) Redo() (*models.Operation, error) {
+	op, err := r.getOperation("undone = 1 ORDER BY id ASC")
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("没有可重做的操作")
+		}
+		return nil, err
+	}
+	if err := r.apply(op.ID, "before_data", "after_data"); err != nil {
+		return nil, err
+	}
+	if _, err := r.db.Exec("UPDATE operation_log SET undone = 0 WHERE id = ?", op.ID); err != nil {
+		return nil, err
+	}
+	op.Undone = false
+	return op, nil
+}
+
+// State 获取下一次撤销和重做的操作
+func (r *OperationRepository) State() (*models.UndoState, error) {
+	state := &models.UndoState{}
+	var err error
+	if state.Undo, err = r.getOperation("undone = 0 ORDER BY id DESC"); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if state.Redo, err = r.getOperation("undone = 1 ORDER BY id ASC"); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return state, nil
+}
+
+// DeleteByTodoIDs 删除涉及指定待办的操作(待办被彻底删除后无法再撤销)
+// 返回不再被引用的附件文件，调用方在事务提交后删除
+func (r *OperationRepository) DeleteByTodoIDs(todoIDs []int64) ([]string, error) {
+	if len(todoIDs) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(todoIDs))
+	for i, id := range todoIDs {
+		args[i] = id
+	}
+	paths, err := r.deleteOperations("id IN (SELECT operation_id FROM operation_items WHERE todo_id IN ("+placeholders(len(args))+"))", args...)
+	if err != nil {
+		return nil, err
+	}
+	return r.unreferencedFiles(paths)
+}
+
+// getOperation 获取满足条件的第一个操作
+func (r *OperationRepository) getOperation(condition string) (*models.Operation, error) {
+	op := &models.Operation{}
+	err := r.db.QueryRow("SELECT id, action, COALESCE(origin, ''), undone, created_at FROM operation_log WHERE "+condition+" LIMIT 1").
+		Scan(&op.ID, &op.Action, &op.Origin, &op.Undone, &op.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query("SELECT todo_id FROM operation_items WHERE operation_id = ? ORDER BY id", op.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	op.TodoIDs = []int64{}
+	for rows.Next() {
+		var todoID int64
+		if err := rows.Scan(&todoID); err != nil {
+			return nil, err
+		}
+		op.TodoIDs = append(op.TodoIDs, todoID)
+	}
+	return op, rows.Err()
+}
+
+// apply 校验待办当前状态与 expectColumn 一致后，恢复为 targetColumn 的快照
+func (r *OperationRepository) apply(opID int64, expectColumn, targetColumn string) error {
+	rows, err := r.db.Query("SELECT todo_id, "+expectColumn+", "+targetColumn+" FROM operation_items WHERE operation_id = ? ORDER BY id DESC", opID)
+	if err != nil {
+		return err
+	}
+	type item struct {
+		todoID         int64
+		expect, target sql.NullString
+	}
+	items := []item{}
+	for rows.Next() {
+		var it item
+		if err := rows.Scan(&it.todoID, &it.expect, &it.target); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, it := range items {
+		expect, err := decodeSnapshot(it.expect)
+		if err != nil {
+			return err
+		}
+		current, err := r.snapshot(it.todoID)
+		if err != nil {
+			return err
+		}
+		if !sameSnapshot(current, expect) {
+			return fmt.Errorf("待办 %d 在操作之后已被修改，无法撤销或重做", it.todoID)
+		}
+	}
+	for _, it := range items {
+		target, err := decodeSnapshot(it.target)
+		if err != nil {
+			return err
+		}
+		if err := r.restore(it.todoID, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteOperations 删除满足条件的操作，返回其快照中引用的附件文件
+func (r *OperationRepository) deleteOperations(condition string, args ...interface{}) ([]string, error) {
+	query := `
+		SELECT COALESCE(before_data, ''), COALESCE(after_data, '') FROM operation_items
+		WHERE operation_id IN (SELECT id FROM operation_log WHERE ` + condition + `)
+	`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for rows.Next() {
+		var beforeData, afterData string
+		if err := rows.Scan(&beforeData, &afterData); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, data := range []string{beforeData, afterData} {
+			snapshot, err := decodeSnapshot(sql.NullString{String: data, Valid: data != ""})
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			paths = append(paths, snapshot.attachmentPaths()...)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.db.Exec("DELETE FROM operation_items WHERE operation_id IN (SELECT id FROM operation_log WHERE "+condition+")", args...); err != nil {
+		return nil, err
+	}
+	if _, err := r.db.Exec("DELETE FROM operation_log WHERE "+condition, args...); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// unreferencedFiles 过滤出既不属于现有附件、也不在剩余操作快照中的文件
+func (r *OperationRepository) unreferencedFiles(paths []string) ([]string, error) {
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		quoted, err := json.Marshal(path)
+		if err != nil {
+			return nil, err
+		}
+		var count int
+		err = r.db.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM attachments WHERE storage_path = ?) +
+				   (SELECT COUNT(*) FROM operation_items WHERE instr(before_data, ?) > 0 OR instr(after_data, ?) > 0)
+		`, path, string(quoted), string(quoted)).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			result = append(result, path)
+		}
+	}
+	return result, nil
+}
+
+// snapshot 读取待办当前的快照
+func (r *OperationRepository) snapshot(todoID int64) (todoSnapshot, error) {
+	snapshot := todoSnapshot{}
+	for i, table := range snapshotTables {
+		rows, err := r.db.Query("SELECT * FROM "+table.name+" WHERE "+table.key+" = ? ORDER BY "+table.orderBy, todoID)
+		if err != nil {
+			return nil, err
+		}
+		tableRows, err := scanSnapshotRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 && len(tableRows) == 0 {
+			return nil, nil
+		}
+		snapshot[table.name] = tableRows
+	}
+	return snapshot, nil
+}
+
+// restore 将待办恢复为快照的状态，快照为 nil 时删除待办
+func (r *OperationRepository) restore(todoID int64, snapshot todoSnapshot) error {
+	var exists bool
+	if err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM todos WHERE id = ?)", todoID).Scan(&exists); err != nil {
+		return err
+	}
+
+	for _, table := range snapshotTables[1:] {
+		if _, err := r.db.Exec("DELETE FROM "+table.name+" WHERE "+table.key+" = ?", todoID); err != nil {
+			return err
+		}
+	}
+
+	if snapshot == nil {
+		if !exists {
+			return nil
+		}
+		for _, stmt := range []string{
+			"DELETE FROM subtasks WHERE todo_id = ?",
+			"DELETE FROM todo_dependencies WHERE todo_id = ?1 OR depends_on_id = ?1",
+			"DELETE FROM todo_revisions WHERE todo_id = ?",
+			"DELETE FROM time_entries WHERE todo_id = ?",
+			"DELETE FROM reminder_snoozes WHERE todo_id = ?",
+			"DELETE FROM fired_reminders WHERE todo_id = ?",
+			"DELETE FROM todos WHERE id = ?",
+		} {
+			if _, err := r.db.Exec(stmt, todoID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 待办记录已存在时原地更新，保留子任务、依赖等未纳入快照的数据
+	todoRows := snapshot["todos"]
+	if len(todoRows) != 1 {
+		return fmt.Errorf("无效的待办快照")
+	}
+	if exists {
+		columns, values, err := todoRows[0].decode()
+		if err != nil {
+			return err
+		}
+		sets := make([]string, len(columns))
+		for i, column := range columns {
+			sets[i] = `"` + column + `" = ?`
+		}
+		if _, err := r.db.Exec("UPDATE todos SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(values, todoID)...); err != nil {
+			return err
+		}
+	} else if err := insertSnapshotRow(r.db, "todos", todoRows[0]); err != nil {
+		return err
+	}
+
+	for _, table := range snapshotTables[1:] {
+		for _, row := range snapshot[table.name] {
+			if err := insertSnapshotRow(r.db, table.name, row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// attachmentPaths 返回快照中附件记录的文件路径
+func (s todoSnapshot) attachmentPaths() []string {
+	var paths []string
+	for _, row := range s["attachments"] {
+		if path, ok := row["storage_path"].Value.(string); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// sameSnapshot 比较两个快照，忽略由提醒服务自动修改的字段
+func sameSnapshot(a, b todoSnapshot) bool {
+	strip := func(s todoSnapshot) []byte {
+		if s == nil {
+			return nil
+		}
+		stripped := todoSnapshot{}
+		for table, rows := range s {
+			for _, row := range rows {
+				if table == "todo_instances" && reminderOnlyInstance(row) {
+					continue
+				}
+				copied := snapshotRow{}
+				for column, value := range row {
+					if !volatileColumns[table][column] {
+						copied[column] = value
+					}
+				}
+				stripped[table] = append(stripped[table], copied)
+			}
+		}
+		data, _ := json.Marshal(stripped)
+		return data
+	}
+	return bytes.Equal(strip(a), strip(b))
+}
+
+// reminderOnlyInstance 判断实例记录是否只保存了提醒状态
+func reminderOnlyInstance(row snapshotRow) bool {
+	for _, column := range instanceStateColumns {
+		v, ok := row[column]
+		if !ok {
+			continue
+		}
+		value, err := decodeValue(v)
+		if err != nil {
+			return false
+		}
+		if value != nil && value != int64(0) {
+			return false
+		}
+	}
+	return true
+}
+
+// scanSnapshotRows 读取查询结果的所有行
+func scanSnapshotRows(rows *sql.Rows) ([]snapshotRow, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := []snapshotRow{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := snapshotRow{}
+		for i, column := range columns {
+			row[column] = encodeValue(values[i])
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// insertSnapshotRow 将快照中的一行插入表中
+func insertSnapshotRow(db dbExecutor, table string, row snapshotRow) error {
+	columns, values, err := row.decode()
+	if err != nil {
+		return err
+	}
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = `"` + column + `"`
+	}
+	_, err = db.Exec("INSERT INTO "+table+" ("+strings.Join(quoted, ", ")+") VALUES ("+placeholders(len(columns))+")", values...)
+	return err
+}
+
+// decode 按字段名排序返回字段和对应的值
+func (row snapshotRow) decode() ([]string, []interface{}, error) {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		value, err := decodeValue(row[column])
+		if err != nil {
+			return nil, nil, fmt.Errorf("字段 %s: %w", column, err)
+		}
+		values[i] = value
+	}
+	return columns, values, nil
+}
+
+// encodeValue 将数据库读出的值转换为快照值
+func encodeValue(v interface{}) snapshotValue {
+	switch value := v.(type) {
+	case nil:
+		return snapshotValue{Kind: "null"}
+	case int64:
+		return snapshotValue{Kind: "int", Value: value}
+	case float64:
+		return snapshotValue{Kind: "float", Value: value}
+	case bool:
+		if value {
+			return snapshotValue{Kind: "int", Value: int64(1)}
+		}
+		return snapshotValue{Kind: "int", Value: int64(0)}
+	case []byte:
+		return snapshotValue{Kind: "blob", Value: base64.StdEncoding.EncodeToString(value)}
+	case time.Time:
+		return snapshotValue{Kind: "time", Value: value.Format(time.RFC3339Nano)}
+	default:
+		return snapshotValue{Kind: "text", Value: fmt.Sprint(value)}
+	}
+}
+
+// decodeValue 将快照值转换为写入数据库的值
+// 时间转换为本地时区，与其他写入路径的存储格式一致
+func decodeValue(v snapshotValue) (interface{}, error) {
+	switch v.Kind {
+	case "null":
+		return nil, nil
+	case "int":
+		switch n := v.Value.(type) {
+		case json.Number:
+			return n.Int64()
+		case int64:
+			return n, nil
+		}
+	case "float":
+		switch n := v.Value.(type) {
+		case json.Number:
+			return n.Float64()
+		case float64:
+			return n, nil
+		}
+	case "blob":
+		if s, ok := v.Value.(string); ok {
+			return base64.StdEncoding.DecodeString(s)
+		}
+	case "time":
+		if s, ok := v.Value.(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, err
+			}
+			return t.In(time.Local), nil
+		}
+	case "text":
+		if s, ok := v.Value.(string); ok {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("无效的快照值: %v", v)
+}
+
+// encodeSnapshot 序列化快照，快照为 nil 时返回 nil
+func encodeSnapshot(s todoSnapshot) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// decodeSnapshot 反序列化快照，数字保留为 json.Number 以免精度丢失
+func decodeSnapshot(data sql.NullString) (todoSnapshot, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+	var s todoSnapshot
+	decoder := json.NewDecoder(strings.NewReader(data.String))
+	decoder.UseNumber()
+	if err := decoder.Decode(&s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// nullableJSON 空数据写入 NULL
+func nullableJSON(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
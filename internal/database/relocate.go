@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"todo-calendar/internal/datadir"
+)
+
+// relocated 数据库快照已导出到新目录，重启程序前不能再次迁移
+var relocated bool
+
+// MoveDataDir 将数据库、附件和自定义声音复制到新的数据目录，并记录新位置
+// 新数据库中的附件路径和声音路径改写为新位置；原目录保持不变，重启后生效
+// 导出快照后当前数据库只读，避免之后的修改只写入原目录而在重启后丢失
+func MoveDataDir(newDir string) (err error) {
+	if db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	if relocated {
+		return fmt.Errorf("数据目录已迁移，请重启程序")
+	}
+	if !datadir.CanRelocate() {
+		return fmt.Errorf("数据目录由命令行参数、环境变量或便携模式指定，无法迁移")
+	}
+
+	oldDir, err := datadir.Dir()
+	if err != nil {
+		return err
+	}
+	newDir, err = filepath.Abs(newDir)
+	if err != nil {
+		return err
+	}
+	if samePath(oldDir, newDir) {
+		return fmt.Errorf("新目录与当前数据目录相同")
+	}
+	if strings.HasPrefix(newDir+string(filepath.Separator), oldDir+string(filepath.Separator)) {
+		return fmt.Errorf("新目录不能位于当前数据目录内")
+	}
+
+	newDBPath := filepath.Join(newDir, datadir.DatabaseFileName)
+	if _, err := os.Stat(newDBPath); err == nil {
+		return fmt.Errorf("目标目录已存在数据库文件: %s", newDBPath)
+	}
+
+	// 失败时删除复制到目标目录的内容，目标目录原有的文件保持不变
+	created := []string{newDir}
+	if _, err := os.Stat(newDir); err == nil {
+		created = []string{newDBPath}
+		for _, sub := range []string{"attachments", "sounds"} {
+			if _, err := os.Stat(filepath.Join(newDir, sub)); os.IsNotExist(err) {
+				created = append(created, filepath.Join(newDir, sub))
+			}
+		}
+	}
+	defer func() {
+		if err != nil {
+			for _, path := range created {
+				os.RemoveAll(path)
+			}
+		}
+	}()
+	if err := os.MkdirAll(newDir, 0755); err != nil {
+		return fmt.Errorf("无法创建目标目录: %w", err)
+	}
+
+	// 复制文件
+	for _, sub := range []string{"attachments", "sounds"} {
+		if err := copyDir(filepath.Join(oldDir, sub), filepath.Join(newDir, sub)); err != nil {
+			return fmt.Errorf("复制%s失败: %w", sub, err)
+		}
+	}
+
+	if err := snapshotReadOnly(newDBPath); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			db.Exec("PRAGMA query_only = OFF")
+		}
+	}()
+
+	if err := rewritePaths(newDBPath, oldDir, newDir); err != nil {
+		return fmt.Errorf("更新文件路径失败: %w", err)
+	}
+	if err := datadir.SaveLocation(newDir); err != nil {
+		return err
+	}
+	relocated = true
+	return nil
+}
+
+// snapshotReadOnly 导出数据库的一致快照，随后将当前数据库设为只读
+// 连接池只有一个连接，导出和设为只读期间占用该连接，其他写入无法插入其间
+func snapshotReadOnly(dbPath string) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "VACUUM INTO ?", dbPath); err != nil {
+		return fmt.Errorf("复制数据库失败: %w", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "PRAGMA query_only = ON"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// rewritePaths 将新数据库中指向原数据目录的附件和声音路径改写为新目录
+func rewritePaths(dbPath, oldDir, newDir string) error {
+	newDB, err := sql.Open(driverName, dbPath+"?_time_format=sqlite")
+	if err != nil {
+		return err
+	}
+	defer newDB.Close()
+	newDB.SetMaxOpenConns(1)
+
+	return RunInTx(newDB, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT id, storage_path FROM attachments")
+		if err != nil {
+			return err
+		}
+		paths := map[int64]string{}
+		for rows.Next() {
+			var id int64
+			var path string
+			if err := rows.Scan(&id, &path); err != nil {
+				rows.Close()
+				return err
+			}
+			if rel, ok := relativeTo(oldDir, path); ok {
+				paths[id] = filepath.Join(newDir, rel)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for id, path := range paths {
+			if _, err := tx.Exec("UPDATE attachments SET storage_path = ? WHERE id = ?", path, id); err != nil {
+				return err
+			}
+		}
+
+		var soundFile string
+		if err := tx.QueryRow("SELECT COALESCE(notification_sound_file, '') FROM settings WHERE id = 1").Scan(&soundFile); err != nil {
+			return err
+		}
+		if rel, ok := relativeTo(oldDir, soundFile); ok {
+			_, err := tx.Exec("UPDATE settings SET notification_sound_file = ? WHERE id = 1", filepath.Join(newDir, rel))
+			return err
+		}
+		return nil
+	})
+}
+
+// relativeTo 如果 path 位于 dir 内，返回相对路径
+func relativeTo(dir, path string) (string, bool) {
+	if path == "" || !filepath.IsAbs(path) {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return rel, true
+}
+
+// samePath 比较两个路径是否相同(Windows 下不区分大小写)
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if filepath.Separator == '\\' {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// copyDir 复制目录下的所有文件(源目录不存在时跳过)
+func copyDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+		if entry.IsDir() {
+			if err := copyDir(srcPath, dstPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyFile(srcPath, dstPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile 复制单个文件，保留权限
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"todo-calendar/internal/datadir"
+)
+
+// 迁移失败时删除目标目录；迁移成功后原数据库只读，重启前不能再次迁移
+func TestMoveDataDir(t *testing.T) {
+	base := t.TempDir()
+	for _, key := range []string{"APPDATA", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "HOME"} {
+		t.Setenv(key, base)
+	}
+	t.Setenv(datadir.EnvDataDir, "")
+	t.Setenv(datadir.EnvPortable, "")
+	oldDir, err := datadir.Dir()
+	if err != nil || !datadir.CanRelocate() {
+		t.Skipf("测试环境的数据目录无法迁移: %v", err)
+	}
+	conn, err := Open(filepath.Join(oldDir, datadir.DatabaseFileName))
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	oldDB := db
+	db = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db, relocated = oldDB, false
+	})
+
+	// 附件目录位置是普通文件，复制附件失败
+	attachments := filepath.Join(oldDir, "attachments")
+	if err := os.WriteFile(attachments, []byte("x"), 0644); err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	failedDir := filepath.Join(base, "failed")
+	if err := MoveDataDir(failedDir); err == nil {
+		t.Fatalf("复制附件失败时迁移应报错")
+	}
+	if _, err := os.Stat(failedDir); !os.IsNotExist(err) {
+		t.Errorf("迁移失败后目标目录仍然存在: %v", err)
+	}
+	if _, err := db.Exec("UPDATE settings SET notification_sound = 0 WHERE id = 1"); err != nil {
+		t.Errorf("迁移失败后数据库应可写入: %v", err)
+	}
+	os.Remove(attachments)
+
+	newDir := filepath.Join(base, "moved")
+	if err := MoveDataDir(newDir); err != nil {
+		t.Fatalf("迁移数据目录失败: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(newDir, datadir.DatabaseFileName)); err != nil {
+		t.Errorf("新目录中没有数据库: %v", err)
+	}
+	if _, err := db.Exec("UPDATE settings SET notification_sound = 1 WHERE id = 1"); err == nil {
+		t.Errorf("迁移后原数据库仍可写入，修改会在重启后丢失")
+	}
+	if err := MoveDataDir(filepath.Join(base, "again")); err == nil {
+		t.Errorf("重启前再次迁移应报错")
+	}
+}
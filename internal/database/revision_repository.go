@@ -0,0 +1,169 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// revisionField 记录修改历史的待办字段
+type revisionField struct {
+	name string
+	ptr  func(todo *models.Todo) interface{}
+}
+
+// revisionFields 记录修改历史的字段列表，字段名与 Todo 的 JSON 字段名一致
+var revisionFields = []revisionField{
+	{"title", func(t *models.Todo) interface{} { return &t.Title }},
+	{"content", func(t *models.Todo) interface{} { return &t.Content }},
+	{"type", func(t *models.Todo) interface{} { return &t.Type }},
+	{"startDate", func(t *models.Todo) interface{} { return &t.StartDate }},
+	{"endDate", func(t *models.Todo) interface{} { return &t.EndDate }},
+	{"isLunar", func(t *models.Todo) interface{} { return &t.IsLunar }},
+	{"hideYear", func(t *models.Todo) interface{} { return &t.HideYear }},
+	{"advanceRemind", func(t *models.Todo) interface{} { return &t.AdvanceRemind }},
+	{"remindAtStart", func(t *models.Todo) interface{} { return &t.RemindAtStart }},
+	{"remindAtEnd", func(t *models.Todo) interface{} { return &t.RemindAtEnd }},
+	{"priority", func(t *models.Todo) interface{} { return &t.Priority }},
+	{"timeZone", func(t *models.Todo) interface{} { return &t.TimeZone }},
+	{"allDay", func(t *models.Todo) interface{} { return &t.AllDay }},
+	{"calendarId", func(t *models.Todo) interface{} { return &t.CalendarID }},
+	{"location", func(t *models.Todo) interface{} { return &t.Location }},
+	{"meetingUrl", func(t *models.Todo) interface{} { return &t.MeetingURL }},
+	{"attendees", func(t *models.Todo) interface{} { return &t.Attendees }},
+	{"customFields", func(t *models.Todo) interface{} { return &t.CustomFields }},
+}
+
+// diffTodo 比较修改前后的待办，返回有变化的字段
+func diffTodo(before, after *models.Todo) ([]models.FieldChange, error) {
+	changes := []models.FieldChange{}
+	for _, field := range revisionFields {
+		// 时间按时刻比较，忽略时区表示的差异
+		if oldTime, ok := field.ptr(before).(*models.FlexTime); ok {
+			newTime := field.ptr(after).(*models.FlexTime)
+			if oldTime.Time.Truncate(time.Second).Equal(newTime.Time.Truncate(time.Second)) {
+				continue
+			}
+		}
+		oldValue, err := json.Marshal(field.ptr(before))
+		if err != nil {
+			return nil, err
+		}
+		newValue, err := json.Marshal(field.ptr(after))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(oldValue, newValue) {
+			changes = append(changes, models.FieldChange{Field: field.name, Old: oldValue, New: newValue})
+		}
+	}
+	return changes, nil
+}
+
+// RevertChanges 将修改记录中字段的旧值写回待办
+func RevertChanges(todo *models.Todo, changes []models.FieldChange) error {
+	for _, change := range changes {
+		for _, field := range revisionFields {
+			if field.name != change.Field {
+				continue
+			}
+			if err := json.Unmarshal(change.Old, field.ptr(todo)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RevisionRepository 待办修改历史仓库
+type RevisionRepository struct {
+	db dbExecutor
+}
+
+// NewRevisionRepository 创建修改历史仓库实例
+func NewRevisionRepository(db *sql.DB) *RevisionRepository {
+	return &RevisionRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *RevisionRepository) WithTx(tx *sql.Tx) *RevisionRepository {
+	return &RevisionRepository{db: tx}
+}
+
+// Create 记录一次修改
+func (r *RevisionRepository) Create(todoID int64, origin string, changes []models.FieldChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("INSERT INTO todo_revisions (todo_id, changes, origin, created_at) VALUES (?, ?, ?, ?)",
+		todoID, string(data), origin, time.Now())
+	return err
+}
+
+// GetByID 根据ID获取修改记录
+func (r *RevisionRepository) GetByID(id int64) (*models.TodoRevision, error) {
+	query := `SELECT id, todo_id, changes, COALESCE(origin, ''), created_at FROM todo_revisions WHERE id = ?`
+	revision, err := scanRevision(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// ListByTodoID 获取待办的修改记录，最新的在前
+func (r *RevisionRepository) ListByTodoID(todoID int64) ([]models.TodoRevision, error) {
+	return r.query(`
+		SELECT id, todo_id, changes, COALESCE(origin, ''), created_at
+		FROM todo_revisions WHERE todo_id = ?
+		ORDER BY id DESC
+	`, todoID)
+}
+
+// ListSince 获取待办从指定记录开始(含)的修改记录，最新的在前
+func (r *RevisionRepository) ListSince(todoID, revisionID int64) ([]models.TodoRevision, error) {
+	return r.query(`
+		SELECT id, todo_id, changes, COALESCE(origin, ''), created_at
+		FROM todo_revisions WHERE todo_id = ? AND id >= ?
+		ORDER BY id DESC
+	`, todoID, revisionID)
+}
+
+// DeleteByTodoID 删除待办的所有修改记录
+func (r *RevisionRepository) DeleteByTodoID(todoID int64) error {
+	_, err := r.db.Exec("DELETE FROM todo_revisions WHERE todo_id = ?", todoID)
+	return err
+}
+
+// query 执行查询并扫描修改记录列表
+func (r *RevisionRepository) query(query string, args ...interface{}) ([]models.TodoRevision, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.TodoRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// scanRevision 扫描单条修改记录
+func scanRevision(row rowScanner) (models.TodoRevision, error) {
+	var revision models.TodoRevision
+	var changes string
+	if err := row.Scan(&revision.ID, &revision.TodoID, &changes, &revision.Origin, &revision.CreatedAt); err != nil {
+		return revision, err
+	}
+	err := json.Unmarshal([]byte(changes), &revision.Changes)
+	return revision, err
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"time"
 
 	"todo-calendar/internal/models"
 )
@@ -21,7 +22,12 @@ func (r *SettingsRepository) Get() (*models.Settings, error) {
 	query := `
 		SELECT id, enable_widget, enable_auto_start, minimize_to_tray, 
 			   notification_sound, notification_duration, widget_position, 
-			   widget_opacity, theme, COALESCE(notification_sound_file, '')
+			   widget_opacity, theme, COALESCE(notification_sound_file, ''),
+			   COALESCE(auto_complete_parent, 1), COALESCE(trash_retention_days, 30),
+			   COALESCE(all_day_remind_time, '09:00'), COALESCE(all_day_remind_days_before, 0),
+			   COALESCE(pomodoro_work_minutes, 25), COALESCE(pomodoro_break_minutes, 5),
+			   COALESCE(pomodoro_long_break_minutes, 15), COALESCE(pomodoro_long_break_every, 4),
+			   COALESCE(missed_reminder_grace_minutes, 60)
 		FROM settings WHERE id = 1
 	`
 	settings := &models.Settings{}
@@ -36,6 +42,15 @@ func (r *SettingsRepository) Get() (*models.Settings, error) {
 		&settings.WidgetOpacity,
 		&settings.Theme,
 		&settings.NotificationSoundFile,
+		&settings.AutoCompleteParent,
+		&settings.TrashRetentionDays,
+		&settings.AllDayRemindTime,
+		&settings.AllDayRemindDaysBefore,
+		&settings.PomodoroWorkMinutes,
+		&settings.PomodoroBreakMinutes,
+		&settings.PomodoroLongBreakMinutes,
+		&settings.PomodoroLongBreakEvery,
+		&settings.MissedReminderGraceMinutes,
 	)
 	if err != nil {
 		return nil, err
@@ -55,7 +70,16 @@ func (r *SettingsRepository) Update(settings *models.Settings) error {
 			widget_position = ?,
 			widget_opacity = ?,
 			theme = ?,
-			notification_sound_file = ?
+			notification_sound_file = ?,
+			auto_complete_parent = ?,
+			trash_retention_days = ?,
+			all_day_remind_time = ?,
+			all_day_remind_days_before = ?,
+			pomodoro_work_minutes = ?,
+			pomodoro_break_minutes = ?,
+			pomodoro_long_break_minutes = ?,
+			pomodoro_long_break_every = ?,
+			missed_reminder_grace_minutes = ?
 		WHERE id = 1
 	`
 	_, err := r.db.Exec(query,
@@ -68,6 +92,30 @@ func (r *SettingsRepository) Update(settings *models.Settings) error {
 		settings.WidgetOpacity,
 		settings.Theme,
 		settings.NotificationSoundFile,
+		settings.AutoCompleteParent,
+		settings.TrashRetentionDays,
+		settings.AllDayRemindTime,
+		settings.AllDayRemindDaysBefore,
+		settings.PomodoroWorkMinutes,
+		settings.PomodoroBreakMinutes,
+		settings.PomodoroLongBreakMinutes,
+		settings.PomodoroLongBreakEvery,
+		settings.MissedReminderGraceMinutes,
 	)
 	return err
 }
+
+// LastReminderCheck 获取上次检查提醒的时间，从未检查过时返回零值
+func (r *SettingsRepository) LastReminderCheck() (time.Time, error) {
+	var checkedAt sql.NullTime
+	if err := r.db.QueryRow("SELECT last_reminder_check_at FROM settings WHERE id = 1").Scan(&checkedAt); err != nil {
+		return time.Time{}, err
+	}
+	return checkedAt.Time, nil
+}
+
+// SetLastReminderCheck 记录本次检查提醒的时间
+func (r *SettingsRepository) SetLastReminderCheck(checkedAt time.Time) error {
+	_, err := r.db.Exec("UPDATE settings SET last_reminder_check_at = ? WHERE id = 1", checkedAt)
+	return err
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// snoozeColumns 推迟提醒查询字段列表，与 scanSnooze 的扫描顺序一致
+const snoozeColumns = `s.id, s.todo_id, COALESCE(t.title, ''), s.repeat_index, s.reminder, s.fire_at, s.created_at`
+
+// SnoozeRepository 推迟提醒仓库
+type SnoozeRepository struct {
+	db dbExecutor
+}
+
+// NewSnoozeRepository 创建推迟提醒仓库实例
+func NewSnoozeRepository(db *sql.DB) *SnoozeRepository {
+	return &SnoozeRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *SnoozeRepository) WithTx(tx *sql.Tx) *SnoozeRepository {
+	return &SnoozeRepository{db: tx}
+}
+
+// Save 保存推迟的提醒，同一待办实例的同一种提醒已推迟时改为新的时间
+func (r *SnoozeRepository) Save(snooze *models.ReminderSnooze) (int64, error) {
+	query := `
+		INSERT INTO reminder_snoozes (todo_id, repeat_index, reminder, fire_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (todo_id, repeat_index, reminder) DO UPDATE SET fire_at = excluded.fire_at, created_at = excluded.created_at
+	`
+	_, err := r.db.Exec(query, snooze.TodoID, snooze.RepeatIndex, snooze.Reminder, snooze.FireAt.Time, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = r.db.QueryRow("SELECT id FROM reminder_snoozes WHERE todo_id = ? AND repeat_index = ? AND reminder = ?",
+		snooze.TodoID, snooze.RepeatIndex, snooze.Reminder).Scan(&id)
+	return id, err
+}
+
+// GetByID 根据ID获取推迟的提醒
+func (r *SnoozeRepository) GetByID(id int64) (*models.ReminderSnooze, error) {
+	query := `SELECT ` + snoozeColumns + ` FROM reminder_snoozes s LEFT JOIN todos t ON t.id = s.todo_id WHERE s.id = ?`
+	snooze, err := scanSnooze(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+	return &snooze, nil
+}
+
+// List 获取所有推迟的提醒，按提醒时间排列
+func (r *SnoozeRepository) List() ([]models.ReminderSnooze, error) {
+	return r.query(`SELECT ` + snoozeColumns + ` FROM reminder_snoozes s LEFT JOIN todos t ON t.id = s.todo_id ORDER BY s.fire_at ASC`)
+}
+
+// Due 获取到时的推迟提醒
+func (r *SnoozeRepository) Due(now time.Time) ([]models.ReminderSnooze, error) {
+	return r.query(`SELECT `+snoozeColumns+` FROM reminder_snoozes s LEFT JOIN todos t ON t.id = s.todo_id
+		WHERE s.fire_at <= ? ORDER BY s.fire_at ASC`, now)
+}
+
+// Claim 删除推迟的提醒并返回是否由本次调用删除，用于避免多个进程重复发送
+func (r *SnoozeRepository) Claim(id int64) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM reminder_snoozes WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Delete 取消待办实例某种提醒的推迟
+func (r *SnoozeRepository) Delete(todoID int64, repeatIndex int, reminder string) error {
+	_, err := r.db.Exec("DELETE FROM reminder_snoozes WHERE todo_id = ? AND repeat_index = ? AND reminder = ?", todoID, repeatIndex, reminder)
+	return err
+}
+
+// DeleteByTodoID 删除待办的所有推迟提醒
+func (r *SnoozeRepository) DeleteByTodoID(todoID int64) error {
+	_, err := r.db.Exec("DELETE FROM reminder_snoozes WHERE todo_id = ?", todoID)
+	return err
+}
+
+// query 执行查询并扫描推迟提醒列表
+func (r *SnoozeRepository) query(query string, args ...interface{}) ([]models.ReminderSnooze, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snoozes := []models.ReminderSnooze{}
+	for rows.Next() {
+		snooze, err := scanSnooze(rows)
+		if err != nil {
+			return nil, err
+		}
+		snoozes = append(snoozes, snooze)
+	}
+	return snoozes, rows.Err()
+}
+
+// scanSnooze 扫描单条推迟提醒
+func scanSnooze(row rowScanner) (models.ReminderSnooze, error) {
+	var snooze models.ReminderSnooze
+	err := row.Scan(
+		&snooze.ID,
+		&snooze.TodoID,
+		&snooze.TodoTitle,
+		&snooze.RepeatIndex,
+		&snooze.Reminder,
+		&snooze.FireAt,
+		&snooze.CreatedAt,
+	)
+	return snooze, err
+}
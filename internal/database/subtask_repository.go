@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// SubtaskRepository 子任务仓库
+type SubtaskRepository struct {
+	db dbExecutor
+}
+
+// NewSubtaskRepository 创建子任务仓库实例
+func NewSubtaskRepository(db *sql.DB) *SubtaskRepository {
+	return &SubtaskRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *SubtaskRepository) WithTx(tx *sql.Tx) *SubtaskRepository {
+	return &SubtaskRepository{db: tx}
+}
+
+// Create 创建子任务，排在最后
+func (r *SubtaskRepository) Create(subtask *models.Subtask) (int64, error) {
+	subtask.Title = strings.TrimSpace(subtask.Title)
+	if subtask.Title == "" {
+		return 0, fmt.Errorf("子任务标题不能为空")
+	}
+	if err := r.db.QueryRow("SELECT COALESCE(MAX(sort_order), 0) + 1 FROM subtasks WHERE todo_id = ?",
+		subtask.TodoID).Scan(&subtask.SortOrder); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	result, err := r.db.Exec(`
+		INSERT INTO subtasks (todo_id, title, is_completed, sort_order, created_at, updated_at)
+		VALUES (?, ?, 0, ?, ?, ?)
+	`, subtask.TodoID, subtask.Title, subtask.SortOrder, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// UpdateTitle 修改子任务标题
+func (r *SubtaskRepository) UpdateTitle(id int64, title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return fmt.Errorf("子任务标题不能为空")
+	}
+	_, err := r.db.Exec("UPDATE subtasks SET title = ?, updated_at = ? WHERE id = ?", title, time.Now(), id)
+	return err
+}
+
+// MarkCompleted 标记子任务完成状态
+func (r *SubtaskRepository) MarkCompleted(id int64, completed bool) error {
+	var completedAt interface{}
+	if completed {
+		completedAt = time.Now()
+	}
+	_, err := r.db.Exec("UPDATE subtasks SET is_completed = ?, completed_at = ?, updated_at = ? WHERE id = ?",
+		completed, completedAt, time.Now(), id)
+	return err
+}
+
+// Delete 删除子任务
+func (r *SubtaskRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM subtasks WHERE id = ?", id)
+	return err
+}
+
+// DeleteByTodoID 删除待办的所有子任务
+func (r *SubtaskRepository) DeleteByTodoID(todoID int64) error {
+	_, err := r.db.Exec("DELETE FROM subtasks WHERE todo_id = ?", todoID)
+	return err
+}
+
+// Reorder 按给定的ID顺序更新子任务排序，ID必须属于该待办
+func (r *SubtaskRepository) Reorder(todoID int64, ids []int64) error {
+	for i, id := range ids {
+		result, err := r.db.Exec("UPDATE subtasks SET sort_order = ?, updated_at = ? WHERE id = ? AND todo_id = ?",
+			i+1, time.Now(), id, todoID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("子任务 %d 不属于待办 %d", id, todoID)
+		}
+	}
+	return nil
+}
+
+// GetByID 根据ID获取子任务
+func (r *SubtaskRepository) GetByID(id int64) (*models.Subtask, error) {
+	query := `
+		SELECT id, todo_id, title, is_completed, completed_at, sort_order, created_at, updated_at
+		FROM subtasks WHERE id = ?
+	`
+	subtask, err := scanSubtask(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+	return &subtask, nil
+}
+
+// GetByTodoID 获取待办的子任务列表，按排序号排列
+func (r *SubtaskRepository) GetByTodoID(todoID int64) ([]models.Subtask, error) {
+	query := `
+		SELECT id, todo_id, title, is_completed, completed_at, sort_order, created_at, updated_at
+		FROM subtasks WHERE todo_id = ?
+		ORDER BY sort_order ASC, id ASC
+	`
+	rows, err := r.db.Query(query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subtasks := []models.Subtask{}
+	for rows.Next() {
+		subtask, err := scanSubtask(rows)
+		if err != nil {
+			return nil, err
+		}
+		subtasks = append(subtasks, subtask)
+	}
+	return subtasks, rows.Err()
+}
+
+// Progress 获取待办的子任务完成数和总数
+func (r *SubtaskRepository) Progress(todoID int64) (completed, total int, err error) {
+	err = r.db.QueryRow("SELECT COALESCE(SUM(is_completed), 0), COUNT(*) FROM subtasks WHERE todo_id = ?",
+		todoID).Scan(&completed, &total)
+	return completed, total, err
+}
+
+// scanSubtask 扫描单个子任务
+func scanSubtask(row rowScanner) (models.Subtask, error) {
+	var subtask models.Subtask
+	var completedAt sql.NullTime
+	err := row.Scan(
+		&subtask.ID,
+		&subtask.TodoID,
+		&subtask.Title,
+		&subtask.IsCompleted,
+		&completedAt,
+		&subtask.SortOrder,
+		&subtask.CreatedAt,
+		&subtask.UpdatedAt,
+	)
+	if err != nil {
+		return subtask, err
+	}
+	if completedAt.Valid {
+		subtask.CompletedAt = &models.FlexTime{Time: completedAt.Time}
+	}
+	return subtask, nil
+}
@@ -0,0 +1,241 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// tagBatchSize 批量加载标签时每次查询的待办数量
+const tagBatchSize = 500
+
+// TagRepository 标签仓库
+type TagRepository struct {
+	db dbExecutor
+}
+
+// NewTagRepository 创建标签仓库实例
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *TagRepository) WithTx(tx *sql.Tx) *TagRepository {
+	return &TagRepository{db: tx}
+}
+
+// Create 创建标签
+func (r *TagRepository) Create(tag *models.Tag) (int64, error) {
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" {
+		return 0, fmt.Errorf("标签名称不能为空")
+	}
+	result, err := r.db.Exec("INSERT INTO tags (name, color, created_at) VALUES (?, ?, ?)",
+		tag.Name, tag.Color, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Update 更新标签
+func (r *TagRepository) Update(tag *models.Tag) error {
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" {
+		return fmt.Errorf("标签名称不能为空")
+	}
+	_, err := r.db.Exec("UPDATE tags SET name = ?, color = ? WHERE id = ?", tag.Name, tag.Color, tag.ID)
+	return err
+}
+
+// Delete 删除标签及其与待办的关联
+func (r *TagRepository) Delete(id int64) error {
+	if _, err := r.db.Exec("DELETE FROM todo_tags WHERE tag_id = ?", id); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("DELETE FROM tags WHERE id = ?", id)
+	return err
+}
+
+// List 获取所有标签及其使用次数，按名称排序
+func (r *TagRepository) List() ([]models.Tag, error) {
+	query := `
+		SELECT t.id, t.name, COALESCE(t.color, ''), t.created_at,
+			   (SELECT COUNT(*) FROM todo_tags tt WHERE tt.tag_id = t.id)
+		FROM tags t
+		ORDER BY t.name COLLATE NOCASE ASC
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.TodoCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetByID 根据ID获取标签
+func (r *TagRepository) GetByID(id int64) (*models.Tag, error) {
+	tag := &models.Tag{}
+	err := r.db.QueryRow("SELECT id, name, COALESCE(color, ''), created_at FROM tags WHERE id = ?", id).
+		Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// GetByName 根据名称获取标签(不区分大小写)
+func (r *TagRepository) GetByName(name string) (*models.Tag, error) {
+	tag := &models.Tag{}
+	err := r.db.QueryRow("SELECT id, name, COALESCE(color, ''), created_at FROM tags WHERE name = ? COLLATE NOCASE",
+		strings.TrimSpace(name)).Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// SetTodoTags 设置待办的标签，替换原有关联
+// 标签ID为0时按名称查找，不存在则创建
+func (r *TagRepository) SetTodoTags(todoID int64, tags []models.Tag) error {
+	if _, err := r.db.Exec("DELETE FROM todo_tags WHERE todo_id = ?", todoID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		tagID := tag.ID
+		if tagID <= 0 {
+			existing, err := r.GetByName(tag.Name)
+			switch {
+			case err == nil:
+				tagID = existing.ID
+			case err == sql.ErrNoRows:
+				if tagID, err = r.Create(&tag); err != nil {
+					return err
+				}
+			default:
+				return err
+			}
+		}
+		if _, err := r.db.Exec("INSERT OR IGNORE INTO todo_tags (todo_id, tag_id) VALUES (?, ?)", todoID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTodoTag 为待办添加标签，已有该标签时不做修改
+func (r *TagRepository) AddTodoTag(todoID, tagID int64) error {
+	_, err := r.db.Exec("INSERT OR IGNORE INTO todo_tags (todo_id, tag_id) VALUES (?, ?)", todoID, tagID)
+	return err
+}
+
+// RemoveTodoTag 移除待办的标签
+func (r *TagRepository) RemoveTodoTag(todoID, tagID int64) error {
+	_, err := r.db.Exec("DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?", todoID, tagID)
+	return err
+}
+
+// DeleteByTodoID 删除待办的所有标签关联
+func (r *TagRepository) DeleteByTodoID(todoID int64) error {
+	_, err := r.db.Exec("DELETE FROM todo_tags WHERE todo_id = ?", todoID)
+	return err
+}
+
+// GetByTodoIDs 批量获取待办的标签，按待办ID索引
+func (r *TagRepository) GetByTodoIDs(todoIDs []int64) (map[int64][]models.Tag, error) {
+	result := make(map[int64][]models.Tag)
+	for start := 0; start < len(todoIDs); start += tagBatchSize {
+		end := start + tagBatchSize
+		if end > len(todoIDs) {
+			end = len(todoIDs)
+		}
+		batch := todoIDs[start:end]
+
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		query := `
+			SELECT tt.todo_id, t.id, t.name, COALESCE(t.color, ''), t.created_at
+			FROM todo_tags tt JOIN tags t ON t.id = tt.tag_id
+			WHERE tt.todo_id IN (` + placeholders(len(batch)) + `)
+			ORDER BY t.name COLLATE NOCASE ASC
+		`
+		rows, err := r.db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var todoID int64
+			var tag models.Tag
+			if err := rows.Scan(&todoID, &tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result[todoID] = append(result[todoID], tag)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// loadInto 为待办列表填充标签
+func (r *TagRepository) loadInto(todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(todos))
+	for i := range todos {
+		ids[i] = todos[i].ID
+	}
+	tagMap, err := r.GetByTodoIDs(ids)
+	if err != nil {
+		return err
+	}
+	for i := range todos {
+		todos[i].Tags = tagMap[todos[i].ID]
+		if todos[i].Tags == nil {
+			todos[i].Tags = []models.Tag{}
+		}
+	}
+	return nil
+}
+
+// tagCondition 返回按标签筛选待办的条件，用于 todos 表的查询
+// match 为 all 时需包含全部标签，否则包含任意一个即可
+func tagCondition(tagIDs []int64, match string) (string, []interface{}) {
+	seen := make(map[int64]bool)
+	args := []interface{}{}
+	for _, id := range tagIDs {
+		if !seen[id] {
+			seen[id] = true
+			args = append(args, id)
+		}
+	}
+	cond := "id IN (SELECT todo_id FROM todo_tags WHERE tag_id IN (" + placeholders(len(args)) + ")"
+	if match == models.TagMatchAll {
+		cond += " GROUP BY todo_id HAVING COUNT(DISTINCT tag_id) = ?"
+		args = append(args, len(seen))
+	}
+	return cond + ")", args
+}
+
+// placeholders 生成 n 个以逗号分隔的占位符
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// createTaggedTodo 创建带标签的待办
+func createTaggedTodo(t *testing.T, repo *TodoRepository, title string, tags ...string) int64 {
+	t.Helper()
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	todo := &models.Todo{
+		Title:     title,
+		Type:      models.TodoTypeTask,
+		StartDate: models.FlexTime{Time: start},
+		EndDate:   models.FlexTime{Time: start.Add(time.Hour)},
+		Tags:      []models.Tag{},
+	}
+	for _, name := range tags {
+		todo.Tags = append(todo.Tags, models.Tag{Name: name})
+	}
+	id, err := repo.Create(todo)
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	return id
+}
+
+// 按名称设置标签时复用已有标签(不区分大小写)，名称为空时报错
+func TestSetTodoTagsByName(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewTodoRepository(db)
+	tags := NewTagRepository(db)
+
+	id := createTaggedTodo(t, repo, "周会", "Work", "work", "重要")
+	todo, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("获取待办失败: %v", err)
+	}
+	if len(todo.Tags) != 2 {
+		t.Errorf("待办有 %d 个标签，应为 2 个: %v", len(todo.Tags), todo.Tags)
+	}
+	list, _ := tags.List()
+	if len(list) != 2 {
+		t.Errorf("共有 %d 个标签，应为 2 个", len(list))
+	}
+
+	if _, err := tags.Create(&models.Tag{Name: "  "}); err == nil {
+		t.Errorf("创建空名称的标签应报错")
+	}
+	if err := tags.SetTodoTags(id, []models.Tag{{Name: ""}}); err == nil {
+		t.Errorf("按空名称设置标签应报错")
+	}
+}
+
+// 按标签筛选：any 包含任意一个标签，all 包含全部标签(重复的标签ID只算一次)
+func TestListFilterByTags(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewTodoRepository(db)
+	createTaggedTodo(t, repo, "周会", "工作", "重要")
+	createTaggedTodo(t, repo, "写周报", "工作")
+	createTaggedTodo(t, repo, "买菜")
+	work, _ := NewTagRepository(db).GetByName("工作")
+	important, _ := NewTagRepository(db).GetByName("重要")
+
+	tests := []struct {
+		name   string
+		tagIDs []int64
+		match  string
+		want   int64
+	}{
+		{"任意一个", []int64{work.ID, important.ID}, models.TagMatchAny, 2},
+		{"默认为任意一个", []int64{important.ID}, "", 1},
+		{"全部", []int64{work.ID, important.ID}, models.TagMatchAll, 1},
+		{"全部(重复ID)", []int64{work.ID, work.ID}, models.TagMatchAll, 2},
+		{"不存在的标签", []int64{999}, models.TagMatchAny, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := repo.List(models.TodoFilter{TagIDs: tt.tagIDs, TagMatch: tt.match})
+			if err != nil {
+				t.Fatalf("查询失败: %v", err)
+			}
+			if result.Total != tt.want {
+				t.Errorf("查到 %d 个待办，应为 %d 个", result.Total, tt.want)
+			}
+		})
+	}
+}
+
+// 删除标签时移除待办上的该标签
+func TestDeleteTagRemovesAssignments(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewTodoRepository(db)
+	tags := NewTagRepository(db)
+	id := createTaggedTodo(t, repo, "周会", "工作", "重要")
+	work, _ := tags.GetByName("工作")
+
+	if err := tags.Delete(work.ID); err != nil {
+		t.Fatalf("删除标签失败: %v", err)
+	}
+	todo, _ := repo.GetByID(id)
+	if len(todo.Tags) != 1 || todo.Tags[0].Name != "重要" {
+		t.Errorf("删除标签后待办的标签为 %v，应只剩 重要", todo.Tags)
+	}
+}
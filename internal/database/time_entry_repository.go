@@ -0,0 +1,240 @@
+package database
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// timeEntryColumns 计时记录查询字段列表，与 scanTimeEntry 的扫描顺序一致
+const timeEntryColumns = `e.id, e.todo_id, COALESCE(t.title, ''), e.kind, e.started_at, e.ended_at,
+			   COALESCE(e.planned_minutes, 0), COALESCE(e.note, ''), e.created_at`
+
+// TimeEntryRepository 计时记录仓库
+type TimeEntryRepository struct {
+	db dbExecutor
+}
+
+// NewTimeEntryRepository 创建计时记录仓库实例
+func NewTimeEntryRepository(db *sql.DB) *TimeEntryRepository {
+	return &TimeEntryRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *TimeEntryRepository) WithTx(tx *sql.Tx) *TimeEntryRepository {
+	return &TimeEntryRepository{db: tx}
+}
+
+// GetByID 根据ID获取计时记录
+func (r *TimeEntryRepository) GetByID(id int64) (*models.TimeEntry, error) {
+	query := `SELECT ` + timeEntryColumns + ` FROM time_entries e LEFT JOIN todos t ON t.id = e.todo_id WHERE e.id = ?`
+	entry, err := scanTimeEntry(r.db.QueryRow(query, id), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Running 获取正在计时的记录，没有时返回 nil
+func (r *TimeEntryRepository) Running() (*models.TimeEntry, error) {
+	query := `SELECT ` + timeEntryColumns + ` FROM time_entries e LEFT JOIN todos t ON t.id = e.todo_id
+		WHERE e.ended_at IS NULL ORDER BY e.started_at DESC LIMIT 1`
+	entry, err := scanTimeEntry(r.db.QueryRow(query), time.Now())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Create 创建计时记录，EndedAt 为 nil 时开始计时
+func (r *TimeEntryRepository) Create(entry *models.TimeEntry) (int64, error) {
+	var endedAt interface{}
+	if entry.EndedAt != nil {
+		endedAt = entry.EndedAt.Time
+	}
+	query := `
+		INSERT INTO time_entries (todo_id, kind, started_at, ended_at, planned_minutes, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := r.db.Exec(query,
+		entry.TodoID,
+		entry.Kind,
+		entry.StartedAt.Time,
+		endedAt,
+		entry.PlannedMinutes,
+		entry.Note,
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Finish 结束正在计时的记录，返回记录是否由本次调用结束(已结束的记录不做修改)
+func (r *TimeEntryRepository) Finish(id int64, at time.Time) (bool, error) {
+	result, err := r.db.Exec("UPDATE time_entries SET ended_at = ? WHERE id = ? AND ended_at IS NULL", at, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Delete 删除计时记录
+func (r *TimeEntryRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM time_entries WHERE id = ?", id)
+	return err
+}
+
+// DeleteByTodoID 删除待办的所有计时记录
+func (r *TimeEntryRepository) DeleteByTodoID(todoID int64) error {
+	_, err := r.db.Exec("DELETE FROM time_entries WHERE todo_id = ?", todoID)
+	return err
+}
+
+// ListByTodoID 获取待办的计时记录，最新的在前
+func (r *TimeEntryRepository) ListByTodoID(todoID int64) ([]models.TimeEntry, error) {
+	query := `SELECT ` + timeEntryColumns + ` FROM time_entries e LEFT JOIN todos t ON t.id = e.todo_id
+		WHERE e.todo_id = ? ORDER BY e.started_at DESC, e.id DESC`
+	rows, err := r.db.Query(query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	entries := []models.TimeEntry{}
+	for rows.Next() {
+		entry, err := scanTimeEntry(rows, now)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// CountFinishedSince 统计 since 之后结束的某类记录数量
+func (r *TimeEntryRepository) CountFinishedSince(kind string, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM time_entries WHERE kind = ? AND ended_at >= ?", kind, since).Scan(&count)
+	return count, err
+}
+
+// Totals 统计 [start, end) 内的用时(不含番茄钟休息和回收站中的待办)，跨天的记录按本地日期拆分
+func (r *TimeEntryRepository) Totals(start, end time.Time) (*models.TimeTotals, error) {
+	now := time.Now()
+	query := `
+		SELECT e.todo_id, t.title, t.type, e.started_at, e.ended_at
+		FROM time_entries e
+		JOIN todos t ON t.id = e.todo_id AND t.deleted_at IS NULL
+		WHERE e.kind != ? AND e.started_at < ? AND (e.ended_at IS NULL OR e.ended_at > ?)
+	`
+	rows, err := r.db.Query(query, models.TimeEntryBreak, end, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := &models.TimeTotals{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Add(-time.Second).Format("2006-01-02"),
+		ByTodo:    []models.TodoTime{},
+		ByType:    []models.TypeTime{},
+		ByDay:     []models.DayTime{},
+	}
+	byTodo := map[int64]*models.TodoTime{}
+	byType := map[models.TodoType]int64{}
+	byDay := map[string]int64{}
+	for rows.Next() {
+		var todo models.TodoTime
+		var startedAt time.Time
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&todo.TodoID, &todo.Title, &todo.Type, &startedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		// 正在计时的记录算到当前时间
+		endedAt := now
+		if finishedAt.Valid {
+			endedAt = finishedAt.Time
+		}
+		if startedAt.Before(start) {
+			startedAt = start
+		}
+		if endedAt.After(end) {
+			endedAt = end
+		}
+		for from := startedAt.In(time.Local); from.Before(endedAt); {
+			to := time.Date(from.Year(), from.Month(), from.Day()+1, 0, 0, 0, 0, time.Local)
+			if to.After(endedAt) {
+				to = endedAt
+			}
+			seconds := int64(to.Sub(from) / time.Second)
+			byDay[from.Format("2006-01-02")] += seconds
+			if byTodo[todo.TodoID] == nil {
+				byTodo[todo.TodoID] = &models.TodoTime{TodoID: todo.TodoID, Title: todo.Title, Type: todo.Type}
+			}
+			byTodo[todo.TodoID].Seconds += seconds
+			byType[todo.Type] += seconds
+			totals.TotalSeconds += seconds
+			from = to
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, todo := range byTodo {
+		totals.ByTodo = append(totals.ByTodo, *todo)
+	}
+	sort.Slice(totals.ByTodo, func(i, j int) bool {
+		if totals.ByTodo[i].Seconds != totals.ByTodo[j].Seconds {
+			return totals.ByTodo[i].Seconds > totals.ByTodo[j].Seconds
+		}
+		return totals.ByTodo[i].TodoID < totals.ByTodo[j].TodoID
+	})
+	for todoType, seconds := range byType {
+		totals.ByType = append(totals.ByType, models.TypeTime{Type: todoType, Seconds: seconds})
+	}
+	sort.Slice(totals.ByType, func(i, j int) bool { return totals.ByType[i].Type < totals.ByType[j].Type })
+	for day := start.In(time.Local); day.Before(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		totals.ByDay = append(totals.ByDay, models.DayTime{Date: date, Seconds: byDay[date]})
+	}
+	return totals, nil
+}
+
+// scanTimeEntry 扫描单条计时记录，正在计时的记录用时算到 now
+func scanTimeEntry(row rowScanner, now time.Time) (models.TimeEntry, error) {
+	var entry models.TimeEntry
+	var endedAt sql.NullTime
+	err := row.Scan(
+		&entry.ID,
+		&entry.TodoID,
+		&entry.TodoTitle,
+		&entry.Kind,
+		&entry.StartedAt,
+		&endedAt,
+		&entry.PlannedMinutes,
+		&entry.Note,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return entry, err
+	}
+	end := now
+	if endedAt.Valid {
+		entry.EndedAt = &models.FlexTime{Time: endedAt.Time}
+		end = endedAt.Time
+	}
+	if end.After(entry.StartedAt.Time) {
+		entry.Seconds = int64(end.Sub(entry.StartedAt.Time) / time.Second)
+	}
+	return entry, nil
+}
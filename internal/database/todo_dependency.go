@@ -0,0 +1,70 @@
+package database
+
+import (
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// GetBlockers 获取待办的前置待办(需先完成的待办)
+func (r *TodoRepository) GetBlockers(id int64) ([]models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE ` + notDeleted + ` AND id IN (SELECT depends_on_id FROM todo_dependencies WHERE todo_id = ?)
+		ORDER BY start_date ASC
+	`
+	return r.queryTodos(query, id)
+}
+
+// GetDependents 获取等待该待办完成的后续待办
+func (r *TodoRepository) GetDependents(id int64) ([]models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE ` + notDeleted + ` AND id IN (SELECT todo_id FROM todo_dependencies WHERE depends_on_id = ?)
+		ORDER BY start_date ASC
+	`
+	return r.queryTodos(query, id)
+}
+
+// GetDependencyGraph 获取日期范围内的待办及其依赖关系
+// 与范围内待办直接相连的范围外待办也作为节点返回，保证每条边的两端都存在
+func (r *TodoRepository) GetDependencyGraph(start, end time.Time) (*models.DependencyGraph, error) {
+	single, singleArgs := zonedCondition("start_date <= ? AND end_date >= ?", end, start)
+	series, seriesArgs := zonedCondition("start_date <= ? AND (repeat_end_date IS NULL OR repeat_end_date >= ?)", end, start)
+	inRange := `
+		SELECT id FROM todos
+		WHERE (NOT ` + seriesCondition + ` AND ` + single + `)
+		   OR (` + seriesCondition + ` AND ` + series + `)
+	`
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE ` + notDeleted + `
+		  AND (id IN (` + inRange + `)
+		   OR id IN (SELECT depends_on_id FROM todo_dependencies WHERE todo_id IN (` + inRange + `))
+		   OR id IN (SELECT todo_id FROM todo_dependencies WHERE depends_on_id IN (` + inRange + `)))
+		ORDER BY start_date ASC
+	`
+	// inRange 子查询出现三次
+	args := []interface{}{}
+	for i := 0; i < 3; i++ {
+		args = append(args, singleArgs...)
+		args = append(args, seriesArgs...)
+	}
+	nodes, err := r.queryTodos(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	edges, err := r.deps.GetEdges(ids)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DependencyGraph{Nodes: nodes, Edges: edges}, nil
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// TodoInstanceRepository 循环实例仓库
+// 循环待办只存储一条系列记录，这里仅保存实例的完成状态和单次修改
+type TodoInstanceRepository struct {
+	db dbExecutor
+}
+
+// NewTodoInstanceRepository 创建循环实例仓库实例
+func NewTodoInstanceRepository(db *sql.DB) *TodoInstanceRepository {
+	return &TodoInstanceRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *TodoInstanceRepository) WithTx(tx *sql.Tx) *TodoInstanceRepository {
+	return &TodoInstanceRepository{db: tx}
+}
+
+// GetByTodoID 获取系列的所有实例记录，按实例序号索引
+func (r *TodoInstanceRepository) GetByTodoID(todoID int64) (map[int]models.TodoInstance, error) {
+	query := `
+		SELECT id, todo_id, instance_index, scheduled_at, is_completed, completed_at,
+			   COALESCE(is_cancelled, 0), COALESCE(start_remind_triggered, 0),
+			   title, content, start_date, end_date
+		FROM todo_instances WHERE todo_id = ?
+	`
+	rows, err := r.db.Query(query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := make(map[int]models.TodoInstance)
+	for rows.Next() {
+		var instance models.TodoInstance
+		var completedAt, startDate, endDate sql.NullTime
+		var title, content sql.NullString
+		err := rows.Scan(
+			&instance.ID,
+			&instance.TodoID,
+			&instance.InstanceIndex,
+			&instance.ScheduledAt,
+			&instance.IsCompleted,
+			&completedAt,
+			&instance.IsCancelled,
+			&instance.StartRemindTriggered,
+			&title,
+			&content,
+			&startDate,
+			&endDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if completedAt.Valid {
+			instance.CompletedAt = &models.FlexTime{Time: completedAt.Time}
+		}
+		if title.Valid {
+			instance.Title = &title.String
+		}
+		if content.Valid {
+			instance.Content = &content.String
+		}
+		if startDate.Valid {
+			instance.StartDate = &models.FlexTime{Time: startDate.Time}
+		}
+		if endDate.Valid {
+			instance.EndDate = &models.FlexTime{Time: endDate.Time}
+		}
+		instances[instance.InstanceIndex] = instance
+	}
+	return instances, rows.Err()
+}
+
+// MarkCompleted 标记实例完成状态
+func (r *TodoInstanceRepository) MarkCompleted(todoID int64, index int, scheduledAt time.Time, completed bool) error {
+	var completedAt interface{}
+	if completed {
+		completedAt = time.Now()
+	}
+	query := `
+		INSERT INTO todo_instances (todo_id, instance_index, scheduled_at, is_completed, completed_at, start_remind_triggered, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(todo_id, instance_index) DO UPDATE SET
+			is_completed = excluded.is_completed,
+			completed_at = excluded.completed_at,
+			start_remind_triggered = MAX(start_remind_triggered, excluded.start_remind_triggered),
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Exec(query, todoID, index, scheduledAt, completed, completedAt, completed, time.Now())
+	return err
+}
+
+// MarkStartRemindTriggered 标记实例开始提醒已触发
+func (r *TodoInstanceRepository) MarkStartRemindTriggered(todoID int64, index int, scheduledAt time.Time) error {
+	query := `
+		INSERT INTO todo_instances (todo_id, instance_index, scheduled_at, start_remind_triggered, updated_at)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT(todo_id, instance_index) DO UPDATE SET
+			start_remind_triggered = 1,
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Exec(query, todoID, index, scheduledAt, time.Now())
+	return err
+}
+
+// DeleteByTodoID 删除系列的所有实例记录
+func (r *TodoInstanceRepository) DeleteByTodoID(todoID int64) error {
+	_, err := r.db.Exec("DELETE FROM todo_instances WHERE todo_id = ?", todoID)
+	return err
+}
+
+// SaveOverride 保存实例的单次修改(标题、内容、起止时间)
+func (r *TodoInstanceRepository) SaveOverride(todoID int64, index int, scheduledAt time.Time, occurrence *models.Todo) error {
+	query := `
+		INSERT INTO todo_instances (todo_id, instance_index, scheduled_at, title, content, start_date, end_date, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(todo_id, instance_index) DO UPDATE SET
+			title = excluded.title,
+			content = excluded.content,
+			start_date = excluded.start_date,
+			end_date = excluded.end_date,
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Exec(query, todoID, index, scheduledAt,
+		occurrence.Title,
+		occurrence.Content,
+		occurrence.StartDate,
+		occurrence.EndDate,
+		time.Now(),
+	)
+	return err
+}
+
+// Cancel 取消单个实例(仅删除此实例)
+func (r *TodoInstanceRepository) Cancel(todoID int64, index int, scheduledAt time.Time) error {
+	query := `
+		INSERT INTO todo_instances (todo_id, instance_index, scheduled_at, is_cancelled, updated_at)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT(todo_id, instance_index) DO UPDATE SET
+			is_cancelled = 1,
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Exec(query, todoID, index, scheduledAt, time.Now())
+	return err
+}
+
+// DeleteFrom 删除序号不小于 fromIndex 的实例记录
+func (r *TodoInstanceRepository) DeleteFrom(todoID int64, fromIndex int) error {
+	_, err := r.db.Exec("DELETE FROM todo_instances WHERE todo_id = ? AND instance_index >= ?", todoID, fromIndex)
+	return err
+}
+
+// Reindex 按新的序号和计划时间更新实例记录，instances 以原序号为键，新序号为 0 的实例记录被删除
+func (r *TodoInstanceRepository) Reindex(todoID int64, instances map[int]models.TodoInstance) error {
+	// 先把序号改为负数，避免逐条更新时与尚未更新的记录违反唯一约束
+	if _, err := r.db.Exec("UPDATE todo_instances SET instance_index = -instance_index WHERE todo_id = ? AND instance_index > 0", todoID); err != nil {
+		return err
+	}
+	for index, instance := range instances {
+		if instance.InstanceIndex <= 0 {
+			if _, err := r.db.Exec("DELETE FROM todo_instances WHERE todo_id = ? AND instance_index = ?", todoID, -index); err != nil {
+				return err
+			}
+			continue
+		}
+		query := `
+			UPDATE todo_instances SET instance_index = ?, scheduled_at = ?, updated_at = ?
+			WHERE todo_id = ? AND instance_index = ?
+		`
+		if _, err := r.db.Exec(query, instance.InstanceIndex, instance.ScheduledAt.Time, time.Now(), todoID, -index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MoveFrom 将序号不小于 fromIndex 的实例记录移动到新系列，序号从1重新开始
+func (r *TodoInstanceRepository) MoveFrom(todoID int64, fromIndex int, newTodoID int64) error {
+	query := `
+		UPDATE todo_instances
+		SET todo_id = ?, instance_index = instance_index - ?, updated_at = ?
+		WHERE todo_id = ? AND instance_index >= ?
+	`
+	_, err := r.db.Exec(query, newTodoID, fromIndex-1, time.Now(), todoID, fromIndex)
+	return err
+}
@@ -2,59 +2,183 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"todo-calendar/internal/models"
 )
 
+// todoColumns 待办查询字段列表，与 scanTodo 的扫描顺序一致
+const todoColumns = `id, title, content, type, start_date, end_date, is_lunar, hide_year, 
+			   advance_remind, remind_at_start, remind_at_end,
+			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at,
+			   COALESCE(repeat_type, 'none'), COALESCE(cron_expr, ''), repeat_end_date, COALESCE(repeat_count, 0), COALESCE(duration_minutes, 0),
+			   COALESCE(priority, 0),
+			   (SELECT COUNT(*) FROM subtasks s WHERE s.todo_id = todos.id),
+			   (SELECT COUNT(*) FROM subtasks s WHERE s.todo_id = todos.id AND s.is_completed = 1),
+			   ` + blockedExpr + `, deleted_at, COALESCE(time_zone, '` + models.TimeZoneFloating + `'), COALESCE(all_day, 0), COALESCE(calendar_id, 0),
+			   COALESCE(location, ''), COALESCE(meeting_url, ''), COALESCE(attendees, '[]')`
+
+// blockedExpr 判断待办是否被未完成的前置待办阻塞
+// 循环系列没有整体的完成状态，不能作为前置待办，已有的此类依赖不阻塞
+const blockedExpr = `EXISTS (SELECT 1 FROM todo_dependencies d JOIN todos b ON b.id = d.depends_on_id
+			   WHERE d.todo_id = todos.id AND b.is_completed = 0 AND b.deleted_at IS NULL
+			   AND NOT (COALESCE(b.cron_expr, '') != '' AND COALESCE(b.repeat_type, 'none') != 'none'))`
+
+// notDeleted 排除回收站中的待办
+const notDeleted = "deleted_at IS NULL"
+
+// seriesCondition 循环系列记录的筛选条件
+const seriesCondition = "(COALESCE(cron_expr, '') != '' AND COALESCE(repeat_type, 'none') != 'none')"
+
 // TodoRepository 待办事项仓库
 type TodoRepository struct {
-	db *sql.DB
+	db        dbExecutor
+	instances *TodoInstanceRepository
+	tags      *TagRepository
+	subtasks  *SubtaskRepository
+	deps      *DependencyRepository
+	revisions *RevisionRepository
+	calendars *CalendarRepository
+	fields    *CustomFieldRepository
+	entries   *TimeEntryRepository
+	snoozes   *SnoozeRepository
+	origin    string // 修改来源，记录在修改历史中
 }
 
 // NewTodoRepository 创建待办仓库实例
 func NewTodoRepository(db *sql.DB) *TodoRepository {
-	return &TodoRepository{db: db}
+	return &TodoRepository{
+		db:        db,
+		instances: NewTodoInstanceRepository(db),
+		tags:      NewTagRepository(db),
+		subtasks:  NewSubtaskRepository(db),
+		deps:      NewDependencyRepository(db),
+		revisions: NewRevisionRepository(db),
+		calendars: NewCalendarRepository(db),
+		fields:    NewCustomFieldRepository(db),
+		entries:   NewTimeEntryRepository(db),
+		snoozes:   NewSnoozeRepository(db),
+		origin:    models.OriginMain,
+	}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *TodoRepository) WithTx(tx *sql.Tx) *TodoRepository {
+	return &TodoRepository{
+		db:        tx,
+		instances: r.instances.WithTx(tx),
+		tags:      r.tags.WithTx(tx),
+		subtasks:  r.subtasks.WithTx(tx),
+		deps:      r.deps.WithTx(tx),
+		revisions: r.revisions.WithTx(tx),
+		calendars: r.calendars.WithTx(tx),
+		fields:    r.fields.WithTx(tx),
+		entries:   r.entries.WithTx(tx),
+		snoozes:   r.snoozes.WithTx(tx),
+		origin:    r.origin,
+	}
+}
+
+// SetOrigin 设置修改来源窗口(main/widget/notification)
+func (r *TodoRepository) SetOrigin(origin string) {
+	r.origin = origin
+}
+
+// recordRevision 比较修改前后的待办，有变化时记录修改历史
+func (r *TodoRepository) recordRevision(before, after *models.Todo) error {
+	changes, err := diffTodo(before, after)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	return r.revisions.Create(before.ID, r.origin, changes)
 }
 
 // Create 创建待办事项
 func (r *TodoRepository) Create(todo *models.Todo) (int64, error) {
 	query := `
 		INSERT INTO todos (title, content, type, start_date, end_date, is_lunar, hide_year, 
-			advance_remind, remind_at_start, remind_at_end, start_remind_triggered, repeat_index, repeat_total, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			advance_remind, remind_at_start, remind_at_end, priority, start_remind_triggered, repeat_index, repeat_total,
+			repeat_type, cron_expr, repeat_end_date, repeat_count, duration_minutes, time_zone, all_day, calendar_id,
+			location, meeting_url, attendees, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	now := time.Now()
 	// 设置默认值
 	if todo.AdvanceRemind <= 0 {
 		todo.AdvanceRemind = 15
 	}
+	if todo.RepeatType == "" {
+		todo.RepeatType = models.RepeatTypeNone
+	}
+	if todo.TimeZone == "" {
+		todo.TimeZone = models.TimeZoneFloating
+	}
+	if todo.CalendarID <= 0 {
+		calendar, err := r.calendars.Default()
+		if err != nil {
+			return 0, err
+		}
+		todo.CalendarID = calendar.ID
+	}
 	result, err := r.db.Exec(query,
 		todo.Title,
 		todo.Content,
 		todo.Type,
-		todo.StartDate,
-		todo.EndDate,
+		storedFlexTime(todo, &todo.StartDate),
+		storedFlexTime(todo, &todo.EndDate),
 		todo.IsLunar,
 		todo.HideYear,
 		todo.AdvanceRemind,
 		todo.RemindAtStart,
 		todo.RemindAtEnd,
+		todo.Priority,
 		todo.StartRemindTriggered,
 		todo.RepeatIndex,
 		todo.RepeatTotal,
+		todo.RepeatType,
+		todo.CronExpr,
+		storedFlexTime(todo, todo.RepeatEndDate),
+		todo.RepeatCount,
+		todo.DurationMinutes,
+		todo.TimeZone,
+		todo.AllDay,
+		todo.CalendarID,
+		todo.Location,
+		todo.MeetingURL,
+		encodeAttendees(todo.Attendees),
 		now,
 		now,
 	)
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if todo.Tags != nil {
+		if err := r.tags.SetTodoTags(id, todo.Tags); err != nil {
+			return 0, err
+		}
+	}
+	if todo.CustomFields != nil {
+		if err := r.fields.SetTodoValues(id, todo.Type, todo.CustomFields); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
 }
 
-// Update 更新待办事项
+// Update 更新待办事项，并记录修改历史
 func (r *TodoRepository) Update(todo *models.Todo) error {
+	before, err := r.GetByID(todo.ID)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE todos SET
 			title = ?,
@@ -67,118 +191,130 @@ func (r *TodoRepository) Update(todo *models.Todo) error {
 			advance_remind = ?,
 			remind_at_start = ?,
 			remind_at_end = ?,
+			priority = ?,
+			time_zone = ?,
+			all_day = ?,
+			calendar_id = ?,
+			location = ?,
+			meeting_url = ?,
+			attendees = ?,
 			updated_at = ?
 		WHERE id = ?
 	`
-	_, err := r.db.Exec(query,
+	if todo.TimeZone == "" {
+		todo.TimeZone = before.TimeZone
+	}
+	if todo.CalendarID <= 0 {
+		todo.CalendarID = before.CalendarID
+	}
+	if todo.Attendees == nil {
+		todo.Attendees = before.Attendees
+	}
+	if todo.CustomFields == nil {
+		// 类型改变后原类型的字段值不再适用
+		todo.CustomFields = map[string]string{}
+		if todo.Type == before.Type {
+			todo.CustomFields = before.CustomFields
+		}
+	}
+	_, err = r.db.Exec(query,
 		todo.Title,
 		todo.Content,
 		todo.Type,
-		todo.StartDate,
-		todo.EndDate,
+		storedFlexTime(todo, &todo.StartDate),
+		storedFlexTime(todo, &todo.EndDate),
 		todo.IsLunar,
 		todo.HideYear,
 		todo.AdvanceRemind,
 		todo.RemindAtStart,
 		todo.RemindAtEnd,
+		todo.Priority,
+		todo.TimeZone,
+		todo.AllDay,
+		todo.CalendarID,
+		todo.Location,
+		todo.MeetingURL,
+		encodeAttendees(todo.Attendees),
 		time.Now(),
 		todo.ID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if err := r.recordRevision(before, todo); err != nil {
+		return err
+	}
+	if todo.CustomFields != nil {
+		if err := r.fields.SetTodoValues(todo.ID, todo.Type, todo.CustomFields); err != nil {
+			return err
+		}
+	}
+	if todo.Tags != nil {
+		return r.tags.SetTodoTags(todo.ID, todo.Tags)
+	}
+	return nil
 }
 
 // Delete 删除待办事项
 func (r *TodoRepository) Delete(id int64) error {
+	if err := r.instances.DeleteByTodoID(id); err != nil {
+		return err
+	}
+	if err := r.tags.DeleteByTodoID(id); err != nil {
+		return err
+	}
+	if err := r.fields.DeleteByTodoID(id); err != nil {
+		return err
+	}
+	if err := r.subtasks.DeleteByTodoID(id); err != nil {
+		return err
+	}
+	if err := r.deps.DeleteByTodoID(id); err != nil {
+		return err
+	}
+	if err := r.revisions.DeleteByTodoID(id); err != nil {
+		return err
+	}
+	if err := r.entries.DeleteByTodoID(id); err != nil {
+		return err
+	}
+	if err := r.snoozes.DeleteByTodoID(id); err != nil {
+		return err
+	}
 	_, err := r.db.Exec("DELETE FROM todos WHERE id = ?", id)
 	return err
 }
 
 // GetByID 根据ID获取待办事项
 func (r *TodoRepository) GetByID(id int64) (*models.Todo, error) {
-	query := `
-		SELECT id, title, content, type, start_date, end_date, is_lunar, hide_year, 
-			   advance_remind, remind_at_start, remind_at_end,
-			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at
-		FROM todos WHERE id = ?
-	`
-	todo := &models.Todo{}
-	var completedAt sql.NullTime
-	err := r.db.QueryRow(query, id).Scan(
-		&todo.ID,
-		&todo.Title,
-		&todo.Content,
-		&todo.Type,
-		&todo.StartDate,
-		&todo.EndDate,
-		&todo.IsLunar,
-		&todo.HideYear,
-		&todo.AdvanceRemind,
-		&todo.RemindAtStart,
-		&todo.RemindAtEnd,
-		&todo.StartRemindTriggered,
-		&todo.RepeatIndex,
-		&todo.RepeatTotal,
-		&todo.IsCompleted,
-		&completedAt,
-		&todo.CreatedAt,
-		&todo.UpdatedAt,
-	)
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE id = ? AND ` + notDeleted
+	todo, err := scanTodo(r.db.QueryRow(query, id))
 	if err != nil {
 		return nil, err
 	}
-	if completedAt.Valid {
-		ft := &models.FlexTime{Time: completedAt.Time}
-		todo.CompletedAt = ft
+	todos := []models.Todo{todo}
+	if err := r.loadRelated(todos); err != nil {
+		return nil, err
 	}
-	return todo, nil
+	return &todos[0], nil
 }
 
 // List 获取待办列表
 func (r *TodoRepository) List(filter models.TodoFilter) (*models.TodoListResult, error) {
-	// 构建查询条件
-	where := "WHERE 1=1"
-	args := []interface{}{}
-
-	if filter.Keyword != "" {
-		where += " AND title LIKE ?"
-		args = append(args, "%"+filter.Keyword+"%")
-	}
-
-	if filter.Year > 0 {
-		// 使用 substr 匹配年份，兼容各种日期格式
-		where += " AND substr(start_date, 1, 4) = ?"
-		args = append(args, fmt.Sprintf("%d", filter.Year))
-	}
-
-	if filter.Month > 0 {
-		// 使用 substr 匹配月份，兼容各种日期格式
-		where += " AND substr(start_date, 6, 2) = ?"
-		args = append(args, fmt.Sprintf("%02d", filter.Month))
-	}
-
-	if len(filter.Types) > 0 {
-		placeholders := ""
-		for i, t := range filter.Types {
-			if i > 0 {
-				placeholders += ","
-			}
-			placeholders += "?"
-			args = append(args, t)
-		}
-		where += " AND type IN (" + placeholders + ")"
+	where, args, err := listCondition(filter)
+	if err != nil {
+		return nil, err
 	}
 
-	// 默认只查询未完成的待办，除非明确指定 completed=true
-	if filter.Completed != nil && *filter.Completed {
-		where += " AND is_completed = 1"
-	} else {
-		where += " AND is_completed = 0"
+	orderBy, err := orderByClause(filter.Sort)
+	if err != nil {
+		return nil, err
 	}
 
 	// 获取总数
 	var total int64
 	countQuery := "SELECT COUNT(*) FROM todos " + where
-	err := r.db.QueryRow(countQuery, args...).Scan(&total)
+	err = r.db.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, err
 	}
@@ -194,54 +330,17 @@ func (r *TodoRepository) List(filter models.TodoFilter) (*models.TodoListResult,
 
 	// 查询数据
 	query := `
-		SELECT id, title, content, type, start_date, end_date, is_lunar, hide_year, 
-			   advance_remind, remind_at_start, remind_at_end,
-			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos ` + where + `
-		ORDER BY start_date ASC
+		ORDER BY ` + orderBy + `
 		LIMIT ? OFFSET ?
 	`
 	args = append(args, filter.PageSize, offset)
 
-	rows, err := r.db.Query(query, args...)
+	todos, err := r.queryTodos(query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	todos := []models.Todo{}
-	for rows.Next() {
-		var todo models.Todo
-		var completedAt sql.NullTime
-		err := rows.Scan(
-			&todo.ID,
-			&todo.Title,
-			&todo.Content,
-			&todo.Type,
-			&todo.StartDate,
-			&todo.EndDate,
-			&todo.IsLunar,
-			&todo.HideYear,
-			&todo.AdvanceRemind,
-			&todo.RemindAtStart,
-			&todo.RemindAtEnd,
-			&todo.StartRemindTriggered,
-			&todo.RepeatIndex,
-			&todo.RepeatTotal,
-			&todo.IsCompleted,
-			&completedAt,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		if completedAt.Valid {
-			ft := &models.FlexTime{Time: completedAt.Time}
-			todo.CompletedAt = ft
-		}
-		todos = append(todos, todo)
-	}
 
 	totalPages := int(total) / filter.PageSize
 	if int(total)%filter.PageSize > 0 {
@@ -257,54 +356,147 @@ func (r *TodoRepository) List(filter models.TodoFilter) (*models.TodoListResult,
 	}, nil
 }
 
-// GetByDateRange 获取日期范围内的待办
+// ListIDs 获取符合筛选条件的所有待办ID(不分页)，按筛选条件的排序方式排列
+func (r *TodoRepository) ListIDs(filter models.TodoFilter) ([]int64, error) {
+	where, args, err := listCondition(filter)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, err := orderByClause(filter.Sort)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query("SELECT id FROM todos "+where+" ORDER BY "+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// listCondition 返回待办列表筛选条件对应的 WHERE 子句
+func listCondition(filter models.TodoFilter) (string, []interface{}, error) {
+	// 构建查询条件
+	where := "WHERE " + notDeleted
+	args := []interface{}{}
+
+	// 关键词匹配标题、内容和附件名
+	if q := newFTSQuery(filter.Keyword); q != nil {
+		cond, condArgs := q.idCondition()
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
+	if filter.Year > 0 {
+		// 按本地时间的年份(指定月份时为该月)计算开始时间的范围
+		rangeStart := time.Date(filter.Year, 1, 1, 0, 0, 0, 0, time.Local)
+		rangeEnd := rangeStart.AddDate(1, 0, 0)
+		if filter.Month > 0 {
+			rangeStart = time.Date(filter.Year, time.Month(filter.Month), 1, 0, 0, 0, 0, time.Local)
+			rangeEnd = rangeStart.AddDate(0, 1, 0)
+		}
+		cond, condArgs := zonedCondition("start_date >= ? AND start_date < ?", rangeStart, rangeEnd)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	} else if filter.Month > 0 {
+		// 不指定年份时匹配任意年份的该月，按本地墙上时间计算月份
+		where += " AND CAST(strftime('%m', CASE WHEN time_zone = '" + models.TimeZoneFloating + "' THEN start_date ELSE datetime(start_date, 'localtime') END) AS INTEGER) = ?"
+		args = append(args, filter.Month)
+	}
+
+	if len(filter.Types) > 0 {
+		placeholders := ""
+		for i, t := range filter.Types {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += "?"
+			args = append(args, t)
+		}
+		where += " AND type IN (" + placeholders + ")"
+	}
+
+	if len(filter.CalendarIDs) > 0 {
+		placeholders := ""
+		for i, id := range filter.CalendarIDs {
+			if i > 0 {
+				placeholders += ","
+			}
+			placeholders += "?"
+			args = append(args, id)
+		}
+		where += " AND calendar_id IN (" + placeholders + ")"
+	}
+
+	if len(filter.CustomFields) > 0 {
+		if err := filter.ValidateCustomFields(); err != nil {
+			return "", nil, err
+		}
+		cond, condArgs := customFieldCondition(filter.CustomFields)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
+	if len(filter.TagIDs) > 0 {
+		cond, condArgs := tagCondition(filter.TagIDs, filter.TagMatch)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
+	// 默认只查询未完成的待办，除非明确指定 completed=true
+	if filter.Completed != nil && *filter.Completed {
+		where += " AND is_completed = 1"
+	} else {
+		where += " AND is_completed = 0"
+	}
+	return where, args, nil
+}
+
+// GetByDateRange 获取日期范围内的待办(循环系列展开为实例)
 func (r *TodoRepository) GetByDateRange(start, end time.Time) ([]models.Todo, error) {
+	cond, args := zonedCondition("start_date <= ? AND end_date >= ?", end, start)
 	query := `
-		SELECT id, title, content, type, start_date, end_date, is_lunar, hide_year, 
-			   advance_remind, remind_at_start, remind_at_end,
-			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos 
-		WHERE (start_date BETWEEN ? AND ?) OR (end_date BETWEEN ? AND ?)
-			  OR (start_date <= ? AND end_date >= ?)
+		WHERE ` + notDeleted + ` AND NOT ` + seriesCondition + ` AND ` + cond + `
 		ORDER BY start_date ASC
 	`
-	rows, err := r.db.Query(query, start, end, start, end, start, end)
+	todos, err := r.queryTodos(query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	return r.scanTodos(rows)
+	occurrences, err := r.GetOccurrencesInRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return mergeByStartDate(todos, occurrences), nil
 }
 
 // GetPendingTodos 获取所有待处理的待办(未完成的)
+// 循环系列以系列记录返回，不展开实例
 func (r *TodoRepository) GetPendingTodos() ([]models.Todo, error) {
 	query := `
-		SELECT id, title, content, type, start_date, end_date, is_lunar, hide_year, 
-			   advance_remind, remind_at_start, remind_at_end,
-			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos 
-		WHERE is_completed = 0
+		WHERE is_completed = 0 AND ` + notDeleted + `
 		ORDER BY start_date ASC
 	`
-	rows, err := r.db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	return r.scanTodos(rows)
+	return r.queryTodos(query)
 }
 
 // GetWeekTodos 获取本周待办
 func (r *TodoRepository) GetWeekTodos() (*models.WeekTodos, error) {
-	now := time.Now()
-	weekday := int(now.Weekday())
-	if weekday == 0 {
-		weekday = 7
-	}
-	weekStart := time.Date(now.Year(), now.Month(), now.Day()-weekday+1, 0, 0, 0, 0, now.Location())
-	weekEnd := weekStart.AddDate(0, 0, 6).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	weekStart, weekEnd := currentWeekRange()
 
 	todos, err := r.GetByDateRange(weekStart, weekEnd)
 	if err != nil {
@@ -312,84 +504,84 @@ func (r *TodoRepository) GetWeekTodos() (*models.WeekTodos, error) {
 	}
 
 	// 获取逾期未完成
+	cond, args := zonedCondition("end_date < ?", weekStart)
 	overdueQuery := `
-		SELECT id, title, content, type, start_date, end_date, is_lunar, hide_year, 
-			   advance_remind, remind_at_start, remind_at_end,
-			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos 
-		WHERE is_completed = 0 AND end_date < ?
+		WHERE is_completed = 0 AND ` + notDeleted + ` AND NOT ` + seriesCondition + ` AND ` + cond + `
 		ORDER BY start_date ASC
 	`
-	rows, err := r.db.Query(overdueQuery, weekStart)
+	overdue, err := r.queryTodos(overdueQuery, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	overdue, err := r.scanTodos(rows)
+	occurrences, err := r.GetOverdueOccurrences(weekStart)
 	if err != nil {
 		return nil, err
 	}
+	overdueOccurrences := []models.Todo{}
+	for _, occurrence := range occurrences {
+		if occurrence.EndDate.Time.Before(weekStart) {
+			overdueOccurrences = append(overdueOccurrences, occurrence)
+		}
+	}
 
 	return &models.WeekTodos{
 		WeekStart: weekStart.Format("2006-01-02"),
 		WeekEnd:   weekEnd.Format("2006-01-02"),
 		Todos:     todos,
-		Overdue:   overdue,
+		Overdue:   mergeByStartDate(overdue, overdueOccurrences),
 	}, nil
 }
 
 // GetWeekTodosNew 获取本周待办(新版，返回逾期和本周分开)
 func (r *TodoRepository) GetWeekTodosNew() ([]models.Todo, []models.Todo, error) {
-	now := time.Now()
-	weekday := int(now.Weekday())
-	if weekday == 0 {
-		weekday = 7
-	}
-	weekStart := time.Date(now.Year(), now.Month(), now.Day()-weekday+1, 0, 0, 0, 0, now.Location())
-	weekEnd := weekStart.AddDate(0, 0, 6).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	weekStart, weekEnd := currentWeekRange()
 
 	// 本周待办（未完成）
+	cond, args := zonedCondition("start_date >= ? AND start_date <= ?", weekStart, weekEnd)
 	todosQuery := `
-		SELECT id, title, content, type, start_date, end_date, is_lunar, hide_year, 
-			   advance_remind, remind_at_start, remind_at_end,
-			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos 
-		WHERE is_completed = 0 AND start_date >= ? AND start_date <= ?
+		WHERE is_completed = 0 AND ` + notDeleted + ` AND NOT ` + seriesCondition + ` AND ` + cond + `
 		ORDER BY start_date ASC
 	`
-	todosRows, err := r.db.Query(todosQuery, weekStart, weekEnd)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer todosRows.Close()
-
-	todos, err := r.scanTodos(todosRows)
+	todos, err := r.queryTodos(todosQuery, args...)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// 逾期未完成
+	cond, args = zonedCondition("start_date < ?", weekStart)
 	overdueQuery := `
-		SELECT id, title, content, type, start_date, end_date, is_lunar, hide_year, 
-			   advance_remind, remind_at_start, remind_at_end,
-			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos 
-		WHERE is_completed = 0 AND start_date < ?
+		WHERE is_completed = 0 AND ` + notDeleted + ` AND NOT ` + seriesCondition + ` AND ` + cond + `
 		ORDER BY start_date ASC
 	`
-	overdueRows, err := r.db.Query(overdueQuery, weekStart)
+	overdue, err := r.queryTodos(overdueQuery, args...)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer overdueRows.Close()
 
-	overdue, err := r.scanTodos(overdueRows)
+	// 本周开始的循环实例从本周展开，之前开始的按逾期查询
+	occurrences, err := r.GetOccurrencesInRange(weekStart, weekEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	weekOccurrences := []models.Todo{}
+	for _, occurrence := range pendingOnly(occurrences) {
+		if !occurrence.StartDate.Time.Before(weekStart) {
+			weekOccurrences = append(weekOccurrences, occurrence)
+		}
+	}
+	overdueOccurrences, err := r.GetOverdueOccurrences(weekStart)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return overdue, todos, nil
+	return mergeByStartDate(overdue, overdueOccurrences), mergeByStartDate(todos, weekOccurrences), nil
 }
 
 // MarkCompleted 标记完成
@@ -412,66 +604,206 @@ func (r *TodoRepository) MarkStartRemindTriggered(id int64) error {
 	return err
 }
 
-// GetTodayStartRemindTodos 获取今天需要开始提醒且未触发的待办
+// GetTodayStartRemindTodos 获取今天需要开始提醒且未触发的待办(不含被阻塞的待办)
 func (r *TodoRepository) GetTodayStartRemindTodos() ([]models.Todo, error) {
 	now := time.Now()
 	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	todayEnd := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
 
+	cond, args := zonedCondition("start_date >= ? AND start_date <= ?", todayStart, todayEnd)
 	query := `
-		SELECT id, title, content, type, start_date, end_date, is_lunar, hide_year, 
-			   advance_remind, remind_at_start, remind_at_end,
-			   start_remind_triggered, repeat_index, repeat_total, is_completed, completed_at, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos 
 		WHERE is_completed = 0 
+		  AND ` + notDeleted + `
+		  AND NOT ` + seriesCondition + `
 		  AND remind_at_start = 1 
 		  AND start_remind_triggered = 0
-		  AND start_date >= ? AND start_date <= ?
+		  AND NOT ` + blockedExpr + `
+		  AND ` + cond + `
 		ORDER BY start_date ASC
 	`
-	rows, err := r.db.Query(query, todayStart, todayEnd)
+	todos, err := r.queryTodos(query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	return r.scanTodos(rows)
+	occurrences, err := r.GetOccurrencesInRange(todayStart, todayEnd)
+	if err != nil {
+		return nil, err
+	}
+	remindOccurrences := []models.Todo{}
+	for _, occurrence := range pendingOnly(occurrences) {
+		if occurrence.RemindAtStart && !occurrence.StartRemindTriggered && !occurrence.IsBlocked &&
+			!occurrence.StartDate.Time.Before(todayStart) && !occurrence.StartDate.Time.After(todayEnd) {
+			remindOccurrences = append(remindOccurrences, occurrence)
+		}
+	}
+
+	return mergeByStartDate(todos, remindOccurrences), nil
+}
+
+// sortColumns 排序字段对应的列
+var sortColumns = map[string]string{
+	models.SortByPriority: "COALESCE(priority, 0)",
+	models.SortByStart:    "start_date",
+	models.SortByEnd:      "end_date",
+	models.SortByCreated:  "created_at",
+	models.SortByUpdated:  "updated_at",
+	models.SortByTitle:    "title COLLATE NOCASE",
+}
+
+// orderByClause 根据排序条件生成 ORDER BY 子句，相同时按开始时间和ID排列
+func orderByClause(keys []models.SortKey) (string, error) {
+	terms := []string{}
+	for _, key := range keys {
+		column, ok := sortColumns[key.Key]
+		if !ok {
+			return "", fmt.Errorf("invalid sort key: %s", key.Key)
+		}
+		direction := "ASC"
+		if key.Desc {
+			direction = "DESC"
+		}
+		terms = append(terms, column+" "+direction)
+	}
+	terms = append(terms, "start_date ASC", "id ASC")
+	return strings.Join(terms, ", "), nil
+}
+
+// currentWeekRange 获取本周的起止时间(周一至周日)
+func currentWeekRange() (time.Time, time.Time) {
+	now := time.Now()
+	weekday := int(now.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	weekStart := time.Date(now.Year(), now.Month(), now.Day()-weekday+1, 0, 0, 0, 0, now.Location())
+	weekEnd := weekStart.AddDate(0, 0, 6).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	return weekStart, weekEnd
+}
+
+// queryTodos 执行查询并扫描待办列表
+func (r *TodoRepository) queryTodos(query string, args ...interface{}) ([]models.Todo, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := r.scanTodos(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// 标签和自定义字段需在结果集关闭后加载(数据库只有一个连接)
+	if err := r.loadRelated(todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// loadRelated 为待办列表填充标签和自定义字段值
+func (r *TodoRepository) loadRelated(todos []models.Todo) error {
+	if err := r.tags.loadInto(todos); err != nil {
+		return err
+	}
+	return r.fields.loadInto(todos)
 }
 
 // scanTodos 扫描待办列表
 func (r *TodoRepository) scanTodos(rows *sql.Rows) ([]models.Todo, error) {
 	todos := []models.Todo{}
 	for rows.Next() {
-		var todo models.Todo
-		var completedAt sql.NullTime
-		err := rows.Scan(
-			&todo.ID,
-			&todo.Title,
-			&todo.Content,
-			&todo.Type,
-			&todo.StartDate,
-			&todo.EndDate,
-			&todo.IsLunar,
-			&todo.HideYear,
-			&todo.AdvanceRemind,
-			&todo.RemindAtStart,
-			&todo.RemindAtEnd,
-			&todo.StartRemindTriggered,
-			&todo.RepeatIndex,
-			&todo.RepeatTotal,
-			&todo.IsCompleted,
-			&completedAt,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		)
+		todo, err := scanTodo(rows)
 		if err != nil {
 			return nil, err
 		}
-		if completedAt.Valid {
-			ft := &models.FlexTime{Time: completedAt.Time}
-			todo.CompletedAt = ft
-		}
 		todos = append(todos, todo)
 	}
-	return todos, nil
+	return todos, rows.Err()
+}
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTodo 按 todoColumns 的顺序扫描一条待办
+func scanTodo(row rowScanner) (models.Todo, error) {
+	var todo models.Todo
+	var completedAt, repeatEndDate, deletedAt sql.NullTime
+	var attendees string
+	err := row.Scan(
+		&todo.ID,
+		&todo.Title,
+		&todo.Content,
+		&todo.Type,
+		&todo.StartDate,
+		&todo.EndDate,
+		&todo.IsLunar,
+		&todo.HideYear,
+		&todo.AdvanceRemind,
+		&todo.RemindAtStart,
+		&todo.RemindAtEnd,
+		&todo.StartRemindTriggered,
+		&todo.RepeatIndex,
+		&todo.RepeatTotal,
+		&todo.IsCompleted,
+		&completedAt,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+		&todo.RepeatType,
+		&todo.CronExpr,
+		&repeatEndDate,
+		&todo.RepeatCount,
+		&todo.DurationMinutes,
+		&todo.Priority,
+		&todo.SubtaskTotal,
+		&todo.SubtaskCompleted,
+		&todo.IsBlocked,
+		&deletedAt,
+		&todo.TimeZone,
+		&todo.AllDay,
+		&todo.CalendarID,
+		&todo.Location,
+		&todo.MeetingURL,
+		&attendees,
+	)
+	if err != nil {
+		return todo, err
+	}
+	if deletedAt.Valid {
+		todo.DeletedAt = &models.FlexTime{Time: deletedAt.Time}
+	}
+	if completedAt.Valid {
+		ft := &models.FlexTime{Time: completedAt.Time}
+		todo.CompletedAt = ft
+	}
+	if repeatEndDate.Valid {
+		todo.RepeatEndDate = &models.FlexTime{Time: repeatEndDate.Time}
+	}
+	todo.Attendees = decodeAttendees(attendees)
+	localizeTodo(&todo)
+	return todo, nil
+}
+
+// encodeAttendees 将参会人编码为 JSON 存储
+func encodeAttendees(attendees []models.Attendee) string {
+	if len(attendees) == 0 {
+		return "[]"
+	}
+	data, err := json.Marshal(attendees)
+	if err != nil {
+		return "[]"
+	}
+	return string(data)
+}
+
+// decodeAttendees 解析存储的参会人，无法解析时返回空列表
+func decodeAttendees(data string) []models.Attendee {
+	attendees := []models.Attendee{}
+	if err := json.Unmarshal([]byte(data), &attendees); err != nil {
+		return []models.Attendee{}
+	}
+	return attendees
 }
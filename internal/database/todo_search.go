@@ -0,0 +1,216 @@
+package database
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"todo-calendar/internal/models"
+)
+
+const (
+	// defaultSearchLimit 搜索结果默认数量
+	defaultSearchLimit = 50
+	// maxSearchLimit 搜索结果最大数量
+	maxSearchLimit = 200
+	// minTrigramLength trigram 分词可匹配的最短关键词长度，更短的关键词回退为 LIKE 匹配
+	minTrigramLength = 3
+	// snippetLength 片段的最大字符数
+	snippetLength = 64
+
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+
+	// 生成片段时先用 Unicode 私用区字符标记匹配位置，转义 HTML 后再替换为 mark 标签
+	// 待办内容来自用户输入，不转义直接以 HTML 显示会导致脚本注入
+	placeholderOpen  = "\uE000"
+	placeholderClose = "\uE001"
+)
+
+// ftsQuery 全文搜索条件，作用于 todo_fts 表
+type ftsQuery struct {
+	terms []string
+	match string // MATCH 表达式，所有关键词都少于3个字符时为空
+	where string
+	args  []interface{}
+}
+
+// newFTSQuery 解析搜索关键词，多个关键词以空格分隔，需同时匹配
+// 不少于3个字符的关键词使用 MATCH(可排序和生成片段)，较短的关键词使用 LIKE
+func newFTSQuery(keyword string) *ftsQuery {
+	terms := strings.Fields(keyword)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	q := &ftsQuery{terms: terms}
+	phrases := []string{}
+	conditions := []string{}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= minTrigramLength {
+			phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+			continue
+		}
+		pattern := "%" + escapeLike(term) + "%"
+		conditions = append(conditions, `(title LIKE ? ESCAPE '\' OR content LIKE ? ESCAPE '\' OR attachments LIKE ? ESCAPE '\' OR details LIKE ? ESCAPE '\')`)
+		q.args = append(q.args, pattern, pattern, pattern, pattern)
+	}
+
+	if len(phrases) > 0 {
+		q.match = strings.Join(phrases, " ")
+		conditions = append([]string{"todo_fts MATCH ?"}, conditions...)
+		q.args = append([]interface{}{q.match}, q.args...)
+	}
+	q.where = strings.Join(conditions, " AND ")
+	return q
+}
+
+// idCondition 返回筛选待办ID的条件，用于 todos 表的查询
+func (q *ftsQuery) idCondition() (string, []interface{}) {
+	return "id IN (SELECT rowid FROM todo_fts WHERE " + q.where + ")", q.args
+}
+
+// Search 全文搜索待办的标题、Markdown 内容、附件名和会议信息，按相关度排序
+func (r *TodoRepository) Search(keyword string, limit int) ([]models.SearchResult, error) {
+	results := []models.SearchResult{}
+	q := newFTSQuery(keyword)
+	if q == nil {
+		return results, nil
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	// 标题权重最高，其次是附件名和会议信息
+	matched := `
+		SELECT rowid AS fts_id, bm25(todo_fts, 10.0, 1.0, 5.0, 3.0) AS fts_rank,
+			   highlight(todo_fts, 0, '` + placeholderOpen + `', '` + placeholderClose + `') AS title_snippet,
+			   snippet(todo_fts, 1, '` + placeholderOpen + `', '` + placeholderClose + `', '…', 16) AS content_snippet,
+			   highlight(todo_fts, 2, '` + placeholderOpen + `', '` + placeholderClose + `') AS attachment_snippet,
+			   highlight(todo_fts, 3, '` + placeholderOpen + `', '` + placeholderClose + `') AS details_snippet
+		FROM todo_fts WHERE ` + q.where
+	if q.match == "" {
+		// 辅助函数只能用于 MATCH 查询，片段在下面生成
+		matched = `
+		SELECT rowid AS fts_id, 0.0 AS fts_rank,
+			   title AS title_snippet, content AS content_snippet, attachments AS attachment_snippet,
+			   details AS details_snippet
+		FROM todo_fts WHERE ` + q.where
+	}
+
+	query := `
+		SELECT ` + todoColumns + `, m.fts_rank, m.title_snippet, m.content_snippet, m.attachment_snippet, m.details_snippet
+		FROM todos JOIN (` + matched + `) m ON todos.id = m.fts_id
+		WHERE ` + notDeleted + `
+		ORDER BY m.fts_rank ASC, start_date DESC
+		LIMIT ?
+	`
+	args := append(q.args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	todos := []models.Todo{}
+	for rows.Next() {
+		var result models.SearchResult
+		var rank float64
+		todo, err := scanTodo(extraScanner{rows, []interface{}{
+			&rank, &result.TitleSnippet, &result.ContentSnippet, &result.AttachmentSnippet, &result.DetailsSnippet,
+		}})
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		todos = append(todos, todo)
+		if q.match != "" {
+			// bm25 越小越相关，转换为越大越相关
+			result.Score = -rank
+		} else {
+			result.TitleSnippet = highlightTerms(result.TitleSnippet, q.terms, 0)
+			result.ContentSnippet = highlightTerms(result.ContentSnippet, q.terms, snippetLength)
+			result.AttachmentSnippet = highlightTerms(result.AttachmentSnippet, q.terms, 0)
+			result.DetailsSnippet = highlightTerms(result.DetailsSnippet, q.terms, 0)
+		}
+		result.TitleSnippet = snippetHTML(result.TitleSnippet)
+		result.ContentSnippet = snippetHTML(result.ContentSnippet)
+		result.AttachmentSnippet = snippetHTML(result.AttachmentSnippet)
+		result.DetailsSnippet = snippetHTML(result.DetailsSnippet)
+		results = append(results, result)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRelated(todos); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Todo = todos[i]
+	}
+	return results, nil
+}
+
+// extraScanner 在 scanTodo 的字段之后继续扫描额外字段
+type extraScanner struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+// Scan 扫描待办字段和额外字段
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+// snippetHTML 将标记了匹配位置的片段转义为 HTML，匹配位置替换为 mark 标签
+func snippetHTML(text string) string {
+	return strings.NewReplacer(placeholderOpen, markOpen, placeholderClose, markClose).Replace(html.EscapeString(text))
+}
+
+// highlightTerms 用占位符标记文本中的关键词(不区分大小写)，文本中原有的占位符字符会被移除
+// maxLength 大于0时截取第一个匹配附近的片段
+func highlightTerms(text string, terms []string, maxLength int) string {
+	text = strings.NewReplacer(placeholderOpen, "", placeholderClose, "").Replace(text)
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	re := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	if maxLength > 0 && utf8.RuneCountInString(text) > maxLength {
+		start := 0
+		if loc := re.FindStringIndex(text); loc != nil {
+			start = loc[0]
+		}
+		// 匹配位置前保留少量上下文
+		runes := []rune(text)
+		offset := utf8.RuneCountInString(text[:start])
+		from := offset - maxLength/4
+		if from < 0 {
+			from = 0
+		}
+		to := from + maxLength
+		if to > len(runes) {
+			to = len(runes)
+		}
+		snippet := string(runes[from:to])
+		if from > 0 {
+			snippet = "…" + snippet
+		}
+		if to < len(runes) {
+			snippet += "…"
+		}
+		text = snippet
+	}
+	return re.ReplaceAllString(text, placeholderOpen+"$0"+placeholderClose)
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
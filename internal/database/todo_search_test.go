@@ -0,0 +1,94 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 片段转义待办内容中的 HTML，只保留高亮用的 mark 标签
+func TestSearchEscapesSnippets(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewTodoRepository(db)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	_, err := repo.Create(&models.Todo{
+		Title:       `<img src=x onerror=alert(1)> 周会`,
+		Content:     `<script>alert("x")</script> 讨论 release 计划`,
+		Type:        models.TodoTypeWork,
+		StartDate:   models.FlexTime{Time: start},
+		EndDate:     models.FlexTime{Time: start.Add(time.Hour)},
+		RepeatIndex: 1,
+		RepeatTotal: 1,
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+
+	// 3个字符以上的关键词走全文索引，较短的关键词走 LIKE 匹配
+	for _, keyword := range []string{"release", "周会"} {
+		results, err := repo.Search(keyword, 0)
+		if err != nil {
+			t.Fatalf("搜索 %q 失败: %v", keyword, err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("搜索 %q 得到 %d 个结果，应为 1 个", keyword, len(results))
+		}
+		result := results[0]
+		for _, snippet := range []string{result.TitleSnippet, result.ContentSnippet} {
+			if strings.Contains(snippet, "<script") || strings.Contains(snippet, "<img") {
+				t.Errorf("搜索 %q 的片段未转义: %s", keyword, snippet)
+			}
+		}
+		if !strings.Contains(result.TitleSnippet, "&lt;img") {
+			t.Errorf("搜索 %q 的标题片段应保留转义后的内容: %s", keyword, result.TitleSnippet)
+		}
+		highlighted := "<mark>" + keyword + "</mark>"
+		if !strings.Contains(result.TitleSnippet+result.ContentSnippet, highlighted) {
+			t.Errorf("搜索 %q 的片段应包含 %s: %s | %s", keyword, highlighted, result.TitleSnippet, result.ContentSnippet)
+		}
+	}
+}
+
+// 搜索匹配地点、会议链接和参会人，片段来自会议信息
+func TestSearchMeetingDetails(t *testing.T) {
+	repo := NewTodoRepository(openTestDB(t))
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	id, err := repo.Create(&models.Todo{
+		Title:      "站会",
+		Type:       models.TodoTypeWork,
+		Location:   "会议室A305",
+		MeetingURL: "https://meet.example.com/abc",
+		Attendees:  []models.Attendee{{Name: "张三", Email: "zhangsan@example.com"}},
+		StartDate:  models.FlexTime{Time: start},
+		EndDate:    models.FlexTime{Time: start.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+
+	for _, keyword := range []string{"A305", "meet.example", "张三", "zhangsan"} {
+		results, err := repo.Search(keyword, 0)
+		if err != nil {
+			t.Fatalf("搜索 %q 失败: %v", keyword, err)
+		}
+		if len(results) != 1 || results[0].Todo.ID != id {
+			t.Errorf("搜索 %q 得到 %d 个结果，应为该会议", keyword, len(results))
+			continue
+		}
+		if !strings.Contains(results[0].DetailsSnippet, "<mark>") {
+			t.Errorf("搜索 %q 的会议信息片段没有高亮: %s", keyword, results[0].DetailsSnippet)
+		}
+	}
+
+	// 修改地点后按原地点搜索不到
+	todo, _ := repo.GetByID(id)
+	todo.Location = "会议室B"
+	if err := repo.Update(todo); err != nil {
+		t.Fatalf("修改待办失败: %v", err)
+	}
+	if results, _ := repo.Search("A305", 0); len(results) != 0 {
+		t.Errorf("修改地点后按原地点搜到 %d 个结果", len(results))
+	}
+}
@@ -0,0 +1,467 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"todo-calendar/internal/models"
+	"todo-calendar/internal/utils"
+)
+
+// GetSeries 获取与日期范围可能有交集的循环系列记录
+func (r *TodoRepository) GetSeries(start, end time.Time) ([]models.Todo, error) {
+	cond, args := zonedCondition("start_date <= ? AND (repeat_end_date IS NULL OR repeat_end_date >= ?)", end, start)
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE ` + notDeleted + ` AND ` + seriesCondition + ` AND ` + cond + `
+		ORDER BY start_date ASC
+	`
+	return r.queryTodos(query, args...)
+}
+
+// GetOccurrencesInRange 获取日期范围内所有循环系列的实例
+func (r *TodoRepository) GetOccurrencesInRange(start, end time.Time) ([]models.Todo, error) {
+	seriesList, err := r.GetSeries(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []models.Todo{}
+	for i := range seriesList {
+		expanded, err := r.expandSeries(&seriesList[i], start, end)
+		if err != nil {
+			return nil, err
+		}
+		occurrences = append(occurrences, expanded...)
+	}
+	return mergeByStartDate(occurrences, nil), nil
+}
+
+// overdueLookback 逾期的循环实例最多追溯到多久之前
+const overdueLookback = 30 * 24 * time.Hour
+
+// maxOverdueOccurrences 每个循环系列最多列出的逾期实例数
+const maxOverdueOccurrences = 5
+
+// GetOverdueOccurrences 获取在 before 之前开始且未完成的循环实例
+// 只追溯 overdueLookback 之内的实例，每个系列只保留最近的 maxOverdueOccurrences 个，避免长期运行的高频系列展开过多
+func (r *TodoRepository) GetOverdueOccurrences(before time.Time) ([]models.Todo, error) {
+	occurrences, err := r.GetOccurrencesInRange(before.Add(-overdueLookback), before.Add(-time.Second))
+	if err != nil {
+		return nil, err
+	}
+	kept := make(map[int64]int)
+	overdue := []models.Todo{}
+	for i := len(occurrences) - 1; i >= 0; i-- {
+		occurrence := occurrences[i]
+		if occurrence.IsCompleted || !occurrence.StartDate.Time.Before(before) || kept[occurrence.ID] >= maxOverdueOccurrences {
+			continue
+		}
+		kept[occurrence.ID]++
+		overdue = append(overdue, occurrence)
+	}
+	return mergeByStartDate(overdue, nil), nil
+}
+
+// GetOccurrence 获取循环系列的第 index 个实例，非循环待办返回记录本身
+func (r *TodoRepository) GetOccurrence(id int64, index int) (*models.Todo, error) {
+	occurrence, _, err := r.getOccurrence(id, index)
+	return occurrence, err
+}
+
+// MarkOccurrenceCompleted 标记循环实例完成状态，非循环待办直接标记记录
+func (r *TodoRepository) MarkOccurrenceCompleted(id int64, index int, completed bool) error {
+	occurrence, scheduled, err := r.getOccurrence(id, index)
+	if err != nil {
+		return err
+	}
+	if !occurrence.IsOccurrence {
+		return r.MarkCompleted(id, completed)
+	}
+	return r.instances.MarkCompleted(id, index, storedTime(occurrence, scheduled), completed)
+}
+
+// MarkOccurrenceStartRemindTriggered 标记循环实例开始提醒已触发
+func (r *TodoRepository) MarkOccurrenceStartRemindTriggered(id int64, index int) error {
+	occurrence, scheduled, err := r.getOccurrence(id, index)
+	if err != nil {
+		return err
+	}
+	if !occurrence.IsOccurrence {
+		return r.MarkStartRemindTriggered(id)
+	}
+	return r.instances.MarkStartRemindTriggered(id, index, storedTime(occurrence, scheduled))
+}
+
+// getOccurrence 获取实例及其按规则计算的开始时间(忽略单次修改)
+// 循环规则按系列所在时区的墙上时间计算
+func (r *TodoRepository) getOccurrence(id int64, index int) (*models.Todo, time.Time, error) {
+	series, err := r.GetByID(id)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !series.IsSeries() {
+		return series, series.StartDate.Time, nil
+	}
+
+	scheduled, ok := seriesTimeAt(series, index)
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("occurrence %d of todo %d does not exist", index, id)
+	}
+
+	instances, err := r.instances.GetByTodoID(id)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	occurrence := newOccurrence(series, index, seriesTotal(series), scheduled, instances)
+	return &occurrence, scheduled, nil
+}
+
+// expandSeries 按循环规则展开系列在[rangeStart, rangeEnd]内的实例
+// 只展开范围附近的实例，之前的实例只计数以确定序号
+func (r *TodoRepository) expandSeries(series *models.Todo, rangeStart, rangeEnd time.Time) ([]models.Todo, error) {
+	// 开始早于范围但持续到范围内的实例也要展开，全天实例会扩展到整天
+	from := rangeStart.Add(-series.OccurrenceDuration())
+	if series.AllDay {
+		from = from.AddDate(0, 0, -1)
+	}
+	times, first := seriesTimesBetween(series, from, rangeEnd)
+
+	instances, err := r.instances.GetByTodoID(series.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 && len(instances) == 0 {
+		return nil, nil
+	}
+
+	total := seriesTotal(series)
+	occurrences := []models.Todo{}
+	add := func(index int, scheduled time.Time) {
+		if instance, ok := instances[index]; ok && instance.IsCancelled {
+			return
+		}
+		occurrence := newOccurrence(series, index, total, scheduled, instances)
+		if occurrence.StartDate.Time.After(rangeEnd) || occurrence.EndDate.Time.Before(rangeStart) {
+			return
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	for i, t := range times {
+		add(first+i, t)
+	}
+
+	// 单独修改过时间的实例可能从范围外移入
+	for index, instance := range instances {
+		if instance.StartDate == nil || (index >= first && index < first+len(times)) {
+			continue
+		}
+		if scheduled, ok := seriesTimeAt(series, index); ok {
+			add(index, scheduled)
+		}
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].StartDate.Time.Before(occurrences[j].StartDate.Time)
+	})
+	return occurrences, nil
+}
+
+// seriesEndDate 系列的终止时间，没有时返回零值
+func seriesEndDate(series *models.Todo) time.Time {
+	if series.RepeatEndDate == nil {
+		return time.Time{}
+	}
+	return series.RepeatEndDate.Time
+}
+
+// seriesTotal 系列的总次数，没有终止时间和次数限制时为0
+func seriesTotal(series *models.Todo) int {
+	end := seriesEndDate(series)
+	if end.IsZero() && series.RepeatCount <= 0 {
+		return 0
+	}
+	return utils.CountCronOccurrences(series.CronExpr, series.StartDate.Time, end, series.RepeatCount)
+}
+
+// seriesTimesBetween 按系列规则计算开始时间在[from, until]内的实例，返回开始时间和第一个的序号
+func seriesTimesBetween(series *models.Todo, from, until time.Time) ([]time.Time, int) {
+	if end := seriesEndDate(series); !end.IsZero() && end.Before(until) {
+		until = end
+	}
+	return utils.GetCronOccurrencesBetween(series.CronExpr, series.StartDate.Time, from, until, series.RepeatCount)
+}
+
+// seriesTimeAt 按系列规则计算第 index 个实例的开始时间，实例不存在时返回 false
+func seriesTimeAt(series *models.Todo, index int) (time.Time, bool) {
+	if index <= 0 || (series.RepeatCount > 0 && index > series.RepeatCount) {
+		return time.Time{}, false
+	}
+	t := utils.GetCronOccurrenceAt(series.CronExpr, series.StartDate.Time, index)
+	if end := seriesEndDate(series); t.IsZero() || (!end.IsZero() && t.After(end)) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// remapInstances 系列规则、开始时间或时区改变后，按原计划时间重新计算实例记录的序号
+// before 为实例记录原来所属的系列，新规则下没有该计划时间的实例记录(完成状态、单次修改)被删除
+func (r *TodoRepository) remapInstances(before, after *models.Todo) error {
+	instances, err := r.instances.GetByTodoID(after.ID)
+	if err != nil || len(instances) == 0 {
+		return err
+	}
+	for index, instance := range instances {
+		scheduled := loadedTime(before, instance.ScheduledAt.Time)
+		instance.InstanceIndex = 0
+		if times, first := seriesTimesBetween(after, scheduled, scheduled); len(times) > 0 && times[0].Equal(scheduled) {
+			instance.InstanceIndex = first
+			instance.ScheduledAt = models.FlexTime{Time: storedTime(after, scheduled)}
+		}
+		instances[index] = instance
+	}
+	return r.instances.Reindex(after.ID, instances)
+}
+
+// newOccurrence 根据系列记录和实例记录生成一个实例
+func newOccurrence(series *models.Todo, index, total int, start time.Time, instances map[int]models.TodoInstance) models.Todo {
+	occurrence := *series
+	occurrence.StartDate = models.FlexTime{Time: start}
+	occurrence.EndDate = models.FlexTime{Time: start.Add(series.OccurrenceDuration())}
+	if series.AllDay {
+		dayStart, dayEnd := models.AllDayRange(start, series.OccurrenceDuration())
+		occurrence.StartDate = models.FlexTime{Time: dayStart}
+		occurrence.EndDate = models.FlexTime{Time: dayEnd}
+	}
+	occurrence.RepeatIndex = index
+	occurrence.RepeatTotal = total
+	occurrence.IsOccurrence = true
+	occurrence.StartRemindTriggered = false
+
+	instance, ok := instances[index]
+	if !ok {
+		return occurrence
+	}
+	if instance.Title != nil {
+		occurrence.Title = *instance.Title
+	}
+	if instance.Content != nil {
+		occurrence.Content = *instance.Content
+	}
+	if instance.StartDate != nil {
+		occurrence.StartDate = models.FlexTime{Time: loadedTime(series, instance.StartDate.Time)}
+	}
+	if instance.EndDate != nil {
+		occurrence.EndDate = models.FlexTime{Time: loadedTime(series, instance.EndDate.Time)}
+	}
+	if instance.IsCompleted {
+		occurrence.IsCompleted = true
+		occurrence.CompletedAt = instance.CompletedAt
+	}
+	occurrence.StartRemindTriggered = instance.StartRemindTriggered
+	return occurrence
+}
+
+// pendingOnly 过滤出未完成的待办
+func pendingOnly(todos []models.Todo) []models.Todo {
+	pending := []models.Todo{}
+	for _, todo := range todos {
+		if !todo.IsCompleted {
+			pending = append(pending, todo)
+		}
+	}
+	return pending
+}
+
+// mergeByStartDate 合并两个待办列表并按开始时间排序
+func mergeByStartDate(a, b []models.Todo) []models.Todo {
+	merged := make([]models.Todo, 0, len(a)+len(b))
+	merged = append(merged, a...)
+	merged = append(merged, b...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].StartDate.Time.Before(merged[j].StartDate.Time)
+	})
+	return merged
+}
+
+// GetScheduledAt 获取实例按规则计算的开始时间(忽略单次修改)
+func (r *TodoRepository) GetScheduledAt(id int64, index int) (time.Time, error) {
+	_, scheduled, err := r.getOccurrence(id, index)
+	return scheduled, err
+}
+
+// UpdateSeries 更新系列记录，包括循环规则，并记录修改历史
+func (r *TodoRepository) UpdateSeries(series *models.Todo) error {
+	before, err := r.GetByID(series.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE todos SET
+			title = ?,
+			content = ?,
+			type = ?,
+			start_date = ?,
+			end_date = ?,
+			is_lunar = ?,
+			hide_year = ?,
+			advance_remind = ?,
+			remind_at_start = ?,
+			remind_at_end = ?,
+			priority = ?,
+			repeat_index = ?,
+			repeat_total = ?,
+			repeat_type = ?,
+			cron_expr = ?,
+			repeat_end_date = ?,
+			repeat_count = ?,
+			duration_minutes = ?,
+			time_zone = ?,
+			all_day = ?,
+			calendar_id = ?,
+			location = ?,
+			meeting_url = ?,
+			attendees = ?,
+			updated_at = ?
+		WHERE id = ?
+	`
+	if series.TimeZone == "" {
+		series.TimeZone = before.TimeZone
+	}
+	if series.CalendarID <= 0 {
+		series.CalendarID = before.CalendarID
+	}
+	if series.Attendees == nil {
+		series.Attendees = before.Attendees
+	}
+	if series.CustomFields == nil {
+		// 类型改变后原类型的字段值不再适用
+		series.CustomFields = map[string]string{}
+		if series.Type == before.Type {
+			series.CustomFields = before.CustomFields
+		}
+	}
+	_, err = r.db.Exec(query,
+		series.Title,
+		series.Content,
+		series.Type,
+		storedFlexTime(series, &series.StartDate),
+		storedFlexTime(series, &series.EndDate),
+		series.IsLunar,
+		series.HideYear,
+		series.AdvanceRemind,
+		series.RemindAtStart,
+		series.RemindAtEnd,
+		series.Priority,
+		series.RepeatIndex,
+		series.RepeatTotal,
+		series.RepeatType,
+		series.CronExpr,
+		storedFlexTime(series, series.RepeatEndDate),
+		series.RepeatCount,
+		series.DurationMinutes,
+		series.TimeZone,
+		series.AllDay,
+		series.CalendarID,
+		series.Location,
+		series.MeetingURL,
+		encodeAttendees(series.Attendees),
+		time.Now(),
+		series.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := r.recordRevision(before, series); err != nil {
+		return err
+	}
+	if series.CronExpr != before.CronExpr || !series.StartDate.Time.Equal(before.StartDate.Time) || series.TimeZone != before.TimeZone {
+		if err := r.remapInstances(before, series); err != nil {
+			return err
+		}
+	}
+	if series.CustomFields != nil {
+		if err := r.fields.SetTodoValues(series.ID, series.Type, series.CustomFields); err != nil {
+			return err
+		}
+	}
+	if series.Tags != nil {
+		return r.tags.SetTodoTags(series.ID, series.Tags)
+	}
+	return nil
+}
+
+// UpdateOccurrence 仅修改单个实例(标题、内容、起止时间)，occurrence.RepeatIndex 为实例序号
+func (r *TodoRepository) UpdateOccurrence(occurrence *models.Todo) error {
+	current, scheduled, err := r.getOccurrence(occurrence.ID, occurrence.RepeatIndex)
+	if err != nil {
+		return err
+	}
+	stored := *occurrence
+	stored.StartDate = models.FlexTime{Time: storedTime(current, occurrence.StartDate.Time)}
+	stored.EndDate = models.FlexTime{Time: storedTime(current, occurrence.EndDate.Time)}
+	return r.instances.SaveOverride(occurrence.ID, occurrence.RepeatIndex, storedTime(current, scheduled), &stored)
+}
+
+// CancelOccurrence 仅删除单个实例
+func (r *TodoRepository) CancelOccurrence(id int64, index int) error {
+	occurrence, scheduled, err := r.getOccurrence(id, index)
+	if err != nil {
+		return err
+	}
+	return r.instances.Cancel(id, index, storedTime(occurrence, scheduled))
+}
+
+// TruncateSeries 截断系列，只保留序号小于 index 的实例
+func (r *TodoRepository) TruncateSeries(id int64, index int) error {
+	if index <= 1 {
+		return fmt.Errorf("cannot truncate series %d before its first occurrence", id)
+	}
+	series, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if _, ok := seriesTimeAt(series, index); !ok {
+		return fmt.Errorf("occurrence %d of todo %d does not exist", index, id)
+	}
+	last, _ := seriesTimeAt(series, index-1)
+
+	// 以保留的最后一个实例作为终止时间，并限制次数
+	query := `
+		UPDATE todos SET repeat_count = ?, repeat_total = ?, repeat_end_date = ?, updated_at = ?
+		WHERE id = ?
+	`
+	if _, err := r.db.Exec(query, index-1, index-1, storedTime(series, last), time.Now(), id); err != nil {
+		return err
+	}
+	return r.instances.DeleteFrom(id, index)
+}
+
+// SplitSeries 从第 index 个实例拆分系列：原系列截断，之后的实例归入新系列 next
+// 原系列的实例记录随之移动并重新编号，返回新系列ID
+func (r *TodoRepository) SplitSeries(id int64, index int, next *models.Todo) (int64, error) {
+	series, err := r.GetByID(id)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := r.Create(next)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.instances.MoveFrom(id, index, newID); err != nil {
+		return 0, err
+	}
+	created, err := r.GetByID(newID)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.remapInstances(series, created); err != nil {
+		return 0, err
+	}
+	if err := r.TruncateSeries(id, index); err != nil {
+		return 0, err
+	}
+	return newID, nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// 数据库中的时间统一为 UTC：
+// 绑定时区的待办存储时刻，读出后转换到待办的时区；
+// 浮动时间的待办把墙上时间原样存为 UTC，读出后按本机当前时区解释，换时区后墙上时间不变
+
+// wallClockUTC 把墙上时间原样转为 UTC 时间
+func wallClockUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// storedTime 返回写入数据库的待办时间
+func storedTime(todo *models.Todo, t time.Time) time.Time {
+	if todo.IsFloating() {
+		return wallClockUTC(t)
+	}
+	return t
+}
+
+// loadedTime 将数据库读出的待办时间转换到待办的时区
+func loadedTime(todo *models.Todo, t time.Time) time.Time {
+	if todo.IsFloating() {
+		u := t.UTC()
+		return time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), u.Nanosecond(), time.Local)
+	}
+	return t.In(todo.Zone())
+}
+
+// storedFlexTime 返回写入数据库的待办时间，零值写入 NULL
+func storedFlexTime(todo *models.Todo, t *models.FlexTime) interface{} {
+	if t == nil || t.Time.IsZero() {
+		return nil
+	}
+	return storedTime(todo, t.Time)
+}
+
+// localizeTodo 将待办的起止时间和循环终止时间转换到待办的时区
+func localizeTodo(todo *models.Todo) {
+	if !todo.StartDate.Time.IsZero() {
+		todo.StartDate.Time = loadedTime(todo, todo.StartDate.Time)
+	}
+	if !todo.EndDate.Time.IsZero() {
+		todo.EndDate.Time = loadedTime(todo, todo.EndDate.Time)
+	}
+	if todo.RepeatEndDate != nil && !todo.RepeatEndDate.Time.IsZero() {
+		todo.RepeatEndDate = &models.FlexTime{Time: loadedTime(todo, todo.RepeatEndDate.Time)}
+	}
+}
+
+// zonedCondition 生成待办时间的比较条件，cond 中的占位符依次对应 times
+// 浮动时间的待办按本地墙上时间比较，其他待办按时刻比较
+func zonedCondition(cond string, times ...time.Time) (string, []interface{}) {
+	args := make([]interface{}, 0, 2*len(times))
+	for _, t := range times {
+		args = append(args, wallClockUTC(t.In(time.Local)))
+	}
+	for _, t := range times {
+		args = append(args, t)
+	}
+	return "((time_zone = '" + models.TimeZoneFloating + "' AND " + cond + ") OR (time_zone != '" + models.TimeZoneFloating + "' AND " + cond + "))", args
+}
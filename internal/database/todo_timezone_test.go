@@ -0,0 +1,78 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// setLocal 在测试期间把本机时区改为 name
+func setLocal(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("缺少时区数据: %v", err)
+	}
+	old := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = old })
+	return loc
+}
+
+// 本机换时区后：绑定时区的待办时刻不变，浮动时间的待办墙上时间不变
+func TestTodoTimeZoneAfterLocalChange(t *testing.T) {
+	setLocal(t, "Asia/Shanghai")
+	repo := NewTodoRepository(openTestDB(t))
+
+	ny, _ := time.LoadLocation("America/New_York")
+	zonedStart := time.Date(2025, 3, 15, 9, 0, 0, 0, ny)
+	zonedID, err := repo.Create(&models.Todo{
+		Title:     "纽约会议",
+		Type:      models.TodoTypeWork,
+		TimeZone:  "America/New_York",
+		StartDate: models.FlexTime{Time: zonedStart},
+		EndDate:   models.FlexTime{Time: zonedStart.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	floatingStart := time.Date(2025, 3, 20, 8, 0, 0, 0, time.Local)
+	floatingID, err := repo.Create(&models.Todo{
+		Title:     "晨跑",
+		Type:      models.TodoTypeTask,
+		TimeZone:  models.TimeZoneFloating,
+		StartDate: models.FlexTime{Time: floatingStart},
+		EndDate:   models.FlexTime{Time: floatingStart.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+
+	// 原时区下读出的时间与写入一致
+	if todo, _ := repo.GetByID(zonedID); !todo.StartDate.Time.Equal(zonedStart) || todo.StartDate.Time.Location().String() != ny.String() {
+		t.Errorf("绑定时区的待办开始时间为 %v，应为 %v", todo.StartDate.Time, zonedStart)
+	}
+	if todo, _ := repo.GetByID(floatingID); !todo.StartDate.Time.Equal(floatingStart) {
+		t.Errorf("浮动时间的待办开始时间为 %v，应为 %v", todo.StartDate.Time, floatingStart)
+	}
+
+	berlin := setLocal(t, "Europe/Berlin")
+
+	if todo, _ := repo.GetByID(zonedID); !todo.StartDate.Time.Equal(zonedStart) || todo.StartDate.Time.Location().String() != ny.String() {
+		t.Errorf("换时区后绑定时区的待办开始时间为 %v，应保持 %v", todo.StartDate.Time, zonedStart)
+	}
+	want := time.Date(2025, 3, 20, 8, 0, 0, 0, berlin)
+	if todo, _ := repo.GetByID(floatingID); !todo.StartDate.Time.Equal(want) {
+		t.Errorf("换时区后浮动时间的待办开始时间为 %v，应为 %v", todo.StartDate.Time, want)
+	}
+
+	// 按日期查询时浮动时间按新时区的墙上时间匹配
+	todos, err := repo.GetByDateRange(time.Date(2025, 3, 20, 0, 0, 0, 0, berlin), time.Date(2025, 3, 20, 23, 59, 59, 0, berlin))
+	if err != nil {
+		t.Fatalf("按日期查询失败: %v", err)
+	}
+	if len(todos) != 1 || todos[0].ID != floatingID {
+		t.Errorf("3月20日查到 %d 个待办，应只有浮动时间的待办", len(todos))
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// MoveToTrash 将待办移入回收站
+func (r *TodoRepository) MoveToTrash(id int64) error {
+	now := time.Now()
+	result, err := r.db.Exec("UPDATE todos SET deleted_at = ?, updated_at = ? WHERE id = ? AND "+notDeleted, now, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Restore 从回收站恢复待办
+func (r *TodoRepository) Restore(id int64) error {
+	result, err := r.db.Exec("UPDATE todos SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL", time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetTrashed 获取回收站中的待办
+func (r *TodoRepository) GetTrashed(id int64) (*models.Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE id = ? AND deleted_at IS NOT NULL`
+	todo, err := scanTodo(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// ListTrash 获取回收站中的待办，最近删除的在前
+func (r *TodoRepository) ListTrash() ([]models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+	return r.queryTodos(query)
+}
+
+// GetTrashedBefore 获取在指定时间之前移入回收站的待办ID
+func (r *TodoRepository) GetTrashedBefore(cutoff time.Time) ([]int64, error) {
+	rows, err := r.db.Query("SELECT id FROM todos WHERE deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo-calendar/internal/models"
+)
+
+// todoTypeColumns 待办类型查询字段列表，与 scanTodoType 的扫描顺序一致
+const todoTypeColumns = `t.id, t.value, t.label, COALESCE(t.icon, ''), COALESCE(t.color, ''),
+			   COALESCE(t.advance_remind, 15), COALESCE(t.remind_at_start, 1), COALESCE(t.remind_at_end, 1),
+			   COALESCE(t.sort_order, 0), COALESCE(t.is_builtin, 0),
+			   (SELECT COUNT(*) FROM todos WHERE todos.type = t.value)`
+
+// TodoTypeRepository 待办类型仓库
+type TodoTypeRepository struct {
+	db dbExecutor
+}
+
+// NewTodoTypeRepository 创建待办类型仓库实例
+func NewTodoTypeRepository(db *sql.DB) *TodoTypeRepository {
+	return &TodoTypeRepository{db: db}
+}
+
+// WithTx 返回使用指定事务的仓库副本
+func (r *TodoTypeRepository) WithTx(tx *sql.Tx) *TodoTypeRepository {
+	return &TodoTypeRepository{db: tx}
+}
+
+// List 获取所有类型，按排序号排列
+func (r *TodoTypeRepository) List() ([]models.TodoTypeDef, error) {
+	query := `SELECT ` + todoTypeColumns + ` FROM todo_types t ORDER BY t.sort_order ASC, t.id ASC`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []models.TodoTypeDef{}
+	for rows.Next() {
+		todoType, err := scanTodoType(rows)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, todoType)
+	}
+	return types, rows.Err()
+}
+
+// GetByValue 根据类型标识获取类型
+func (r *TodoTypeRepository) GetByValue(value models.TodoType) (*models.TodoTypeDef, error) {
+	query := `SELECT ` + todoTypeColumns + ` FROM todo_types t WHERE t.value = ?`
+	todoType, err := scanTodoType(r.db.QueryRow(query, value))
+	if err != nil {
+		return nil, err
+	}
+	return &todoType, nil
+}
+
+// Create 创建类型，未指定标识时自动生成；未指定排序号时排在最后
+func (r *TodoTypeRepository) Create(todoType *models.TodoTypeDef) (int64, error) {
+	todoType.Label = strings.TrimSpace(todoType.Label)
+	if todoType.Label == "" {
+		return 0, fmt.Errorf("类型名称不能为空")
+	}
+	todoType.Value = models.TodoType(strings.TrimSpace(string(todoType.Value)))
+	if todoType.Value == "" {
+		todoType.Value = models.TodoType(fmt.Sprintf("custom_%d", time.Now().UnixNano()))
+	}
+	if todoType.AdvanceRemind <= 0 {
+		todoType.AdvanceRemind = 15
+	}
+	if todoType.SortOrder <= 0 {
+		if err := r.db.QueryRow("SELECT COALESCE(MAX(sort_order), 0) + 1 FROM todo_types").Scan(&todoType.SortOrder); err != nil {
+			return 0, err
+		}
+	}
+
+	query := `
+		INSERT INTO todo_types (value, label, icon, color, advance_remind, remind_at_start, remind_at_end,
+			sort_order, is_builtin, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?)
+	`
+	now := time.Now()
+	result, err := r.db.Exec(query,
+		todoType.Value,
+		todoType.Label,
+		todoType.Icon,
+		todoType.Color,
+		todoType.AdvanceRemind,
+		todoType.RemindAtStart,
+		todoType.RemindAtEnd,
+		todoType.SortOrder,
+		now,
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Update 更新类型(类型标识不可修改)
+func (r *TodoTypeRepository) Update(todoType *models.TodoTypeDef) error {
+	todoType.Label = strings.TrimSpace(todoType.Label)
+	if todoType.Label == "" {
+		return fmt.Errorf("类型名称不能为空")
+	}
+	if todoType.AdvanceRemind <= 0 {
+		todoType.AdvanceRemind = 15
+	}
+	query := `
+		UPDATE todo_types SET
+			label = ?,
+			icon = ?,
+			color = ?,
+			advance_remind = ?,
+			remind_at_start = ?,
+			remind_at_end = ?,
+			sort_order = ?,
+			updated_at = ?
+		WHERE id = ?
+	`
+	_, err := r.db.Exec(query,
+		todoType.Label,
+		todoType.Icon,
+		todoType.Color,
+		todoType.AdvanceRemind,
+		todoType.RemindAtStart,
+		todoType.RemindAtEnd,
+		todoType.SortOrder,
+		time.Now(),
+		todoType.ID,
+	)
+	return err
+}
+
+// Reorder 按给定的类型标识顺序更新排序号
+func (r *TodoTypeRepository) Reorder(values []models.TodoType) error {
+	for i, value := range values {
+		if _, err := r.db.Exec("UPDATE todo_types SET sort_order = ?, updated_at = ? WHERE value = ?", i+1, time.Now(), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete 删除类型，使用该类型的待办改为 reassignTo
+func (r *TodoTypeRepository) Delete(value, reassignTo models.TodoType) error {
+	if value == reassignTo {
+		return fmt.Errorf("不能将待办重新分配到要删除的类型")
+	}
+	if _, err := r.GetByValue(reassignTo); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("类型不存在: %s", reassignTo)
+		}
+		return err
+	}
+	if _, err := r.db.Exec("UPDATE todos SET type = ?, updated_at = ? WHERE type = ?", reassignTo, time.Now(), value); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("DELETE FROM todo_types WHERE value = ?", value)
+	return err
+}
+
+// scanTodoType 扫描单个类型
+func scanTodoType(row rowScanner) (models.TodoTypeDef, error) {
+	var todoType models.TodoTypeDef
+	err := row.Scan(
+		&todoType.ID,
+		&todoType.Value,
+		&todoType.Label,
+		&todoType.Icon,
+		&todoType.Color,
+		&todoType.AdvanceRemind,
+		&todoType.RemindAtStart,
+		&todoType.RemindAtEnd,
+		&todoType.SortOrder,
+		&todoType.IsBuiltin,
+		&todoType.TodoCount,
+	)
+	return todoType, err
+}
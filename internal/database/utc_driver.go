@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// driverName 数据库驱动名称，写入的时间统一转换为 UTC，读出的时间转换为本地时区
+const driverName = "sqlite-utc"
+
+func init() {
+	sql.Register(driverName, utcDriver{&sqlite.Driver{}})
+}
+
+// utcDriver 包装 SQLite 驱动，保证数据库中只存储 UTC 时刻
+// 配合 _time_format=sqlite 使存储的时间字符串可以直接按字典序比较
+type utcDriver struct {
+	driver.Driver
+}
+
+// Open 打开连接
+func (d utcDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &utcConn{conn}, nil
+}
+
+// utcConn 转换参数和结果中时间的连接
+type utcConn struct {
+	driver.Conn
+}
+
+// CheckNamedValue 将时间参数转换为 UTC
+func (c *utcConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return driver.ErrSkip
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+	nv.Value = value
+	return nil
+}
+
+// Prepare 预编译语句
+func (c *utcConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext 预编译语句
+func (c *utcConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &utcStmt{stmt}, nil
+}
+
+// BeginTx 开始事务
+func (c *utcConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+// ExecContext 执行语句
+func (c *utcConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+// QueryContext 执行查询
+func (c *utcConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &utcRows{rows}, nil
+}
+
+// utcStmt 转换查询结果中时间的预编译语句
+type utcStmt struct {
+	driver.Stmt
+}
+
+// ExecContext 执行语句
+func (s *utcStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+}
+
+// QueryContext 执行查询
+func (s *utcStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return &utcRows{rows}, nil
+}
+
+// utcRows 将读出的时间转换为本地时区
+type utcRows struct {
+	driver.Rows
+}
+
+// Next 读取下一行
+func (r *utcRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, v := range dest {
+		if t, ok := v.(time.Time); ok {
+			dest[i] = t.In(time.Local)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package datadir
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+const (
+	// EnvDataDir 指定数据目录的环境变量
+	EnvDataDir = "TODO_CALENDAR_DATA_DIR"
+	// EnvPortable 启用便携模式的环境变量(值为1)
+	EnvPortable = "TODO_CALENDAR_PORTABLE"
+
+	// DatabaseFileName 数据库文件名
+	DatabaseFileName = "todo_calendar.db"
+
+	appName        = "TodoCalendar"
+	linuxAppName   = "todo-calendar"
+	portableMarker = "portable"
+	locationFile   = "location.json"
+)
+
+// Source 数据目录的来源
+type Source string
+
+const (
+	SourceFlag     Source = "flag"     // 命令行参数 --data-dir
+	SourceEnv      Source = "env"      // 环境变量
+	SourcePortable Source = "portable" // 便携模式(程序目录下的 data)
+	SourceConfig   Source = "config"   // 迁移后记录在配置文件中的位置
+	SourceLegacy   Source = "legacy"   // 旧版本程序目录下已有的数据
+	SourceDefault  Source = "default"  // 系统约定位置
+)
+
+var (
+	mu       sync.Mutex
+	resolved string
+	source   Source
+	override string
+)
+
+// SetOverride 通过命令行参数指定数据目录
+// 同时写入环境变量，使小部件和通知弹窗子进程使用相同的目录
+func SetOverride(dir string) {
+	mu.Lock()
+	defer mu.Unlock()
+	override = dir
+	resolved = ""
+	os.Setenv(EnvDataDir, dir)
+}
+
+// SetPortable 通过命令行参数启用便携模式，同样传递给子进程
+func SetPortable() {
+	mu.Lock()
+	defer mu.Unlock()
+	resolved = ""
+	os.Setenv(EnvPortable, "1")
+}
+
+// Dir 获取数据目录(确保目录存在)
+func Dir() (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if resolved != "" {
+		return resolved, nil
+	}
+
+	dir, src, err := resolve()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("无法创建数据目录 %s: %w", dir, err)
+	}
+	resolved, source = dir, src
+	return resolved, nil
+}
+
+// CurrentSource 获取当前数据目录的来源
+func CurrentSource() Source {
+	if _, err := Dir(); err != nil {
+		return ""
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return source
+}
+
+// DatabasePath 获取数据库文件路径
+func DatabasePath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, DatabaseFileName), nil
+}
+
+// AttachmentsDir 获取附件目录(确保目录存在)
+func AttachmentsDir() (string, error) {
+	return subDir("attachments")
+}
+
+// SoundsDir 获取自定义声音目录(确保目录存在)
+func SoundsDir() (string, error) {
+	return subDir("sounds")
+}
+
+// CacheDir 获取缓存目录(确保目录存在)
+func CacheDir() (string, error) {
+	return subDir("cache")
+}
+
+// subDir 获取数据目录下的子目录
+func subDir(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	sub := filepath.Join(dir, name)
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		return "", err
+	}
+	return sub, nil
+}
+
+// resolve 按优先级确定数据目录：
+// 命令行参数 > 环境变量 > 便携模式 > 迁移记录 > 旧版程序目录已有数据 > 系统约定位置
+func resolve() (string, Source, error) {
+	if override != "" {
+		return absPath(override, SourceFlag)
+	}
+	if dir := os.Getenv(EnvDataDir); dir != "" {
+		return absPath(dir, SourceEnv)
+	}
+
+	portableDir, err := PortableDir()
+	if err != nil {
+		return "", "", err
+	}
+	if IsPortable() {
+		return portableDir, SourcePortable, nil
+	}
+
+	if dir, err := loadLocation(); err == nil && dir != "" {
+		return dir, SourceConfig, nil
+	}
+
+	// 兼容旧版本：程序目录下已有数据库时继续使用，可通过迁移功能移动到新位置
+	if _, err := os.Stat(filepath.Join(portableDir, DatabaseFileName)); err == nil {
+		return portableDir, SourceLegacy, nil
+	}
+
+	dir, err := DefaultDir()
+	if err != nil {
+		return "", "", err
+	}
+	return dir, SourceDefault, nil
+}
+
+// absPath 转换为绝对路径
+func absPath(dir string, src Source) (string, Source, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	return abs, src, nil
+}
+
+// IsPortable 是否为便携模式(环境变量或程序目录下存在 portable 标记文件)
+func IsPortable() bool {
+	if os.Getenv(EnvPortable) == "1" {
+		return true
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(filepath.Dir(exe), portableMarker))
+	return err == nil
+}
+
+// PortableDir 便携模式的数据目录(程序目录下的 data)
+func PortableDir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(exe), "data"), nil
+}
+
+// DefaultDir 系统约定的数据目录
+// Windows: %APPDATA%\TodoCalendar；macOS: ~/Library/Application Support/TodoCalendar；
+// Linux: $XDG_DATA_HOME/todo-calendar (默认 ~/.local/share/todo-calendar)
+func DefaultDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, appName), nil
+		}
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(configDir, appName), nil
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Application Support", appName), nil
+	default:
+		if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+			return filepath.Join(dataHome, linuxAppName), nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".local", "share", linuxAppName), nil
+	}
+}
+
+// location 迁移后记录的数据目录
+type location struct {
+	DataDir string `json:"dataDir"`
+}
+
+// locationPath 迁移记录文件路径(位于系统配置目录，不随数据目录移动)
+func locationPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	name := appName
+	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		name = linuxAppName
+	}
+	return filepath.Join(configDir, name, locationFile), nil
+}
+
+// loadLocation 读取迁移记录
+func loadLocation() (string, error) {
+	path, err := locationPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var loc location
+	if err := json.Unmarshal(data, &loc); err != nil {
+		return "", err
+	}
+	return loc.DataDir, nil
+}
+
+// SaveLocation 记录新的数据目录，下次启动时生效
+func SaveLocation(dir string) error {
+	path, err := locationPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(location{DataDir: dir}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// CanRelocate 当前数据目录是否可以通过迁移功能更改
+// 命令行参数、环境变量和便携模式的优先级高于迁移记录，此时迁移不会生效
+func CanRelocate() bool {
+	switch CurrentSource() {
+	case SourceFlag, SourceEnv, SourcePortable:
+		return false
+	}
+	return true
+}
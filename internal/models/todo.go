@@ -3,6 +3,13 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -90,9 +97,10 @@ func (ft FlexTime) Value() (driver.Value, error) {
 	return ft.Time, nil
 }
 
-// TodoType 待办类型
+// TodoType 待办类型(对应 todo_types 表的 value)
 type TodoType string
 
+// 内置类型
 const (
 	TodoTypeBirthday    TodoType = "birthday"    // 生日
 	TodoTypeWork        TodoType = "work"        // 工作
@@ -101,6 +109,29 @@ const (
 	TodoTypeTask        TodoType = "task"        // 任务
 )
 
+// TodoTypeDef 待办类型定义
+type TodoTypeDef struct {
+	ID            int64    `json:"id"`
+	Value         TodoType `json:"value"`         // 类型标识(创建后不可修改)
+	Label         string   `json:"label"`         // 名称
+	Icon          string   `json:"icon"`          // 图标
+	Color         string   `json:"color"`         // 颜色
+	AdvanceRemind int      `json:"advanceRemind"` // 默认提前提醒(分钟)
+	RemindAtStart bool     `json:"remindAtStart"` // 默认到点提醒
+	RemindAtEnd   bool     `json:"remindAtEnd"`   // 默认结束提醒
+	SortOrder     int      `json:"sortOrder"`     // 排序
+	IsBuiltin     bool     `json:"isBuiltin"`     // 是否内置类型
+	TodoCount     int      `json:"todoCount"`     // 使用该类型的待办数量
+}
+
+// 优先级
+const (
+	PriorityNone   = 0 // 无
+	PriorityLow    = 1 // 低
+	PriorityMedium = 2 // 中
+	PriorityHigh   = 3 // 高
+)
+
 // RepeatType 循环类型
 type RepeatType string
 
@@ -112,6 +143,15 @@ const (
 	RepeatTypeCustom  RepeatType = "custom"  // 自定义(cron表达式)
 )
 
+// EditScope 循环待办的修改/删除范围
+type EditScope string
+
+const (
+	EditScopeSingle    EditScope = "single"    // 仅此实例
+	EditScopeFollowing EditScope = "following" // 此实例及之后
+	EditScopeAll       EditScope = "all"       // 整个系列
+)
+
 // Todo 待办事项模型
 type Todo struct {
 	ID                   int64     `json:"id"`
@@ -125,6 +165,7 @@ type Todo struct {
 	AdvanceRemind        int       `json:"advanceRemind"`        // 提前提醒(分钟)，默认15
 	RemindAtStart        bool      `json:"remindAtStart"`        // 到点提醒(开始时间)
 	RemindAtEnd          bool      `json:"remindAtEnd"`          // 结束提醒(结束时间)
+	Priority             int       `json:"priority"`             // 优先级: 0无 1低 2中 3高
 	StartRemindTriggered bool      `json:"startRemindTriggered"` // 开始提醒是否已触发
 	RepeatIndex          int       `json:"repeatIndex"`          // 循环序号(第几次)，0表示非循环
 	RepeatTotal          int       `json:"repeatTotal"`          // 循环总次数，0表示非循环
@@ -132,13 +173,293 @@ type Todo struct {
 	CompletedAt          *FlexTime `json:"completedAt"`          // 完成时间
 	CreatedAt            FlexTime  `json:"createdAt"`            // 创建时间
 	UpdatedAt            FlexTime  `json:"updatedAt"`            // 更新时间
-	// 以下字段仅用于创建时的批量生成，不存储在数据库
-	RepeatType      RepeatType `json:"repeatType,omitempty"`      // 循环类型
-	CronExpr        string     `json:"cronExpr,omitempty"`        // 自定义cron表达式
-	RepeatEndDate   *FlexTime  `json:"repeatEndDate,omitempty"`   // 循环终止时间
-	DurationMinutes int        `json:"durationMinutes,omitempty"` // 持续时间(分钟)，循环待办使用
+	// 循环规则：循环待办只存储一条系列记录，实例按需计算
+	RepeatType       RepeatType `json:"repeatType,omitempty"`      // 循环类型
+	CronExpr         string     `json:"cronExpr,omitempty"`        // 自定义cron表达式
+	RepeatEndDate    *FlexTime  `json:"repeatEndDate,omitempty"`   // 循环终止时间
+	RepeatCount      int        `json:"repeatCount,omitempty"`     // 循环次数上限，0表示以终止时间为准
+	DurationMinutes  int        `json:"durationMinutes,omitempty"` // 持续时间(分钟)，循环待办使用
+	SubtaskTotal     int        `json:"subtaskTotal"`              // 子任务总数
+	SubtaskCompleted int        `json:"subtaskCompleted"`          // 已完成的子任务数
+	IsBlocked        bool       `json:"isBlocked"`                 // 是否被未完成的前置待办阻塞
+	DeletedAt        *FlexTime  `json:"deletedAt"`                 // 移入回收站的时间
+	Tags             []Tag      `json:"tags"`                      // 标签(为 nil 时修改待办不改变标签)
+	IsOccurrence     bool       `json:"isOccurrence,omitempty"`    // 是否为系列展开出的实例(RepeatIndex为实例序号)
+	// 时区：IANA 名称或 floating，起止时间按该时区的墙上时间传入和返回
+	TimeZone string `json:"timeZone"`
+	// 全天待办只有日期：开始为首日 00:00，结束为末日 23:59:59，始终为浮动时间
+	AllDay     bool  `json:"allDay"`
+	CalendarID int64 `json:"calendarId"` // 所属日历，0表示默认日历
+	// 会议信息
+	Location   string     `json:"location"`   // 地点
+	MeetingURL string     `json:"meetingUrl"` // 线上会议链接(http/https)
+	Attendees  []Attendee `json:"attendees"`  // 参会人(为 nil 时修改待办不改变参会人)
+	// 自定义字段值，键为字段标识(为 nil 时修改待办不改变自定义字段)
+	CustomFields map[string]string `json:"customFields"`
+}
+
+// Attendee 参会人
+type Attendee struct {
+	Name  string `json:"name"`  // 姓名
+	Email string `json:"email"` // 邮箱
+}
+
+// MaxAdvanceRemindMinutes 提前提醒的最长时间(分钟)，提醒服务按此范围查询即将开始的待办
+const MaxAdvanceRemindMinutes = 24 * 60
+
+// TimeZoneFloating 浮动时间，不绑定时区，始终按本机当前时区的墙上时间解释(用于只有日期的待办)
+const TimeZoneFloating = "floating"
+
+// zoneCache 已加载的时区
+var zoneCache sync.Map
+
+// LoadZone 加载时区，空字符串和 floating 返回本地时区
+func LoadZone(name string) (*time.Location, error) {
+	if name == "" || name == TimeZoneFloating {
+		return time.Local, nil
+	}
+	if loc, ok := zoneCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	zoneCache.Store(name, loc)
+	return loc, nil
+}
+
+// IsFloating 是否为浮动时间
+func (t *Todo) IsFloating() bool {
+	return t.TimeZone == "" || t.TimeZone == TimeZoneFloating
+}
+
+// Zone 待办所在时区，无效的时区名按本地时区处理
+func (t *Todo) Zone() *time.Location {
+	loc, err := LoadZone(t.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// IsSeries 是否为循环系列
+func (t *Todo) IsSeries() bool {
+	return t.CronExpr != "" && t.RepeatType != RepeatTypeNone
+}
+
+// OccurrenceDuration 循环实例的持续时间(默认1小时)
+func (t *Todo) OccurrenceDuration() time.Duration {
+	if t.DurationMinutes > 0 {
+		return time.Duration(t.DurationMinutes) * time.Minute
+	}
+	if t.EndDate.Time.After(t.StartDate.Time) {
+		return t.EndDate.Time.Sub(t.StartDate.Time)
+	}
+	return time.Hour
+}
+
+// AllDayRange 返回全天待办从 start 当天开始、持续 duration 的起止时间
+func AllDayRange(start time.Time, duration time.Duration) (time.Time, time.Time) {
+	days := int(duration / (24 * time.Hour))
+	dayStart := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	dayEnd := time.Date(start.Year(), start.Month(), start.Day()+days, 23, 59, 59, 0, start.Location())
+	return dayStart, dayEnd
+}
+
+// TodoInstance 循环实例记录，仅保存完成状态和单次修改
+type TodoInstance struct {
+	ID                   int64     `json:"id"`
+	TodoID               int64     `json:"todoId"`
+	InstanceIndex        int       `json:"instanceIndex"`        // 实例序号(从1开始)
+	ScheduledAt          FlexTime  `json:"scheduledAt"`          // 按规则计算的开始时间
+	IsCompleted          bool      `json:"isCompleted"`          // 是否完成
+	CompletedAt          *FlexTime `json:"completedAt"`          // 完成时间
+	IsCancelled          bool      `json:"isCancelled"`          // 是否已取消(单次删除)
+	StartRemindTriggered bool      `json:"startRemindTriggered"` // 开始提醒是否已触发
+	Title                *string   `json:"title"`                // 覆盖标题
+	Content              *string   `json:"content"`              // 覆盖内容
+	StartDate            *FlexTime `json:"startDate"`            // 覆盖开始时间
+	EndDate              *FlexTime `json:"endDate"`              // 覆盖结束时间
+}
+
+// Subtask 子任务(待办的检查项)
+type Subtask struct {
+	ID          int64     `json:"id"`
+	TodoID      int64     `json:"todoId"`
+	Title       string    `json:"title"`       // 标题
+	IsCompleted bool      `json:"isCompleted"` // 是否完成
+	CompletedAt *FlexTime `json:"completedAt"` // 完成时间
+	SortOrder   int       `json:"sortOrder"`   // 排序
+	CreatedAt   FlexTime  `json:"createdAt"`
+	UpdatedAt   FlexTime  `json:"updatedAt"`
+}
+
+// TodoDependencies 待办的依赖关系
+type TodoDependencies struct {
+	BlockedBy []Todo `json:"blockedBy"` // 前置待办(需先完成)
+	Blocks    []Todo `json:"blocks"`    // 后续待办(等待此待办完成)
+}
+
+// DependencyEdge 依赖关系边，From 完成后 To 才能开始
+type DependencyEdge struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// DependencyGraph 依赖关系图(甘特图使用)
+// 循环待办以系列记录作为节点，不展开实例
+type DependencyGraph struct {
+	Nodes []Todo           `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// 修改来源窗口
+const (
+	OriginMain         = "main"         // 主窗口
+	OriginWidget       = "widget"       // 桌面小部件
+	OriginNotification = "notification" // 通知弹窗
+)
+
+// FieldChange 字段修改，值为字段的 JSON 表示
+type FieldChange struct {
+	Field string          `json:"field"` // 字段名(与 Todo 的 JSON 字段名一致)
+	Old   json.RawMessage `json:"old"`   // 修改前的值
+	New   json.RawMessage `json:"new"`   // 修改后的值
+}
+
+// TodoRevision 待办修改记录
+type TodoRevision struct {
+	ID        int64         `json:"id"`
+	TodoID    int64         `json:"todoId"`
+	Changes   []FieldChange `json:"changes"` // 修改的字段
+	Origin    string        `json:"origin"`  // 修改来源: main/widget/notification
+	CreatedAt FlexTime      `json:"createdAt"`
+}
+
+// 撤销/重做的操作类型
+const (
+	OperationCreate           = "create"            // 创建待办
+	OperationUpdate           = "update"            // 修改待办
+	OperationDelete           = "delete"            // 删除待办
+	OperationComplete         = "complete"          // 标记完成
+	OperationUncomplete       = "uncomplete"        // 取消完成
+	OperationAddAttachment    = "add_attachment"    // 上传附件
+	OperationDeleteAttachment = "delete_attachment" // 删除附件
+)
+
+// Operation 可撤销的操作
+type Operation struct {
+	ID        int64    `json:"id"`
+	Action    string   `json:"action"`  // 操作类型
+	TodoIDs   []int64  `json:"todoIds"` // 涉及的待办
+	Origin    string   `json:"origin"`  // 操作来源: main/widget/notification
+	Undone    bool     `json:"undone"`  // 是否已撤销
+	CreatedAt FlexTime `json:"createdAt"`
+}
+
+// UndoState 撤销/重做状态，没有可执行的操作时对应字段为 nil
+type UndoState struct {
+	Undo *Operation `json:"undo"` // 下一次撤销的操作
+	Redo *Operation `json:"redo"` // 下一次重做的操作
+}
+
+// Tag 标签
+type Tag struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`      // 名称(不区分大小写唯一)
+	Color     string    `json:"color"`     // 颜色
+	TodoCount int       `json:"todoCount"` // 使用该标签的待办数量(仅标签列表返回)
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 自定义字段类型
+const (
+	FieldTypeText   = "text"   // 文本
+	FieldTypeNumber = "number" // 数字
+	FieldTypeDate   = "date"   // 日期(YYYY-MM-DD)
+	FieldTypeChoice = "choice" // 单选
+	FieldTypeURL    = "url"    // 链接(http/https)
+)
+
+// CustomFieldDef 待办类型的自定义字段定义
+type CustomFieldDef struct {
+	ID        int64    `json:"id"`
+	TodoType  TodoType `json:"todoType"`  // 所属类型(创建后不可修改)
+	Key       string   `json:"key"`       // 字段标识，同一类型内唯一(创建后不可修改)
+	Label     string   `json:"label"`     // 名称
+	FieldType string   `json:"fieldType"` // 字段类型(创建后不可修改)
+	Options   []string `json:"options"`   // 单选字段的可选值
+	Required  bool     `json:"required"`  // 是否必填
+	SortOrder int      `json:"sortOrder"` // 排序
+}
+
+// NormalizeValue 校验字段值并转换为存储格式，空值返回空字符串
+func (d *CustomFieldDef) NormalizeValue(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	switch d.FieldType {
+	case FieldTypeText:
+		return value, nil
+	case FieldTypeNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return "", fmt.Errorf("%s 必须是数字", d.Label)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case FieldTypeDate:
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", fmt.Errorf("%s 必须是 YYYY-MM-DD 格式的日期", d.Label)
+		}
+		return date.Format("2006-01-02"), nil
+	case FieldTypeChoice:
+		for _, option := range d.Options {
+			if option == value {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("%s 不是可选值: %s", d.Label, value)
+	case FieldTypeURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("%s 必须是 http/https 链接", d.Label)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("未知的字段类型: %s", d.FieldType)
 }
 
+// IsValidFieldType 判断是否为支持的字段类型
+func IsValidFieldType(fieldType string) bool {
+	switch fieldType {
+	case FieldTypeText, FieldTypeNumber, FieldTypeDate, FieldTypeChoice, FieldTypeURL:
+		return true
+	}
+	return false
+}
+
+// Calendar 日历(待办分组)
+type Calendar struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`        // 名称
+	Color       string    `json:"color"`       // 颜色
+	DefaultType TodoType  `json:"defaultType"` // 新建待办的默认类型，为空时使用第一个类型
+	IsVisible   bool      `json:"isVisible"`   // 是否显示(隐藏的日历不出现在日历视图、小部件和通知中)
+	IsMuted     bool      `json:"isMuted"`     // 是否静音提醒
+	SortOrder   int       `json:"sortOrder"`   // 排序
+	TodoCount   int       `json:"todoCount"`   // 日历中的待办数量
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// 标签筛选方式
+const (
+	TagMatchAny = "any" // 包含任意一个标签
+	TagMatchAll = "all" // 包含全部标签
+)
+
 // Attachment 附件模型
 type Attachment struct {
 	ID            int64     `json:"id"`
@@ -154,27 +475,292 @@ type Attachment struct {
 
 // Settings 系统设置
 type Settings struct {
-	ID                    int64  `json:"id"`
-	EnableWidget          bool   `json:"enableWidget"`          // 启用桌面小部件
-	EnableAutoStart       bool   `json:"enableAutoStart"`       // 开机自启
-	MinimizeToTray        bool   `json:"minimizeToTray"`        // 最小化到托盘
-	NotificationSound     bool   `json:"notificationSound"`     // 通知声音
-	NotificationSoundFile string `json:"notificationSoundFile"` // 通知声音文件路径
-	NotificationDuration  int    `json:"notificationDuration"`  // 通知显示时长(秒)
-	WidgetPosition        string `json:"widgetPosition"`        // 小部件位置
-	WidgetOpacity         int    `json:"widgetOpacity"`         // 小部件透明度
-	Theme                 string `json:"theme"`                 // 主题
+	ID                     int64  `json:"id"`
+	EnableWidget           bool   `json:"enableWidget"`           // 启用桌面小部件
+	EnableAutoStart        bool   `json:"enableAutoStart"`        // 开机自启
+	MinimizeToTray         bool   `json:"minimizeToTray"`         // 最小化到托盘
+	NotificationSound      bool   `json:"notificationSound"`      // 通知声音
+	NotificationSoundFile  string `json:"notificationSoundFile"`  // 通知声音文件路径
+	NotificationDuration   int    `json:"notificationDuration"`   // 通知显示时长(秒)
+	WidgetPosition         string `json:"widgetPosition"`         // 小部件位置
+	WidgetOpacity          int    `json:"widgetOpacity"`          // 小部件透明度
+	Theme                  string `json:"theme"`                  // 主题
+	AutoCompleteParent     bool   `json:"autoCompleteParent"`     // 子任务全部完成时自动完成待办
+	TrashRetentionDays     int    `json:"trashRetentionDays"`     // 回收站保留天数，0表示不自动清理
+	AllDayRemindTime       string `json:"allDayRemindTime"`       // 全天待办的提醒时间(HH:MM)
+	AllDayRemindDaysBefore int    `json:"allDayRemindDaysBefore"` // 全天待办提前几天提醒，0表示当天
+	// 错过的提醒(睡眠或程序未运行期间)在多少分钟内补发，0表示不补发
+	MissedReminderGraceMinutes int `json:"missedReminderGraceMinutes"`
+	// 番茄钟
+	PomodoroWorkMinutes      int `json:"pomodoroWorkMinutes"`      // 专注时长(分钟)
+	PomodoroBreakMinutes     int `json:"pomodoroBreakMinutes"`     // 短休息时长(分钟)
+	PomodoroLongBreakMinutes int `json:"pomodoroLongBreakMinutes"` // 长休息时长(分钟)
+	PomodoroLongBreakEvery   int `json:"pomodoroLongBreakEvery"`   // 每完成几个番茄长休息一次，0表示不长休息
+}
+
+// PomodoroBreakMinutesAfter 返回当天完成第 completed 个番茄后的休息时长
+func (s *Settings) PomodoroBreakMinutesAfter(completed int) int {
+	if s.PomodoroLongBreakEvery > 0 && completed > 0 && completed%s.PomodoroLongBreakEvery == 0 {
+		return s.PomodoroLongBreakMinutes
+	}
+	return s.PomodoroBreakMinutes
+}
+
+// DefaultAllDayRemindTime 全天待办的默认提醒时间
+const DefaultAllDayRemindTime = "09:00"
+
+// DefaultMissedReminderGraceMinutes 错过的提醒默认补发时间(分钟)
+const DefaultMissedReminderGraceMinutes = 60
+
+// MaxMissedReminderGraceMinutes 错过的提醒最多补发多久之前的(分钟)
+const MaxMissedReminderGraceMinutes = 24 * 60
+
+// 番茄钟默认设置
+const (
+	DefaultPomodoroWorkMinutes      = 25 // 专注时长(分钟)
+	DefaultPomodoroBreakMinutes     = 5  // 短休息时长(分钟)
+	DefaultPomodoroLongBreakMinutes = 15 // 长休息时长(分钟)
+	DefaultPomodoroLongBreakEvery   = 4  // 每完成几个番茄长休息一次
+)
+
+// AllDayRemindAt 返回日期为 day 的全天待办的提醒时间
+func (s *Settings) AllDayRemindAt(day time.Time) time.Time {
+	clock, err := time.Parse("15:04", s.AllDayRemindTime)
+	if err != nil {
+		clock, _ = time.Parse("15:04", DefaultAllDayRemindTime)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day()-s.AllDayRemindDaysBefore, clock.Hour(), clock.Minute(), 0, 0, day.Location())
+}
+
+// DataLocation 数据目录信息
+type DataLocation struct {
+	Dir         string `json:"dir"`         // 当前数据目录
+	Source      string `json:"source"`      // 来源: flag/env/portable/config/legacy/default
+	DefaultDir  string `json:"defaultDir"`  // 系统约定的数据目录
+	PortableDir string `json:"portableDir"` // 便携模式的数据目录
+	IsPortable  bool   `json:"isPortable"`  // 是否便携模式
+	CanMove     bool   `json:"canMove"`     // 是否可以通过迁移功能更改
 }
 
 // TodoFilter 待办筛选条件
 type TodoFilter struct {
-	Keyword   string   `json:"keyword"`   // 搜索关键词
-	Year      int      `json:"year"`      // 年份
-	Month     int      `json:"month"`     // 月份
-	Types     []string `json:"types"`     // 类型筛选
-	Completed *bool    `json:"completed"` // 完成状态
-	Page      int      `json:"page"`      // 页码
-	PageSize  int      `json:"pageSize"`  // 每页数量
+	Keyword      string              `json:"keyword"`      // 搜索关键词
+	Year         int                 `json:"year"`         // 年份
+	Month        int                 `json:"month"`        // 月份
+	Types        []string            `json:"types"`        // 类型筛选
+	Completed    *bool               `json:"completed"`    // 完成状态
+	TagIDs       []int64             `json:"tagIds"`       // 标签筛选
+	TagMatch     string              `json:"tagMatch"`     // 标签筛选方式: any(默认)/all
+	CalendarIDs  []int64             `json:"calendarIds"`  // 日历筛选
+	CustomFields []CustomFieldFilter `json:"customFields"` // 自定义字段筛选，需同时满足
+	Sort         []SortKey           `json:"sort"`         // 排序，依次比较；为空时按开始时间升序
+	Page         int                 `json:"page"`         // 页码
+	PageSize     int                 `json:"pageSize"`     // 每页数量
+}
+
+// 自定义字段比较方式
+const (
+	FieldOpEq       = "eq"       // 等于(默认)
+	FieldOpContains = "contains" // 包含(不区分大小写)
+	FieldOpGt       = "gt"       // 大于
+	FieldOpGte      = "gte"      // 大于等于
+	FieldOpLt       = "lt"       // 小于
+	FieldOpLte      = "lte"      // 小于等于
+	FieldOpExists   = "exists"   // 有值
+)
+
+// CustomFieldFilter 自定义字段筛选条件
+// 数字字段按数值比较，日期字段按 YYYY-MM-DD 比较，其他按文本比较
+type CustomFieldFilter struct {
+	Key   string `json:"key"`   // 字段标识
+	Op    string `json:"op"`    // 比较方式
+	Value string `json:"value"` // 比较值
+}
+
+// 排序字段
+const (
+	SortByPriority = "priority" // 优先级
+	SortByStart    = "start"    // 开始时间
+	SortByEnd      = "end"      // 结束时间
+	SortByCreated  = "created"  // 创建时间
+	SortByUpdated  = "updated"  // 更新时间
+	SortByTitle    = "title"    // 标题
+)
+
+// SortKey 排序条件
+type SortKey struct {
+	Key  string `json:"key"`  // 排序字段
+	Desc bool   `json:"desc"` // 是否降序
+}
+
+// ValidateSort 校验排序字段
+func (f TodoFilter) ValidateSort() error {
+	for _, key := range f.Sort {
+		if _, ok := compareTodos(&Todo{}, &Todo{}, key.Key); !ok {
+			return fmt.Errorf("invalid sort key: %s", key.Key)
+		}
+	}
+	return nil
+}
+
+// SortTodos 按排序条件排列已展开的待办列表，相同时按开始时间和ID排列
+func (f TodoFilter) SortTodos(todos []Todo) {
+	sort.SliceStable(todos, func(i, j int) bool {
+		for _, key := range f.Sort {
+			c, ok := compareTodos(&todos[i], &todos[j], key.Key)
+			if !ok || c == 0 {
+				continue
+			}
+			if key.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		if c, _ := compareTodos(&todos[i], &todos[j], SortByStart); c != 0 {
+			return c < 0
+		}
+		return todos[i].ID < todos[j].ID
+	})
+}
+
+// compareTodos 按指定字段比较两个待办，返回 -1、0、1；字段未知时 ok 为 false
+func compareTodos(a, b *Todo, key string) (c int, ok bool) {
+	switch key {
+	case SortByPriority:
+		return compareInt(a.Priority, b.Priority), true
+	case SortByStart:
+		return a.StartDate.Time.Compare(b.StartDate.Time), true
+	case SortByEnd:
+		return a.EndDate.Time.Compare(b.EndDate.Time), true
+	case SortByCreated:
+		return a.CreatedAt.Time.Compare(b.CreatedAt.Time), true
+	case SortByUpdated:
+		return a.UpdatedAt.Time.Compare(b.UpdatedAt.Time), true
+	case SortByTitle:
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)), true
+	}
+	return 0, false
+}
+
+// compareInt 比较两个整数
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// MatchTags 判断标签是否满足筛选条件
+func (f TodoFilter) MatchTags(tags []Tag) bool {
+	if len(f.TagIDs) == 0 {
+		return true
+	}
+	has := make(map[int64]bool, len(tags))
+	for _, tag := range tags {
+		has[tag.ID] = true
+	}
+	for _, id := range f.TagIDs {
+		if has[id] && f.TagMatch != TagMatchAll {
+			return true
+		}
+		if !has[id] && f.TagMatch == TagMatchAll {
+			return false
+		}
+	}
+	return f.TagMatch == TagMatchAll
+}
+
+// MatchCalendar 判断日历是否满足筛选条件
+func (f TodoFilter) MatchCalendar(calendarID int64) bool {
+	if len(f.CalendarIDs) == 0 {
+		return true
+	}
+	for _, id := range f.CalendarIDs {
+		if id == calendarID {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchCustomFields 判断自定义字段值是否满足全部筛选条件
+func (f TodoFilter) MatchCustomFields(values map[string]string) bool {
+	for _, cond := range f.CustomFields {
+		value := values[cond.Key]
+		if cond.Op == FieldOpExists {
+			if value == "" {
+				return false
+			}
+			continue
+		}
+		if value == "" {
+			return false
+		}
+		if cond.Op == FieldOpContains {
+			if !strings.Contains(strings.ToLower(value), strings.ToLower(cond.Value)) {
+				return false
+			}
+			continue
+		}
+		c := compareFieldValues(value, cond.Value)
+		switch cond.Op {
+		case FieldOpGt:
+			if c <= 0 {
+				return false
+			}
+		case FieldOpGte:
+			if c < 0 {
+				return false
+			}
+		case FieldOpLt:
+			if c >= 0 {
+				return false
+			}
+		case FieldOpLte:
+			if c > 0 {
+				return false
+			}
+		default:
+			if c != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ValidateCustomFields 校验自定义字段筛选条件
+func (f TodoFilter) ValidateCustomFields() error {
+	for _, cond := range f.CustomFields {
+		if cond.Key == "" {
+			return fmt.Errorf("custom field filter requires a key")
+		}
+		switch cond.Op {
+		case "", FieldOpEq, FieldOpContains, FieldOpGt, FieldOpGte, FieldOpLt, FieldOpLte, FieldOpExists:
+		default:
+			return fmt.Errorf("invalid custom field operator: %s", cond.Op)
+		}
+	}
+	return nil
+}
+
+// compareFieldValues 比较两个字段值，都是数字时按数值比较，否则按文本比较
+func compareFieldValues(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
 }
 
 // TodoListResult 待办列表结果
@@ -186,6 +772,23 @@ type TodoListResult struct {
 	TotalPages int    `json:"totalPages"`
 }
 
+// BulkTarget 批量操作的目标待办：指定待办ID，或按筛选条件选择(不分页)
+type BulkTarget struct {
+	IDs    []int64     `json:"ids"`    // 待办ID
+	Filter *TodoFilter `json:"filter"` // 筛选条件，与 IDs 二选一
+}
+
+// SearchResult 全文搜索结果
+// 片段已转义为 HTML，其中的匹配文本使用 <mark></mark> 包裹
+type SearchResult struct {
+	Todo              Todo    `json:"todo"`
+	Score             float64 `json:"score"`             // 相关度，越大越相关
+	TitleSnippet      string  `json:"titleSnippet"`      // 标题片段
+	ContentSnippet    string  `json:"contentSnippet"`    // 内容片段
+	AttachmentSnippet string  `json:"attachmentSnippet"` // 附件名片段
+	DetailsSnippet    string  `json:"detailsSnippet"`    // 地点、会议链接和参会人片段
+}
+
 // WeekTodosResult 本周待办结果
 type WeekTodosResult struct {
 	Overdue []Todo `json:"overdue"` // 逾期待办
@@ -220,8 +823,9 @@ type CalendarDay struct {
 	Lunar          LunarDate `json:"lunar"`      // 农历信息
 	IsToday        bool      `json:"isToday"`
 	IsCurrentMonth bool      `json:"isCurrentMonth"`
-	Todos          []Todo    `json:"todos"`     // 当天待办
-	TodoCount      int       `json:"todoCount"` // 待办数量
+	Todos          []Todo    `json:"todos"`       // 当天待办(全天待办在前)
+	TodoCount      int       `json:"todoCount"`   // 待办数量
+	AllDayCount    int       `json:"allDayCount"` // 全天待办数量(排在 Todos 的前面)
 }
 
 // WeekTodos 本周待办
@@ -239,3 +843,227 @@ type NotificationData struct {
 	TotalCount   int    `json:"totalCount"`   // 总提醒次数
 	Message      string `json:"message"`
 }
+
+// CompletionStats 完成情况统计
+type CompletionStats struct {
+	Total          int     `json:"total"`          // 待办数量
+	Completed      int     `json:"completed"`      // 已完成数量
+	CompletionRate float64 `json:"completionRate"` // 完成率(0-1)
+	OnTime         int     `json:"onTime"`         // 在结束时间前完成的数量
+	Late           int     `json:"late"`           // 超过结束时间才完成的数量
+	AvgLateMinutes float64 `json:"avgLateMinutes"` // 平均延迟(完成时间减结束时间，分钟，负数表示提前)
+	Overdue        int     `json:"overdue"`        // 已过结束时间仍未完成的数量
+}
+
+// TypeStats 按类型统计的完成情况
+type TypeStats struct {
+	Type  TodoType        `json:"type"`
+	Stats CompletionStats `json:"stats"`
+}
+
+// WeekStats 按周统计的完成情况
+type WeekStats struct {
+	WeekStart string          `json:"weekStart"` // 周一，使用字符串格式: "2006-01-02"
+	Stats     CompletionStats `json:"stats"`
+	// 该周结束时已到期仍未完成的待办数量(只统计范围内的待办，未结束的周为当前的数量)
+	OpenOverdue int `json:"openOverdue"`
+}
+
+// SeriesStats 循环系列的执行情况(只统计已到结束时间的实例)
+type SeriesStats struct {
+	TodoID         int64    `json:"todoId"`
+	Title          string   `json:"title"`
+	Type           TodoType `json:"type"`
+	Due            int      `json:"due"`            // 已到期的实例数
+	Completed      int      `json:"completed"`      // 已完成的实例数
+	OnTime         int      `json:"onTime"`         // 按时完成的实例数
+	CompletionRate float64  `json:"completionRate"` // 完成率(0-1)
+	OnTimeRate     float64  `json:"onTimeRate"`     // 按时完成率(0-1)
+}
+
+// Analytics 时间范围内的统计数据，按开始时间统计范围内的待办(循环待办按实例统计)
+type Analytics struct {
+	StartDate string          `json:"startDate"` // 使用字符串格式: "2006-01-02"
+	EndDate   string          `json:"endDate"`   // 使用字符串格式: "2006-01-02"
+	Summary   CompletionStats `json:"summary"`   // 总体完成情况
+	ByType    []TypeStats     `json:"byType"`    // 按类型
+	ByWeek    []WeekStats     `json:"byWeek"`    // 按周(周一开始)
+	Weekdays  []int           `json:"weekdays"`  // 每个星期几的待办数量，下标0为周一
+	Hours     []int           `json:"hours"`     // 每个小时开始的待办数量(不含全天待办)，按本地时间
+	Series    []SeriesStats   `json:"series"`    // 循环系列
+}
+
+// 计时记录类型
+const (
+	TimeEntryTimer    = "timer"    // 计时器
+	TimeEntryManual   = "manual"   // 手动添加
+	TimeEntryPomodoro = "pomodoro" // 番茄钟专注
+	TimeEntryBreak    = "break"    // 番茄钟休息(不计入用时)
+)
+
+// TimeEntry 待办的计时记录
+type TimeEntry struct {
+	ID             int64     `json:"id"`
+	TodoID         int64     `json:"todoId"`
+	TodoTitle      string    `json:"todoTitle"`      // 待办标题
+	Kind           string    `json:"kind"`           // 记录类型
+	StartedAt      FlexTime  `json:"startedAt"`      // 开始时间
+	EndedAt        *FlexTime `json:"endedAt"`        // 结束时间，为 nil 时正在计时
+	PlannedMinutes int       `json:"plannedMinutes"` // 番茄钟计划时长(分钟)
+	Note           string    `json:"note"`           // 备注
+	Seconds        int64     `json:"seconds"`        // 用时(秒)，正在计时的记录算到当前时间
+	CreatedAt      FlexTime  `json:"createdAt"`
+}
+
+// IsRunning 是否正在计时
+func (e *TimeEntry) IsRunning() bool {
+	return e.EndedAt == nil
+}
+
+// PlannedEnd 番茄钟计划结束时间，没有计划时长时返回零值
+func (e *TimeEntry) PlannedEnd() time.Time {
+	if e.PlannedMinutes <= 0 {
+		return time.Time{}
+	}
+	return e.StartedAt.Time.Add(time.Duration(e.PlannedMinutes) * time.Minute)
+}
+
+// TodoTime 单个待办的用时
+type TodoTime struct {
+	TodoID  int64    `json:"todoId"`
+	Title   string   `json:"title"`
+	Type    TodoType `json:"type"`
+	Seconds int64    `json:"seconds"`
+}
+
+// TypeTime 单个类型的用时
+type TypeTime struct {
+	Type    TodoType `json:"type"`
+	Seconds int64    `json:"seconds"`
+}
+
+// DayTime 单日的用时
+type DayTime struct {
+	Date    string `json:"date"` // 使用字符串格式: "2006-01-02"
+	Seconds int64  `json:"seconds"`
+}
+
+// TimeTotals 时间范围内的用时统计(不含番茄钟休息)，跨天的记录按本地日期拆分
+type TimeTotals struct {
+	StartDate    string     `json:"startDate"` // 使用字符串格式: "2006-01-02"
+	EndDate      string     `json:"endDate"`   // 使用字符串格式: "2006-01-02"
+	TotalSeconds int64      `json:"totalSeconds"`
+	ByTodo       []TodoTime `json:"byTodo"` // 按用时从多到少排列
+	ByType       []TypeTime `json:"byType"`
+	ByDay        []DayTime  `json:"byDay"` // 范围内的每一天
+}
+
+// 提醒类型
+const (
+	ReminderAdvance  = "advance"  // 提前提醒
+	ReminderStart    = "start"    // 到点提醒
+	ReminderEnd      = "end"      // 结束提醒
+	ReminderPomodoro = "pomodoro" // 番茄钟专注/休息结束(不可推迟)
+)
+
+// IsValidReminder 判断是否为可推迟的提醒类型
+func IsValidReminder(reminder string) bool {
+	switch reminder {
+	case ReminderAdvance, ReminderStart, ReminderEnd:
+		return true
+	}
+	return false
+}
+
+// 推迟提醒的方式
+const (
+	Snooze5Minutes  = "5m"       // 5分钟后
+	Snooze10Minutes = "10m"      // 10分钟后
+	Snooze30Minutes = "30m"      // 30分钟后
+	SnoozeTomorrow  = "tomorrow" // 明天 09:00
+	SnoozeCustom    = "custom"   // 指定时间
+)
+
+// SnoozeTomorrowTime 推迟到明天时的提醒时间
+const SnoozeTomorrowTime = "09:00"
+
+// SnoozeRequest 推迟提醒请求
+type SnoozeRequest struct {
+	TodoID      int64    `json:"todoId"`
+	RepeatIndex int      `json:"repeatIndex"` // 循环实例序号，0表示非循环待办
+	Reminder    string   `json:"reminder"`    // 提醒类型: advance/start/end
+	Option      string   `json:"option"`      // 推迟方式
+	Until       FlexTime `json:"until"`       // 指定时间(custom 时使用)
+}
+
+// FireAt 返回推迟后的提醒时间
+func (r SnoozeRequest) FireAt(now time.Time) (time.Time, error) {
+	switch r.Option {
+	case Snooze5Minutes:
+		return now.Add(5 * time.Minute), nil
+	case Snooze10Minutes:
+		return now.Add(10 * time.Minute), nil
+	case Snooze30Minutes:
+		return now.Add(30 * time.Minute), nil
+	case SnoozeTomorrow:
+		clock, _ := time.Parse("15:04", SnoozeTomorrowTime)
+		return time.Date(now.Year(), now.Month(), now.Day()+1, clock.Hour(), clock.Minute(), 0, 0, now.Location()), nil
+	case SnoozeCustom:
+		if !r.Until.Time.After(now) {
+			return time.Time{}, fmt.Errorf("推迟到的时间必须晚于当前时间")
+		}
+		return r.Until.Time, nil
+	}
+	return time.Time{}, fmt.Errorf("未知的推迟方式: %s", r.Option)
+}
+
+// ReminderSnooze 推迟的提醒，到时由提醒服务重新发送
+type ReminderSnooze struct {
+	ID          int64    `json:"id"`
+	TodoID      int64    `json:"todoId"`
+	TodoTitle   string   `json:"todoTitle"`   // 待办标题
+	RepeatIndex int      `json:"repeatIndex"` // 循环实例序号，0表示非循环待办
+	Reminder    string   `json:"reminder"`    // 提醒类型
+	FireAt      FlexTime `json:"fireAt"`      // 下次提醒时间
+	CreatedAt   FlexTime `json:"createdAt"`
+}
+
+// 通知的处理结果
+const (
+	OutcomeShown     = "shown"     // 已弹出，用户未处理
+	OutcomeDismissed = "dismissed" // 关闭
+	OutcomeSnoozed   = "snoozed"   // 稍后提醒
+	OutcomeCompleted = "completed" // 标记完成
+	OutcomeOpened    = "opened"    // 查看详情或打开会议链接
+)
+
+// IsValidOutcome 判断是否为用户可以做出的处理结果
+func IsValidOutcome(outcome string) bool {
+	switch outcome {
+	case OutcomeDismissed, OutcomeSnoozed, OutcomeCompleted, OutcomeOpened:
+		return true
+	}
+	return false
+}
+
+// NotificationLog 通知记录
+type NotificationLog struct {
+	ID          int64     `json:"id"`
+	TodoID      int64     `json:"todoId"`
+	RepeatIndex int       `json:"repeatIndex"` // 循环实例序号，0表示非循环待办
+	Reminder    string    `json:"reminder"`    // 提醒类型
+	Title       string    `json:"title"`       // 通知标题
+	Message     string    `json:"message"`     // 通知内容
+	Outcome     string    `json:"outcome"`     // 处理结果
+	NotifiedAt  FlexTime  `json:"notifiedAt"`  // 通知时间
+	RespondedAt *FlexTime `json:"respondedAt"` // 处理时间，未处理时为 nil
+}
+
+// NotificationLogFilter 通知记录筛选条件
+type NotificationLogFilter struct {
+	TodoID    int64  `json:"todoId"`    // 待办ID，0表示全部
+	StartDate string `json:"startDate"` // 开始日期(包含)，使用字符串格式: "2006-01-02"
+	EndDate   string `json:"endDate"`   // 结束日期(包含)，使用字符串格式: "2006-01-02"
+	Outcome   string `json:"outcome"`   // 处理结果，为空表示全部
+	Limit     int    `json:"limit"`     // 最多返回条数，默认200
+}
@@ -6,6 +6,8 @@ import (
 	"embed"
 	"os"
 	"path/filepath"
+
+	"todo-calendar/internal/datadir"
 )
 
 //go:embed sounds/*.wav
@@ -20,15 +22,11 @@ func GetEmbeddedSoundPath(name string) (string, error) {
 		return "", err
 	}
 
-	// 获取临时目录
-	exe, err := os.Executable()
+	// 获取缓存目录
+	tempDir, err := datadir.CacheDir()
 	if err != nil {
 		return "", err
 	}
-	tempDir := filepath.Join(filepath.Dir(exe), "data", "cache")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return "", err
-	}
 
 	// 写入临时文件
 	tempPath := filepath.Join(tempDir, name)
@@ -5,7 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"sync"
+	"sort"
+	"strings"
 	"time"
 
 	"todo-calendar/internal/database"
@@ -19,21 +20,45 @@ import (
 type NotificationType string
 
 const (
-	NotifyAdvance NotificationType = "advance" // 提前提醒
-	NotifyStart   NotificationType = "start"   // 到点提醒
-	NotifyEnd     NotificationType = "end"     // 结束提醒
+	NotifyAdvance  NotificationType = models.ReminderAdvance  // 提前提醒
+	NotifyStart    NotificationType = models.ReminderStart    // 到点提醒
+	NotifyEnd      NotificationType = models.ReminderEnd      // 结束提醒
+	NotifyPomodoro NotificationType = models.ReminderPomodoro // 番茄钟专注/休息结束
+	NotifyMissed   NotificationType = "missed"                // 错过的提醒汇总
 )
 
+// firedRetention 已发送提醒的保留时间，提醒key包含日期，过了当天就不会再用到
+const firedRetention = 7 * 24 * time.Hour
+
+// missedAfter 提醒时间早于检查时间超过该时长时视为错过的提醒
+const missedAfter = 2 * time.Minute
+
+// missedSummaryThreshold 错过的提醒超过该数量时合并为一条汇总通知
+const missedSummaryThreshold = 3
+
+// reminder 到时待发送的提醒
+type reminder struct {
+	todo       models.Todo
+	notifyType NotificationType
+	at         time.Time // 提醒时间
+	title      string
+	message    string
+}
+
 // Notifier 通知管理器
 type Notifier struct {
 	ctx          context.Context
 	db           *sql.DB
 	todoRepo     *database.TodoRepository
 	settingsRepo *database.SettingsRepository
+	calendarRepo *database.CalendarRepository
+	entryRepo    *database.TimeEntryRepository
+	snoozeRepo   *database.SnoozeRepository
+	logRepo      *database.NotificationLogRepository
+	firedRepo    *database.FiredReminderRepository
+	popup        func(args ...string) // 启动通知弹窗
 	ticker       *time.Ticker
 	stopChan     chan struct{}
-	notifiedMap  map[string]bool // 记录已通知的key: "todoID-type-date"
-	notifiedLock sync.RWMutex
 }
 
 // NewNotifier 创建通知管理器
@@ -42,8 +67,13 @@ func NewNotifier(db *sql.DB) *Notifier {
 		db:           db,
 		todoRepo:     database.NewTodoRepository(db),
 		settingsRepo: database.NewSettingsRepository(db),
+		calendarRepo: database.NewCalendarRepository(db),
+		entryRepo:    database.NewTimeEntryRepository(db),
+		snoozeRepo:   database.NewSnoozeRepository(db),
+		logRepo:      database.NewNotificationLogRepository(db),
+		firedRepo:    database.NewFiredReminderRepository(db),
+		popup:        startPopup,
 		stopChan:     make(chan struct{}),
-		notifiedMap:  make(map[string]bool),
 	}
 }
 
@@ -52,23 +82,17 @@ func (n *Notifier) SetContext(ctx context.Context) {
 	n.ctx = ctx
 }
 
-// getNotifyKey 生成通知key，用于避免重复通知
-func (n *Notifier) getNotifyKey(todoID int64, notifyType NotificationType, date time.Time) string {
-	return fmt.Sprintf("%d-%s-%s", todoID, notifyType, date.Format("2006-01-02"))
-}
-
-// hasNotified 检查是否已通知
-func (n *Notifier) hasNotified(key string) bool {
-	n.notifiedLock.RLock()
-	defer n.notifiedLock.RUnlock()
-	return n.notifiedMap[key]
+// getNotifyKey 生成通知key，用于避免重复通知，date 为提醒时间
+// 循环待办的每个实例使用自己的序号，避免同一天的多个实例互相覆盖
+func (n *Notifier) getNotifyKey(todo models.Todo, notifyType NotificationType, date time.Time) string {
+	return fmt.Sprintf("%d-%d-%s-%s", todo.ID, todo.RepeatIndex, notifyType, date.Format("2006-01-02"))
 }
 
-// markNotified 标记已通知
-func (n *Notifier) markNotified(key string) {
-	n.notifiedLock.Lock()
-	defer n.notifiedLock.Unlock()
-	n.notifiedMap[key] = true
+// claimNotify 在数据库中登记提醒时间为 at 的提醒，返回是否应由本次发送
+// 已登记(重启前或其他进程已发送)或登记失败时不发送
+func (n *Notifier) claimNotify(todo models.Todo, notifyType NotificationType, at time.Time) bool {
+	claimed, err := n.firedRepo.Claim(n.getNotifyKey(todo, notifyType, at), todo.ID, time.Now())
+	return err == nil && claimed
 }
 
 // StartNotificationChecker 启动通知检查器
@@ -98,11 +122,9 @@ func (n *Notifier) CheckPendingTodos() {
 }
 
 // checkAndNotify 检查并发送通知
+// 检查的时间窗口从上次检查开始，睡眠唤醒或程序启动时补发宽限时间内错过的提醒
 func (n *Notifier) checkAndNotify() {
-	todos, err := n.todoRepo.GetPendingTodos()
-	if err != nil {
-		return
-	}
+	now := time.Now()
 
 	// 获取设置，检查是否开启声音
 	settings, err := n.settingsRepo.Get()
@@ -111,84 +133,298 @@ func (n *Notifier) checkAndNotify() {
 	if err == nil {
 		playSound = settings.NotificationSound
 		soundFile = settings.NotificationSoundFile
+	} else {
+		settings = &models.Settings{
+			AllDayRemindTime:           models.DefaultAllDayRemindTime,
+			MissedReminderGraceMinutes: models.DefaultMissedReminderGraceMinutes,
+			PomodoroBreakMinutes:       models.DefaultPomodoroBreakMinutes,
+			PomodoroLongBreakMinutes:   models.DefaultPomodoroLongBreakMinutes,
+			PomodoroLongBreakEvery:     models.DefaultPomodoroLongBreakEvery,
+		}
 	}
 
-	now := time.Now()
-	today := now.Format("2006-01-02")
+	// 清理过期的已发送提醒
+	n.firedRepo.Prune(now.Add(-firedRetention))
 
+	// 番茄钟专注/休息到时
+	n.checkPomodoro(now, settings, playSound, soundFile)
+
+	// 获取检查窗口内的待办，循环待办展开为实例
+	// 提前提醒和全天待办的提前几天提醒会提醒之后开始的待办，查询范围延长到提醒覆盖的时间
+	from := n.checkWindowStart(now, settings)
+	rangeStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	rangeEnd := todayStart.AddDate(0, 0, settings.AllDayRemindDaysBefore+1).Add(-time.Second)
+	if advanceEnd := now.Add(models.MaxAdvanceRemindMinutes * time.Minute); advanceEnd.After(rangeEnd) {
+		rangeEnd = advanceEnd
+	}
+	todos, err := n.todoRepo.GetByDateRange(rangeStart, rangeEnd)
+	if err != nil {
+		return
+	}
+
+	// 隐藏或静音的日历中的待办不提醒
+	silenced, err := n.calendarRepo.SilencedIDs()
+	if err != nil {
+		return
+	}
+
+	// 推迟的提醒到时重新发送
+	n.checkSnoozes(now, silenced, playSound, soundFile)
+
+	var missed []reminder
 	for _, todo := range todos {
-		if todo.IsCompleted {
+		if todo.IsCompleted || silenced[todo.CalendarID] {
 			continue
 		}
+		for _, r := range n.dueReminders(todo, from, now, settings) {
+			if !n.claimNotify(r.todo, r.notifyType, r.at) {
+				continue
+			}
+			if now.Sub(r.at) >= missedAfter {
+				missed = append(missed, r)
+				continue
+			}
+			n.sendWindowsNotification(r.todo, r.title, r.message, playSound, soundFile, r.notifyType)
+		}
+	}
+	n.notifyMissed(missed, playSound, soundFile)
 
-		startTime := todo.StartDate.Time
-		endTime := todo.EndDate.Time
+	n.settingsRepo.SetLastReminderCheck(now)
+}
 
-		// 检查是否是今天的待办
-		if startTime.Format("2006-01-02") != today && endTime.Format("2006-01-02") != today {
-			continue
-		}
+// checkWindowStart 返回本次检查窗口的起点：上次检查的时间，最早追溯到补发宽限时间之前
+// 从未检查过、时钟被调回或不补发时只检查当前这一分钟
+func (n *Notifier) checkWindowStart(now time.Time, settings *models.Settings) time.Time {
+	earliest := now.Add(-time.Minute)
+	if grace := time.Duration(settings.MissedReminderGraceMinutes) * time.Minute; grace > time.Minute {
+		earliest = now.Add(-grace)
+	}
+	last, err := n.settingsRepo.LastReminderCheck()
+	if err != nil || last.IsZero() || last.After(now) {
+		return now.Add(-time.Minute)
+	}
+	if last.Before(earliest) {
+		return earliest
+	}
+	return last
+}
 
-		// 1. 提前提醒
-		if todo.AdvanceRemind > 0 {
-			advanceTime := startTime.Add(-time.Duration(todo.AdvanceRemind) * time.Minute)
-			key := n.getNotifyKey(todo.ID, NotifyAdvance, now)
+// dueReminders 返回待办在检查窗口 (from, now] 内到时的提醒
+// 被前置待办阻塞时不发送提前提醒和到点提醒，结束提醒照常发送
+func (n *Notifier) dueReminders(todo models.Todo, from, now time.Time, settings *models.Settings) []reminder {
+	var due []reminder
+	add := func(notifyType NotificationType, at time.Time, title, message string) {
+		if isDue(at, from, now) {
+			due = append(due, reminder{todo: todo, notifyType: notifyType, at: at, title: title, message: message})
+		}
+	}
 
-			if !n.hasNotified(key) && n.isTimeMatch(now, advanceTime) {
-				title := fmt.Sprintf("⏰提前提醒: %s", todo.Title)
-				message := fmt.Sprintf("将在 %d 分钟后开始", todo.AdvanceRemind)
-				n.sendWindowsNotification(todo, title, message, playSound, soundFile, NotifyAdvance)
-				n.markNotified(key)
+	// 全天待办不按时刻提醒，开启到点提醒时在设置的时间提醒一次
+	if todo.AllDay {
+		if todo.RemindAtStart && !todo.IsBlocked {
+			message := "今天"
+			if settings.AllDayRemindDaysBefore == 1 {
+				message = "明天"
+			} else if settings.AllDayRemindDaysBefore > 1 {
+				message = fmt.Sprintf("%d 天后", settings.AllDayRemindDaysBefore)
+			}
+			if todo.Content != "" {
+				message += ": " + todo.Content
 			}
+			add(NotifyStart, settings.AllDayRemindAt(todo.StartDate.Time), fmt.Sprintf("📅全天: %s", todo.Title), message)
+		}
+		return due
+	}
+
+	startTime := todo.StartDate.Time
+	endTime := todo.EndDate.Time
+
+	// 1. 提前提醒
+	if todo.AdvanceRemind > 0 && !todo.IsBlocked {
+		add(NotifyAdvance, startTime.Add(-time.Duration(todo.AdvanceRemind)*time.Minute),
+			fmt.Sprintf("⏰提前提醒: %s", todo.Title),
+			fmt.Sprintf("将在 %d 分钟后开始", todo.AdvanceRemind))
+	}
+
+	// 2. 到点提醒 (开始时间)
+	if todo.RemindAtStart && !todo.IsBlocked {
+		message := "任务已开始"
+		if todo.Content != "" {
+			message = todo.Content
+		}
+		add(NotifyStart, startTime, fmt.Sprintf("🔔开始: %s", todo.Title), message)
+	}
+
+	// 3. 结束提醒
+	if todo.RemindAtEnd {
+		add(NotifyEnd, endTime, fmt.Sprintf("✅结束提醒: %s", todo.Title), "已到任务结束时间。")
+	}
+	return due
+}
+
+// notifyMissed 补发错过的提醒，数量较多时合并为一条汇总通知
+func (n *Notifier) notifyMissed(missed []reminder, playSound bool, soundFile string) {
+	if len(missed) == 0 {
+		return
+	}
+	sort.SliceStable(missed, func(i, j int) bool {
+		return missed[i].at.Before(missed[j].at)
+	})
+
+	if len(missed) <= missedSummaryThreshold {
+		for _, r := range missed {
+			n.sendWindowsNotification(r.todo, r.title, missedMessage(r), playSound, soundFile, r.notifyType)
 		}
+		return
+	}
 
-		// 2. 到点提醒 (开始时间)
-		if todo.RemindAtStart {
-			key := n.getNotifyKey(todo.ID, NotifyStart, now)
-
-			if !n.hasNotified(key) && n.isTimeMatch(now, startTime) {
-				title := fmt.Sprintf("🔔开始: %s", todo.Title)
-				message := "任务已开始"
-				if todo.Content != "" {
-					message = todo.Content
-				}
-				n.sendWindowsNotification(todo, title, message, playSound, soundFile, NotifyStart)
-				n.markNotified(key)
+	// 每个错过的提醒各写一条通知记录，弹窗只显示一条汇总，汇总本身不写记录
+	items := make([]string, 0, len(missed))
+	for _, r := range missed {
+		n.logNotification(r.todo, r.title, missedMessage(r), r.notifyType)
+		items = append(items, fmt.Sprintf("%s %s", r.at.Format("15:04"), r.todo.Title))
+	}
+	title := fmt.Sprintf("📋错过了 %d 个提醒", len(missed))
+	n.showNotification(models.Todo{}, 0, title, strings.Join(items, "、"), playSound, soundFile, NotifyMissed)
+}
+
+// missedMessage 错过的提醒的消息内容，注明原提醒时间
+func missedMessage(r reminder) string {
+	return fmt.Sprintf("错过的提醒(%s): %s", r.at.Format("01-02 15:04"), r.message)
+}
+
+// checkSnoozes 发送到时的推迟提醒，待办已完成、已删除或所在日历不提醒时丢弃
+func (n *Notifier) checkSnoozes(now time.Time, silenced map[int64]bool, playSound bool, soundFile string) {
+	snoozes, err := n.snoozeRepo.Due(now)
+	if err != nil {
+		return
+	}
+	for _, snooze := range snoozes {
+		claimed, err := n.snoozeRepo.Claim(snooze.ID)
+		if err != nil || !claimed {
+			continue
+		}
+		todo, err := n.todoRepo.GetOccurrence(snooze.TodoID, snooze.RepeatIndex)
+		if err != nil || todo.IsCompleted || silenced[todo.CalendarID] {
+			continue
+		}
+
+		title := fmt.Sprintf("💤稍后提醒: %s", todo.Title)
+		var message string
+		switch NotificationType(snooze.Reminder) {
+		case NotifyAdvance:
+			message = fmt.Sprintf("将于 %s 开始", todo.StartDate.Time.Format("01-02 15:04"))
+		case NotifyEnd:
+			message = "已到任务结束时间。"
+		default:
+			message = "任务已开始"
+			if todo.Content != "" {
+				message = todo.Content
 			}
 		}
+		n.sendWindowsNotification(*todo, title, message, playSound, soundFile, NotificationType(snooze.Reminder))
+	}
+}
 
-		// 3. 结束提醒
-		if todo.RemindAtEnd && !endTime.IsZero() {
-			key := n.getNotifyKey(todo.ID, NotifyEnd, now)
+// checkPomodoro 番茄钟到时后结束当前阶段并提醒：专注结束后开始休息，休息结束后本轮结束
+func (n *Notifier) checkPomodoro(now time.Time, settings *models.Settings, playSound bool, soundFile string) {
+	entry, err := n.entryRepo.Running()
+	if err != nil || entry == nil {
+		return
+	}
+	if entry.Kind != models.TimeEntryPomodoro && entry.Kind != models.TimeEntryBreak {
+		return
+	}
+	end := entry.PlannedEnd()
+	if end.IsZero() || end.After(now) {
+		return
+	}
 
-			if !n.hasNotified(key) && n.isTimeMatch(now, endTime) {
-				title := fmt.Sprintf("✅结束提醒: %s", todo.Title)
-				message := "已到任务结束时间。"
-				n.sendWindowsNotification(todo, title, message, playSound, soundFile, NotifyEnd)
-				n.markNotified(key)
+	var title, message string
+	finished := false
+	if entry.Kind == models.TimeEntryPomodoro {
+		breakMinutes := 0
+		err = database.RunInTx(n.db, func(tx *sql.Tx) error {
+			entryRepo := n.entryRepo.WithTx(tx)
+			ok, err := entryRepo.Finish(entry.ID, end)
+			if err != nil || !ok {
+				return err
+			}
+			finished = true
+			local := end.In(time.Local)
+			dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+			count, err := entryRepo.CountFinishedSince(models.TimeEntryPomodoro, dayStart)
+			if err != nil {
+				return err
 			}
+			breakMinutes = settings.PomodoroBreakMinutesAfter(count)
+			if breakMinutes <= 0 {
+				return nil
+			}
+			_, err = entryRepo.Create(&models.TimeEntry{
+				TodoID:         entry.TodoID,
+				Kind:           models.TimeEntryBreak,
+				StartedAt:      models.FlexTime{Time: end},
+				PlannedMinutes: breakMinutes,
+			})
+			return err
+		})
+		title = fmt.Sprintf("🍅专注结束: %s", entry.TodoTitle)
+		message = "本轮番茄钟已完成"
+		if breakMinutes > 0 {
+			message = fmt.Sprintf("休息 %d 分钟", breakMinutes)
 		}
+	} else {
+		finished, err = n.entryRepo.Finish(entry.ID, end)
+		title = fmt.Sprintf("☕休息结束: %s", entry.TodoTitle)
+		message = "可以开始下一个番茄钟了"
+	}
+	if err != nil || !finished {
+		return
+	}
+
+	todo := models.Todo{ID: entry.TodoID, Title: entry.TodoTitle}
+	if loaded, err := n.todoRepo.GetByID(entry.TodoID); err == nil {
+		todo = *loaded
+	}
+	n.sendWindowsNotification(todo, title, message, playSound, soundFile, NotifyPomodoro)
+	if n.ctx != nil {
+		runtime.EventsEmit(n.ctx, "timer:changed")
 	}
 }
 
-// isTimeMatch 检查当前时间是否匹配目标时间（精确到分钟，允许30秒误差）
-func (n *Notifier) isTimeMatch(now, target time.Time) bool {
+// isDue 检查提醒时间是否落在检查窗口 (from, now] 内(精确到分钟)
+// 每个分钟只落在一个窗口里，窗口重叠时由已发送提醒去重
+func isDue(target, from, now time.Time) bool {
 	if target.IsZero() {
 		return false
 	}
-
-	// 比较小时和分钟
-	if now.Year() == target.Year() &&
-		now.YearDay() == target.YearDay() &&
-		now.Hour() == target.Hour() &&
-		now.Minute() == target.Minute() {
-		return true
-	}
-	return false
+	minute := target.Truncate(time.Minute)
+	return minute.After(from) && !minute.After(now)
 }
 
 // sendWindowsNotification 发送 Windows Toast 通知
+// 每次发送都写入通知记录，弹窗通过记录ID回报用户的处理结果
 func (n *Notifier) sendWindowsNotification(todo models.Todo, title, message string, playSound bool, soundFile string, notifyType NotificationType) {
+	logID := n.logNotification(todo, title, message, notifyType)
+	n.showNotification(todo, logID, title, message, playSound, soundFile, notifyType)
+}
+
+// logNotification 写入通知记录，返回记录ID，写入失败时返回 0
+func (n *Notifier) logNotification(todo models.Todo, title, message string, notifyType NotificationType) int64 {
+	logID, _ := n.logRepo.Create(&models.NotificationLog{
+		TodoID:      todo.ID,
+		RepeatIndex: todo.RepeatIndex,
+		Reminder:    string(notifyType),
+		Title:       title,
+		Message:     message,
+	})
+	return logID
+}
+
+// showNotification 播放声音并显示通知弹窗，logID 为 0 时弹窗的处理结果不记录
+func (n *Notifier) showNotification(todo models.Todo, logID int64, title, message string, playSound bool, soundFile string, notifyType NotificationType) {
 	// 播放声音
 	if playSound {
 		go func() {
@@ -202,11 +438,6 @@ func (n *Notifier) sendWindowsNotification(todo models.Todo, title, message stri
 
 	// 启动通知弹窗进程
 	go func() {
-		exePath, err := os.Executable()
-		if err != nil {
-			return
-		}
-
 		// 获取通知类型显示名称
 		var typeLabel string
 		switch notifyType {
@@ -216,24 +447,68 @@ func (n *Notifier) sendWindowsNotification(todo models.Todo, title, message stri
 			typeLabel = "开始提醒"
 		case NotifyEnd:
 			typeLabel = "结束提醒"
+		case NotifyPomodoro:
+			typeLabel = "番茄钟"
+		case NotifyMissed:
+			typeLabel = "错过的提醒"
 		default:
 			typeLabel = "提醒"
 		}
 
-		// 启动通知弹窗进程
-		utils.StartProcess(exePath,
+		// 全天待办只显示日期，汇总通知不关联待办，不显示时间
+		timeLayout := "2006-01-02 15:04"
+		if todo.AllDay {
+			timeLayout = "2006-01-02"
+		}
+		startTime, endTime := "", ""
+		if todo.ID > 0 {
+			startTime = todo.StartDate.Time.Format(timeLayout)
+			endTime = todo.EndDate.Time.Format(timeLayout)
+		}
+
+		n.popup(
 			"--notify",
 			"--notify-title", title,
 			"--notify-message", message,
 			"--notify-type", typeLabel,
+			"--notify-kind", string(notifyType),
+			"--notify-log", fmt.Sprintf("%d", logID),
 			"--notify-todo", fmt.Sprintf("%d", todo.ID),
-			"--notify-start", todo.StartDate.Time.Format("2006-01-02 15:04"),
-			"--notify-end", todo.EndDate.Time.Format("2006-01-02 15:04"),
+			"--notify-index", fmt.Sprintf("%d", todo.RepeatIndex),
+			"--notify-start", startTime,
+			"--notify-end", endTime,
+			"--notify-location", todo.Location,
+			"--notify-url", todo.MeetingURL,
+			"--notify-attendees", attendeeNames(todo.Attendees),
 		)
 	}()
 
-	// 同时发送到前端（用于主窗口内的通知）
-	n.sendNotification(todo)
+	// 同时发送到前端（用于主窗口内的通知），汇总通知不关联待办
+	if todo.ID > 0 {
+		n.sendNotification(todo)
+	}
+}
+
+// startPopup 以通知参数启动本程序，显示通知弹窗
+func startPopup(args ...string) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return
+	}
+	utils.StartProcess(exePath, args...)
+}
+
+// attendeeNames 参会人名单，没有姓名时显示邮箱
+func attendeeNames(attendees []models.Attendee) string {
+	names := make([]string, 0, len(attendees))
+	for _, attendee := range attendees {
+		if attendee.Name != "" {
+			names = append(names, attendee.Name)
+		} else {
+			names = append(names, attendee.Email)
+		}
+	}
+	return strings.Join(names, ", ")
 }
 
 // sendNotification 发送通知
@@ -0,0 +1,388 @@
+package notification
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-calendar/internal/database"
+	"todo-calendar/internal/models"
+)
+
+// popupRecorder 记录启动的通知弹窗参数
+type popupRecorder chan map[string]string
+
+// newTestNotifier 创建使用临时数据库、不播放声音、不启动弹窗进程的通知管理器
+func newTestNotifier(t *testing.T) (*Notifier, popupRecorder) {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "todo_calendar.db"))
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("UPDATE settings SET notification_sound = 0 WHERE id = 1"); err != nil {
+		t.Fatalf("关闭提醒声音失败: %v", err)
+	}
+	n := NewNotifier(db)
+	popups := make(popupRecorder, 64)
+	n.popup = popups.record
+	return n, popups
+}
+
+// record 按 --notify-xxx 参数名记录弹窗参数
+func (p popupRecorder) record(args ...string) {
+	popup := map[string]string{}
+	for i := 1; i+1 < len(args); i += 2 {
+		popup[strings.TrimPrefix(args[i], "--notify-")] = args[i+1]
+	}
+	p <- popup
+}
+
+// wait 等待 want 个弹窗，并确认没有多余的弹窗
+func (p popupRecorder) wait(t *testing.T, want int) []map[string]string {
+	t.Helper()
+	var got []map[string]string
+	timeout := time.After(2 * time.Second)
+	for len(got) < want {
+		select {
+		case popup := <-p:
+			got = append(got, popup)
+		case <-timeout:
+			t.Fatalf("弹出 %d 个通知，应为 %d 个", len(got), want)
+		}
+	}
+	select {
+	case popup := <-p:
+		t.Fatalf("多弹出了通知: %v", popup)
+	case <-time.After(100 * time.Millisecond):
+	}
+	return got
+}
+
+// createReminder 创建一个只在 start 到点提醒的待办
+func createReminder(t *testing.T, n *Notifier, title string, start time.Time) int64 {
+	t.Helper()
+	id, err := n.todoRepo.Create(&models.Todo{
+		Title:         title,
+		Type:          models.TodoTypeTask,
+		RemindAtStart: true,
+		StartDate:     models.FlexTime{Time: start},
+		EndDate:       models.FlexTime{Time: start.Add(2 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("创建待办失败: %v", err)
+	}
+	// 新建待办默认提前15分钟提醒，这里只保留到点提醒
+	if _, err := n.db.Exec("UPDATE todos SET advance_remind = 0 WHERE id = ?", id); err != nil {
+		t.Fatalf("关闭提前提醒失败: %v", err)
+	}
+	return id
+}
+
+// setLastCheck 设置上次检查提醒的时间和补发宽限时间
+func setLastCheck(t *testing.T, n *Notifier, last time.Time, graceMinutes int) {
+	t.Helper()
+	var checkedAt interface{}
+	if !last.IsZero() {
+		checkedAt = last
+	}
+	if _, err := n.db.Exec("UPDATE settings SET last_reminder_check_at = ?, missed_reminder_grace_minutes = ? WHERE id = 1", checkedAt, graceMinutes); err != nil {
+		t.Fatalf("设置上次检查时间失败: %v", err)
+	}
+}
+
+// notificationLog 通知记录中用于断言的字段
+type notificationLog struct {
+	todoID   int64
+	reminder string
+	message  string
+}
+
+// notificationLogs 按写入顺序读取所有通知记录
+func notificationLogs(t *testing.T, db *sql.DB) []notificationLog {
+	t.Helper()
+	rows, err := db.Query("SELECT todo_id, reminder, message FROM notification_logs ORDER BY id")
+	if err != nil {
+		t.Fatalf("读取通知记录失败: %v", err)
+	}
+	defer rows.Close()
+	var logs []notificationLog
+	for rows.Next() {
+		var log notificationLog
+		if err := rows.Scan(&log.todoID, &log.reminder, &log.message); err != nil {
+			t.Fatalf("读取通知记录失败: %v", err)
+		}
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+// 从未检查过时只提醒当前这一分钟，不补发更早的提醒
+func TestFirstCheckSkipsOldReminders(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	now := time.Now()
+	createReminder(t, n, "早会", now.Add(-30*time.Minute))
+	createReminder(t, n, "站会", now.Truncate(time.Minute))
+
+	n.checkAndNotify()
+
+	got := popups.wait(t, 1)
+	if !strings.Contains(got[0]["title"], "站会") {
+		t.Errorf("弹出了 %q，应为当前这一分钟的提醒", got[0]["title"])
+	}
+}
+
+// 间隔较短时逐条补发错过的提醒，消息注明原提醒时间
+func TestMissedRemindersSentIndividually(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	now := time.Now()
+	setLastCheck(t, n, now.Add(-15*time.Minute), 60)
+	ids := []int64{
+		createReminder(t, n, "早会", now.Add(-10*time.Minute)),
+		createReminder(t, n, "站会", now.Add(-5*time.Minute)),
+	}
+
+	n.checkAndNotify()
+
+	popups.wait(t, 2)
+	logs := notificationLogs(t, n.db)
+	if len(logs) != 2 {
+		t.Fatalf("写入 %d 条通知记录，应为 2 条", len(logs))
+	}
+	for i, log := range logs {
+		if log.todoID != ids[i] || log.reminder != string(NotifyStart) || !strings.HasPrefix(log.message, "错过的提醒(") {
+			t.Errorf("第 %d 条通知记录为 %+v，应为待办 %d 的错过的到点提醒", i+1, log, ids[i])
+		}
+	}
+}
+
+// 错过的提醒较多时只弹出一条汇总，每个提醒仍各写一条关联待办的通知记录
+func TestMissedRemindersSummary(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	now := time.Now()
+	setLastCheck(t, n, now.Add(-50*time.Minute), 60)
+	var ids []int64
+	for i := 4; i >= 1; i-- {
+		ids = append(ids, createReminder(t, n, "会议", now.Add(-time.Duration(i*10)*time.Minute)))
+	}
+
+	n.checkAndNotify()
+
+	got := popups.wait(t, 1)
+	if got[0]["kind"] != string(NotifyMissed) || got[0]["title"] != "📋错过了 4 个提醒" || got[0]["log"] != "0" {
+		t.Errorf("汇总弹窗为 %v", got[0])
+	}
+	logs := notificationLogs(t, n.db)
+	if len(logs) != len(ids) {
+		t.Fatalf("写入 %d 条通知记录，应为 %d 条", len(logs), len(ids))
+	}
+	for i, log := range logs {
+		if log.todoID != ids[i] || log.reminder != string(NotifyStart) {
+			t.Errorf("第 %d 条通知记录为 %+v，应关联待办 %d", i+1, log, ids[i])
+		}
+	}
+}
+
+// 提醒时间早于补发宽限时间或不补发时不再提醒
+func TestMissedReminderGrace(t *testing.T) {
+	tests := []struct {
+		name   string
+		grace  int
+		lastAt time.Duration
+		due    time.Duration
+		want   int
+	}{
+		{"宽限时间内", 60, -3 * time.Hour, -30 * time.Minute, 1},
+		{"超过宽限时间", 60, -3 * time.Hour, -2 * time.Hour, 0},
+		{"不补发", 0, -15 * time.Minute, -10 * time.Minute, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, popups := newTestNotifier(t)
+			now := time.Now()
+			setLastCheck(t, n, now.Add(tt.lastAt), tt.grace)
+			createReminder(t, n, "复盘", now.Add(tt.due))
+
+			n.checkAndNotify()
+
+			popups.wait(t, tt.want)
+		})
+	}
+}
+
+// 重复检查时已发送的提醒不再发送
+func TestRepeatedCheckDoesNotResend(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	now := time.Now()
+	setLastCheck(t, n, now.Add(-15*time.Minute), 60)
+	createReminder(t, n, "早会", now.Add(-10*time.Minute))
+
+	n.checkAndNotify()
+	popups.wait(t, 1)
+
+	// 检查窗口与上次检查重叠时，由已发送提醒去重
+	setLastCheck(t, n, now.Add(-15*time.Minute), 60)
+	n.checkAndNotify()
+	popups.wait(t, 0)
+}
+
+// 两个通知管理器(如重启前后或同时运行的两个进程)共用数据库时，同一提醒只发送一次
+func TestClaimDedupesAcrossNotifiers(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	other := NewNotifier(n.db)
+	other.popup = popups.record
+	now := time.Now()
+	createReminder(t, n, "站会", now.Truncate(time.Minute))
+
+	n.checkAndNotify()
+	// 另一个通知管理器在第一个记录检查时间之前开始检查
+	setLastCheck(t, n, time.Time{}, 60)
+	other.checkAndNotify()
+
+	popups.wait(t, 1)
+	if logs := notificationLogs(t, n.db); len(logs) != 1 {
+		t.Errorf("写入 %d 条通知记录，应为 1 条", len(logs))
+	}
+}
+
+// 检查提醒时清理超过保留时间的已发送提醒
+func TestPruneFiredReminders(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	now := time.Now()
+	for key, firedAt := range map[string]time.Time{
+		"old":    now.Add(-firedRetention - time.Hour),
+		"recent": now.Add(-time.Hour),
+	} {
+		if _, err := n.firedRepo.Claim(key, 1, firedAt); err != nil {
+			t.Fatalf("登记提醒失败: %v", err)
+		}
+	}
+
+	n.checkAndNotify()
+
+	for key, want := range map[string]bool{"old": true, "recent": false} {
+		claimed, err := n.firedRepo.Claim(key, 1, now)
+		if err != nil {
+			t.Fatalf("登记提醒失败: %v", err)
+		}
+		if claimed != want {
+			t.Errorf("提醒 %s 能否重新登记为 %v，应为 %v", key, claimed, want)
+		}
+	}
+}
+
+// 提前提醒时间较长时，明天开始的待办也按时提前提醒
+func TestAdvanceReminderForLaterDay(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	start := time.Now().Truncate(time.Minute).Add(models.MaxAdvanceRemindMinutes * time.Minute)
+	id := createReminder(t, n, "出差", start)
+	if _, err := n.db.Exec("UPDATE todos SET advance_remind = ?, remind_at_start = 0 WHERE id = ?", models.MaxAdvanceRemindMinutes, id); err != nil {
+		t.Fatalf("设置提前提醒失败: %v", err)
+	}
+
+	n.checkAndNotify()
+
+	got := popups.wait(t, 1)
+	if got[0]["kind"] != string(NotifyAdvance) {
+		t.Errorf("弹出了 %v，应为提前提醒", got[0])
+	}
+}
+
+// 全天待办按设置的提醒时刻提前若干天提醒
+func TestAllDayReminderDaysBefore(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	now := time.Now()
+	if _, err := n.db.Exec("UPDATE settings SET all_day_remind_time = ?, all_day_remind_days_before = 1 WHERE id = 1", now.Format("15:04")); err != nil {
+		t.Fatalf("设置全天待办提醒失败: %v", err)
+	}
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.Local)
+	id := createReminder(t, n, "体检", tomorrow)
+	if _, err := n.db.Exec("UPDATE todos SET all_day = 1, time_zone = ? WHERE id = ?", models.TimeZoneFloating, id); err != nil {
+		t.Fatalf("设置全天待办失败: %v", err)
+	}
+
+	n.checkAndNotify()
+
+	got := popups.wait(t, 1)
+	if got[0]["title"] != "📅全天: 体检" {
+		t.Errorf("弹出了 %v，应为全天待办的提醒", got[0])
+	}
+}
+
+// 番茄钟专注结束后开始休息，每完成若干个番茄钟休息较长时间，休息结束后本轮结束
+func TestPomodoroPhases(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	now := time.Now()
+	id := createReminder(t, n, "写周报", now.Add(time.Hour))
+	settings := &models.Settings{PomodoroBreakMinutes: 5, PomodoroLongBreakMinutes: 15, PomodoroLongBreakEvery: 2}
+
+	for i, wantBreak := range []int{5, 15} {
+		started := now.Add(-26 * time.Minute)
+		if _, err := n.entryRepo.Create(&models.TimeEntry{TodoID: id, Kind: models.TimeEntryPomodoro, StartedAt: models.FlexTime{Time: started}, PlannedMinutes: 25}); err != nil {
+			t.Fatalf("开始番茄钟失败: %v", err)
+		}
+
+		n.checkPomodoro(now, settings, false, "")
+		got := popups.wait(t, 1)
+		if got[0]["title"] != "🍅专注结束: 写周报" {
+			t.Errorf("第 %d 个番茄钟弹出了 %v，应为专注结束", i+1, got[0])
+		}
+		running, err := n.entryRepo.Running()
+		if err != nil || running == nil || running.Kind != models.TimeEntryBreak || running.PlannedMinutes != wantBreak {
+			t.Fatalf("第 %d 个番茄钟后正在进行的计时为 %+v (%v)，应为 %d 分钟的休息", i+1, running, err, wantBreak)
+		}
+		if !running.StartedAt.Time.Equal(started.Add(25 * time.Minute)) {
+			t.Errorf("休息开始于 %v，应从专注结束时开始", running.StartedAt.Time)
+		}
+
+		// 休息未结束时不提醒，结束后提醒并停止计时
+		n.checkPomodoro(now, settings, false, "")
+		popups.wait(t, 0)
+		n.checkPomodoro(now.Add(time.Duration(wantBreak)*time.Minute), settings, false, "")
+		if got := popups.wait(t, 1); got[0]["title"] != "☕休息结束: 写周报" {
+			t.Errorf("弹出了 %v，应为休息结束", got[0])
+		}
+		if running, _ := n.entryRepo.Running(); running != nil {
+			t.Errorf("休息结束后仍在计时: %+v", running)
+		}
+	}
+}
+
+// 推迟的提醒到时只弹出一次，待办已完成时不再提醒
+func TestSnoozedReminderFires(t *testing.T) {
+	n, popups := newTestNotifier(t)
+	now := time.Now()
+	id := createReminder(t, n, "写周报", now.Add(-time.Hour))
+	doneID := createReminder(t, n, "交报销", now.Add(-time.Hour))
+	if _, err := n.db.Exec("UPDATE todos SET is_completed = 1 WHERE id = ?", doneID); err != nil {
+		t.Fatalf("完成待办失败: %v", err)
+	}
+	for _, todoID := range []int64{id, doneID} {
+		if _, err := n.snoozeRepo.Save(&models.ReminderSnooze{TodoID: todoID, Reminder: string(NotifyEnd), FireAt: models.FlexTime{Time: now.Add(-time.Minute)}}); err != nil {
+			t.Fatalf("推迟提醒失败: %v", err)
+		}
+	}
+	if _, err := n.snoozeRepo.Save(&models.ReminderSnooze{TodoID: id, Reminder: string(NotifyStart), FireAt: models.FlexTime{Time: now.Add(time.Minute)}}); err != nil {
+		t.Fatalf("推迟提醒失败: %v", err)
+	}
+
+	n.checkSnoozes(now, nil, false, "")
+	got := popups.wait(t, 1)
+	if got[0]["title"] != "💤稍后提醒: 写周报" || got[0]["message"] != "已到任务结束时间。" {
+		t.Errorf("弹出了 %v，应为推迟的结束提醒", got[0])
+	}
+
+	// 已提醒和已完成待办的推迟记录被删除，未到时的保留
+	n.checkSnoozes(now, nil, false, "")
+	popups.wait(t, 0)
+	snoozes, err := n.snoozeRepo.List()
+	if err != nil {
+		t.Fatalf("获取推迟的提醒失败: %v", err)
+	}
+	if len(snoozes) != 1 || snoozes[0].Reminder != string(NotifyStart) {
+		t.Errorf("剩余推迟的提醒为 %+v，应只有未到时的开始提醒", snoozes)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"todo-calendar/internal/datadir"
 )
 
 // SoundInfo 声音信息
@@ -18,18 +20,7 @@ type SoundInfo struct {
 
 // GetSoundsDir 获取声音文件目录
 func GetSoundsDir() (string, error) {
-	exe, err := os.Executable()
-	if err != nil {
-		return "", err
-	}
-	soundsDir := filepath.Join(filepath.Dir(exe), "data", "sounds")
-
-	// 确保目录存在
-	if err := os.MkdirAll(soundsDir, 0755); err != nil {
-		return "", err
-	}
-
-	return soundsDir, nil
+	return datadir.SoundsDir()
 }
 
 // getWindowsSystemSounds 获取 Windows 系统内置声音
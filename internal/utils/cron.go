@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"sort"
+	"sync"
 	"time"
 
 	"todo-calendar/internal/models"
@@ -8,6 +10,34 @@ import (
 	"github.com/gorhill/cronexpr"
 )
 
+// maxDSTRetries 计算下一次执行时间时跳过夏令时重复时段的最大尝试次数
+const maxDSTRetries = 4
+
+// cronNext 计算 after 之后的下一次执行时间，Cron 字段按 after 所在时区的墙上时间匹配
+// cronexpr 直接在有夏令时的时区计算时会重复返回同一时间或死循环，这里先在 UTC 中按墙上时间计算再换回原时区：
+// 夏令时跳过的时间顺延到跳变之后，重复的时间只执行一次
+func cronNext(expr *cronexpr.Expression, after time.Time) time.Time {
+	loc := after.Location()
+	wall := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), after.Second(), after.Nanosecond(), time.UTC)
+	for i := 0; i < maxDSTRetries; i++ {
+		next := expr.Next(wall)
+		if next.IsZero() {
+			return next
+		}
+		t := time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), next.Minute(), next.Second(), 0, loc)
+		if t.Hour() != next.Hour() || t.Minute() != next.Minute() {
+			// 墙上时间落在夏令时跳过的时段内，按跳变前的偏移换算即顺延到跳变之后
+			_, offset := t.Zone()
+			t = next.Add(-time.Duration(offset) * time.Second).In(loc)
+		}
+		if t.After(after) {
+			return t
+		}
+		wall = next
+	}
+	return time.Time{}
+}
+
 // ParseCronExpr 解析Cron表达式并返回接下来5次执行时间
 func ParseCronExpr(expr string) models.CronNextRun {
 	result := models.CronNextRun{
@@ -33,7 +63,7 @@ func ParseCronExpr(expr string) models.CronNextRun {
 	// 计算接下来5次执行时间
 	now := time.Now()
 	for i := 0; i < 5; i++ {
-		next := cronExpr.Next(now)
+		next := cronNext(cronExpr, now)
 		result.NextRuns = append(result.NextRuns, next)
 		now = next
 	}
@@ -47,7 +77,7 @@ func GetNextCronTime(expr string) (time.Time, error) {
 	if err != nil {
 		return time.Time{}, err
 	}
-	return cronExpr.Next(time.Now()), nil
+	return cronNext(cronExpr, time.Now()), nil
 }
 
 // IsCronExprValid 检查Cron表达式是否有效
@@ -74,7 +104,7 @@ func CalculateEndDateByRemindCount(startTime time.Time, expr string, remindCount
 	// 从开始时间计算，执行 remindCount 次后的时间
 	current := startTime
 	for i := 0; i < remindCount; i++ {
-		current = cronExpr.Next(current)
+		current = cronNext(cronExpr, current)
 	}
 	return current, nil
 }
@@ -94,7 +124,7 @@ func CalculateRemindCountByEndDate(startTime time.Time, expr string, endTime tim
 	count := 0
 	current := startTime
 	for {
-		next := cronExpr.Next(current)
+		next := cronNext(cronExpr, current)
 		if next.After(endTime) {
 			break
 		}
@@ -141,7 +171,7 @@ func GetCronDatesInRange(expr string, todoStartTime time.Time, todoEndTime time.
 	maxIterations := 1000 // 防止无限循环
 
 	for i := 0; i < maxIterations; i++ {
-		next := cronExpr.Next(current)
+		next := cronNext(cronExpr, current)
 
 		// 如果下次执行时间超过了待办的结束时间或范围结束时间，停止
 		if next.After(todoEndTime) || next.After(rangeEnd) {
@@ -161,7 +191,7 @@ func GetCronDatesInRange(expr string, todoStartTime time.Time, todoEndTime time.
 }
 
 // GetCronScheduledTimes 获取cron表达式的执行时间列表
-// 从startTime开始，计算count次执行的具体时间
+// 从startTime开始，计算count次执行的具体时间，按startTime所在时区的墙上时间匹配
 func GetCronScheduledTimes(expr string, startTime time.Time, count int) []time.Time {
 	var times []time.Time
 
@@ -178,7 +208,7 @@ func GetCronScheduledTimes(expr string, startTime time.Time, count int) []time.T
 	current := startTime.Add(-time.Second)
 
 	for i := 0; i < count; i++ {
-		next := cronExpr.Next(current)
+		next := cronNext(cronExpr, current)
 		// 如果返回了零值时间，停止计算
 		if next.IsZero() {
 			break
@@ -189,3 +219,143 @@ func GetCronScheduledTimes(expr string, startTime time.Time, count int) []time.T
 
 	return times
 }
+
+// maxCronOccurrences 一次展开循环实例的上限，防止无限循环
+// 只限制返回的实例，范围之前的执行只计数不展开
+const maxCronOccurrences = 10000
+
+// cronAnchorEvery 计数时每隔多少次执行记录一个锚点
+const cronAnchorEvery = 500
+
+// maxCronAnchorKeys 锚点缓存最多保存的系列数，超出时清空重建
+const maxCronAnchorKeys = 1000
+
+// cronAnchor 第 index 次执行的时间
+type cronAnchor struct {
+	index int
+	at    time.Time
+}
+
+var (
+	cronAnchors     = map[string][]cronAnchor{}
+	cronAnchorsLock sync.Mutex
+)
+
+// cronAnchorKey 锚点缓存的键，表达式和起点相同的系列共用锚点
+func cronAnchorKey(expr string, startTime time.Time) string {
+	return expr + "|" + startTime.Location().String() + "|" + startTime.Format(time.RFC3339Nano)
+}
+
+// cronSkip 从startTime(含)开始计数早于before的执行次数，返回次数和最后一次的时间(没有时为startTime前一秒)
+// before为零值表示不限制时间，limit>0时最多计数limit次
+// 从缓存中最近的锚点继续计数，长期运行的高频系列不必每次从头计算
+func cronSkip(cronExpr *cronexpr.Expression, expr string, startTime, before time.Time, limit int) (int, time.Time) {
+	key := cronAnchorKey(expr, startTime)
+	index, current := 0, startTime.Add(-time.Second)
+
+	cronAnchorsLock.Lock()
+	anchors := cronAnchors[key]
+	cronAnchorsLock.Unlock()
+	i := sort.Search(len(anchors), func(i int) bool {
+		return (!before.IsZero() && !anchors[i].at.Before(before)) || (limit > 0 && anchors[i].index > limit)
+	})
+	if i > 0 {
+		index, current = anchors[i-1].index, anchors[i-1].at
+	}
+
+	var found []cronAnchor
+	for limit <= 0 || index < limit {
+		next := cronNext(cronExpr, current)
+		if next.IsZero() || (!before.IsZero() && !next.Before(before)) {
+			break
+		}
+		index++
+		current = next
+		if index%cronAnchorEvery == 0 {
+			found = append(found, cronAnchor{index: index, at: next})
+		}
+	}
+
+	if len(found) > 0 {
+		cronAnchorsLock.Lock()
+		if len(cronAnchors) >= maxCronAnchorKeys {
+			cronAnchors = map[string][]cronAnchor{}
+		}
+		anchors = cronAnchors[key]
+		for _, anchor := range found {
+			if len(anchors) == 0 || anchor.index > anchors[len(anchors)-1].index {
+				anchors = append(anchors, anchor)
+			}
+		}
+		cronAnchors[key] = anchors
+		cronAnchorsLock.Unlock()
+	}
+	return index, current
+}
+
+// GetCronOccurrencesBetween 获取从startTime(含)开始的执行中落在[from, until]内的时间，以及第一个的序号(从1开始)
+// count>0表示最多执行count次；from之前的执行只计数，最多返回maxCronOccurrences个
+// 按startTime所在时区的墙上时间匹配，返回的时间也在该时区
+func GetCronOccurrencesBetween(expr string, startTime, from, until time.Time, count int) ([]time.Time, int) {
+	var times []time.Time
+
+	cronExpr, err := cronexpr.Parse(expr)
+	if expr == "" || err != nil {
+		return times, 1
+	}
+	if from.Before(startTime) {
+		from = startTime
+	}
+
+	skipped, current := cronSkip(cronExpr, expr, startTime, from, count)
+	for i := 0; i < maxCronOccurrences; i++ {
+		if count > 0 && skipped+len(times) >= count {
+			break
+		}
+		next := cronNext(cronExpr, current)
+		if next.IsZero() || next.After(until) {
+			break
+		}
+		times = append(times, next)
+		current = next
+	}
+	return times, skipped + 1
+}
+
+// CountCronOccurrences 统计从startTime(含)开始到until(含)为止的执行次数
+// until为零值表示不限制终止时间，count>0表示最多计数count次，二者至少提供一个
+func CountCronOccurrences(expr string, startTime, until time.Time, count int) int {
+	if expr == "" || (until.IsZero() && count <= 0) {
+		return 0
+	}
+	cronExpr, err := cronexpr.Parse(expr)
+	if err != nil {
+		return 0
+	}
+	var before time.Time
+	if !until.IsZero() {
+		before = until.Add(time.Nanosecond)
+	}
+	total, _ := cronSkip(cronExpr, expr, startTime, before, count)
+	return total
+}
+
+// GetCronOccurrenceAt 获取从startTime(含)开始的第index次执行时间(从1开始)，不存在时返回零值
+func GetCronOccurrenceAt(expr string, startTime time.Time, index int) time.Time {
+	if expr == "" || index <= 0 {
+		return time.Time{}
+	}
+	cronExpr, err := cronexpr.Parse(expr)
+	if err != nil {
+		return time.Time{}
+	}
+	current := startTime.Add(-time.Second)
+	if index > 1 {
+		var skipped int
+		skipped, current = cronSkip(cronExpr, expr, startTime, time.Time{}, index-1)
+		if skipped < index-1 {
+			return time.Time{}
+		}
+	}
+	return cronNext(cronExpr, current)
+}
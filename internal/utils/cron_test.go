@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// 长期运行的高频系列：范围之前的执行只计数，范围内的实例和序号仍然正确
+func TestGetCronOccurrencesBetweenLongRunning(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		start    time.Time
+		from     time.Time
+		interval time.Duration
+	}{
+		{"每小时两年后", "0 * * * *", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC), time.Hour},
+		{"每5分钟60天后", "*/5 * * * *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until := tt.from.Add(24*time.Hour - time.Second)
+			times, first := GetCronOccurrencesBetween(tt.expr, tt.start, tt.from, until, 0)
+
+			wantFirst := int(tt.from.Sub(tt.start)/tt.interval) + 1
+			if wantFirst <= maxCronOccurrences {
+				t.Fatalf("测试数据应超过展开上限，第一个序号为 %d", wantFirst)
+			}
+			if first != wantFirst {
+				t.Errorf("第一个实例序号为 %d，应为 %d", first, wantFirst)
+			}
+			if want := int(24 * time.Hour / tt.interval); len(times) != want {
+				t.Fatalf("范围内有 %d 个实例，应为 %d 个", len(times), want)
+			}
+			if !times[0].Equal(tt.from) {
+				t.Errorf("第一个实例时间为 %v，应为 %v", times[0], tt.from)
+			}
+			if at := GetCronOccurrenceAt(tt.expr, tt.start, first); !at.Equal(times[0]) {
+				t.Errorf("第 %d 次执行时间为 %v，应为 %v", first, at, times[0])
+			}
+			if total := CountCronOccurrences(tt.expr, tt.start, until, 0); total != first+len(times)-1 {
+				t.Errorf("截止范围结束共执行 %d 次，应为 %d 次", total, first+len(times)-1)
+			}
+
+			// 第二次计算从缓存的锚点继续，结果应一致
+			again, againFirst := GetCronOccurrencesBetween(tt.expr, tt.start, tt.from, until, 0)
+			if againFirst != first || len(again) != len(times) {
+				t.Errorf("再次计算得到序号 %d 和 %d 个实例，应与第一次一致", againFirst, len(again))
+			}
+		})
+	}
+}
+
+// 有次数限制时，范围内的实例不超过剩余次数
+func TestGetCronOccurrencesBetweenCount(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	from := time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	times, first := GetCronOccurrencesBetween("0 9 * * *", start, from, until, 10)
+	if first != 8 || len(times) != 3 {
+		t.Errorf("得到从第 %d 次开始的 %d 个实例，应为从第 8 次开始的 3 个", first, len(times))
+	}
+	if total := CountCronOccurrences("0 9 * * *", start, time.Time{}, 10); total != 10 {
+		t.Errorf("共执行 %d 次，应为 10 次", total)
+	}
+	if at := GetCronOccurrenceAt("0 9 * * *", start, 10); !at.Equal(start.AddDate(0, 0, 9)) {
+		t.Errorf("第 10 次执行时间为 %v，应为 %v", at, start.AddDate(0, 0, 9))
+	}
+}
+
+// 夏令时：跳过的时间顺延到跳变之后，重复的时间只执行一次
+func TestCronNextDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("缺少时区数据: %v", err)
+	}
+	tests := []struct {
+		name  string
+		expr  string
+		start time.Time
+		want  []time.Time
+	}{
+		{"夏令时开始", "30 2 * * *", time.Date(2025, 3, 8, 2, 30, 0, 0, ny), []time.Time{
+			time.Date(2025, 3, 8, 2, 30, 0, 0, ny),
+			time.Date(2025, 3, 9, 3, 30, 0, 0, ny),
+			time.Date(2025, 3, 10, 2, 30, 0, 0, ny),
+		}},
+		{"夏令时结束", "30 1 * * *", time.Date(2025, 11, 1, 1, 30, 0, 0, ny), []time.Time{
+			time.Date(2025, 11, 1, 1, 30, 0, 0, ny),
+			time.Date(2025, 11, 2, 5, 30, 0, 0, time.UTC),
+			time.Date(2025, 11, 3, 1, 30, 0, 0, ny),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetCronScheduledTimes(tt.expr, tt.start, len(tt.want))
+			if len(got) != len(tt.want) {
+				t.Fatalf("得到 %d 次执行，应为 %d 次", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("第 %d 次执行时间为 %v，应为 %v", i+1, got[i], tt.want[i])
+				}
+				if got[i].Location() != ny {
+					t.Errorf("第 %d 次执行时间的时区为 %v，应保持 %v", i+1, got[i].Location(), ny)
+				}
+			}
+		})
+	}
+
+	// 夏令时结束当天有 25 小时，重复的 1 点只执行一次
+	day := time.Date(2025, 11, 2, 0, 0, 0, 0, ny)
+	times, _ := GetCronOccurrencesBetween("*/30 * * * *", day, day, day.Add(25*time.Hour-time.Second), 0)
+	if len(times) != 48 {
+		t.Errorf("夏令时结束当天执行 %d 次，应为 48 次", len(times))
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+
+	"todo-calendar/internal/datadir"
 )
 
 const appName = "TodoCalendar"
@@ -99,11 +101,7 @@ func IsAutoStartEnabled() bool {
 
 // GetAppDataDir 获取应用数据目录
 func GetAppDataDir() (string, error) {
-	exe, err := os.Executable()
-	if err != nil {
-		return "", err
-	}
-	return filepath.Dir(exe), nil
+	return datadir.Dir()
 }
 
 // OpenURL 打开URL
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalZoneName 获取本机时区的 IANA 名称，无法确定时返回空字符串
+// 依次尝试 TZ 环境变量、time.Local 的名称和 /etc/localtime 链接
+func LocalZoneName() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		if _, err := time.LoadLocation(tz); err == nil && tz != "Local" {
+			return tz
+		}
+	}
+	if name := time.Local.String(); name != "Local" && name != "" {
+		return name
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		target = filepath.ToSlash(target)
+		if i := strings.Index(target, "zoneinfo/"); i >= 0 {
+			name := target[i+len("zoneinfo/"):]
+			if _, err := time.LoadLocation(name); err == nil {
+				return name
+			}
+		}
+	}
+	return ""
+}
@@ -17,6 +17,8 @@ import (
 
 	"todo-calendar/internal/app"
 	"todo-calendar/internal/database"
+	"todo-calendar/internal/datadir"
+	"todo-calendar/internal/models"
 	"todo-calendar/internal/notification"
 	"todo-calendar/internal/tray"
 	"todo-calendar/internal/utils"
@@ -43,12 +45,27 @@ func main() {
 	notifyTitle := flag.String("notify-title", "", "通知标题")
 	notifyMessage := flag.String("notify-message", "", "通知消息")
 	notifyType := flag.String("notify-type", "提醒", "通知类型")
+	notifyKind := flag.String("notify-kind", "", "提醒类型: advance/start/end/pomodoro")
+	notifyLogId := flag.Int64("notify-log", 0, "通知记录ID")
 	notifyTodoId := flag.Int64("notify-todo", 0, "关联的待办ID")
+	notifyRepeatIndex := flag.Int("notify-index", 0, "关联的循环实例序号")
 	notifyStartTime := flag.String("notify-start", "", "开始时间")
 	notifyEndTime := flag.String("notify-end", "", "结束时间")
+	notifyLocation := flag.String("notify-location", "", "地点")
+	notifyMeetingURL := flag.String("notify-url", "", "线上会议链接")
+	notifyAttendees := flag.String("notify-attendees", "", "参会人")
 	todoId := flag.Int64("todo", 0, "打开指定待办的详情")
+	dataDir := flag.String("data-dir", "", "数据目录(也可通过环境变量 "+datadir.EnvDataDir+" 指定)")
+	portable := flag.Bool("portable", false, "便携模式，数据保存在程序目录下的 data")
 	flag.Parse()
 
+	// 数据目录参数同时写入环境变量，传递给小部件和通知弹窗子进程
+	if *dataDir != "" {
+		datadir.SetOverride(*dataDir)
+	} else if *portable {
+		datadir.SetPortable()
+	}
+
 	// 保存待办ID
 	openTodoId = *todoId
 
@@ -63,9 +80,12 @@ func main() {
 
 	// 根据模式启动不同的窗口
 	if *widgetMode {
+		application.SetOrigin(models.OriginWidget)
 		runWidgetWindow(application)
 	} else if *notifyMode {
-		runNotificationPopup(application, *notifyTitle, *notifyMessage, *notifyType, *notifyTodoId, *notifyStartTime, *notifyEndTime)
+		application.SetOrigin(models.OriginNotification)
+		runNotificationPopup(application, *notifyTitle, *notifyMessage, *notifyType, *notifyKind, *notifyLogId, *notifyTodoId, *notifyRepeatIndex, *notifyStartTime, *notifyEndTime,
+			*notifyLocation, *notifyMeetingURL, *notifyAttendees)
 	} else {
 		runMainWindow(application, db)
 	}
@@ -130,18 +150,29 @@ func runWidgetWindow(application *app.App) {
 }
 
 // 保存通知弹窗的参数
-var popupTitle, popupMessage, popupType string
-var popupTodoId int64
+var popupTitle, popupMessage, popupType, popupKind string
+var popupTodoId, popupLogId int64
+var popupRepeatIndex int
 var popupStartTime, popupEndTime string
+var popupLocation, popupMeetingURL, popupAttendees string
 
 // runNotificationPopup 启动通知弹窗窗口
-func runNotificationPopup(application *app.App, title, message, notifyType string, todoId int64, startTime, endTime string) {
+// 有会议链接时弹窗显示"打开链接"按钮；待办提醒(kind 为 advance/start/end)可以推迟和标记完成
+// 用户的处理结果通过 logId 写入通知记录
+func runNotificationPopup(application *app.App, title, message, notifyType, kind string, logId, todoId int64, repeatIndex int, startTime, endTime string,
+	location, meetingURL, attendees string) {
 	popupTitle = title
 	popupMessage = message
 	popupType = notifyType
+	popupKind = kind
+	popupLogId = logId
 	popupTodoId = todoId
+	popupRepeatIndex = repeatIndex
 	popupStartTime = startTime
 	popupEndTime = endTime
+	popupLocation = location
+	popupMeetingURL = meetingURL
+	popupAttendees = attendees
 
 	// 创建窗口模式服务
 	windowModeService := app.NewWindowModeService("notification")
@@ -180,12 +211,18 @@ func runNotificationPopup(application *app.App, title, message, notifyType strin
 				runtime.WindowExecJS(ctx, `window.location.hash = '#/notification-popup'`)
 				time.Sleep(100 * time.Millisecond)
 				runtime.EventsEmit(ctx, "notification:show", map[string]interface{}{
-					"title":     popupTitle,
-					"message":   popupMessage,
-					"type":      popupType,
-					"todoId":    popupTodoId,
-					"startTime": popupStartTime,
-					"endTime":   popupEndTime,
+					"title":       popupTitle,
+					"message":     popupMessage,
+					"type":        popupType,
+					"kind":        popupKind,
+					"logId":       popupLogId,
+					"todoId":      popupTodoId,
+					"repeatIndex": popupRepeatIndex,
+					"startTime":   popupStartTime,
+					"endTime":     popupEndTime,
+					"location":    popupLocation,
+					"meetingUrl":  popupMeetingURL,
+					"attendees":   popupAttendees,
 				})
 				// 先定位到右下角
 				utils.MoveWindowToBottomRight("待办通知")
@@ -251,6 +288,8 @@ func runMainWindow(application *app.App, db *sql.DB) {
 			trayManager.StartTray()
 			// 启动通知检查
 			go notifier.StartNotificationChecker()
+			// 定期清理回收站
+			application.StartTrashPurger()
 		},
 		OnDomReady: func(ctx context.Context) {
 			// DOM就绪后的初始化
@@ -274,6 +313,8 @@ func runMainWindow(application *app.App, db *sql.DB) {
 			return false
 		},
 		OnShutdown: func(ctx context.Context) {
+			// 先停止后台写入数据库的任务，再关闭数据库
+			application.StopTrashPurger()
 			database.CloseDB()
 		},
 		Bind: []interface{}{